@@ -0,0 +1,55 @@
+package consensus
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/s809616134/go-blocker/crypto"
+	"github.com/s809616134/go-blocker/proto"
+	"github.com/s809616134/go-blocker/types"
+)
+
+// hasQuorum reports whether stake is more than 2/3 of the total stake.
+func hasQuorum(stake, total int64) bool {
+	return 3*stake > 2*total
+}
+
+// hasOneThird reports whether stake is more than 1/3 of the total
+// stake, enough to contain an honest validator.
+func hasOneThird(stake, total int64) bool {
+	return 3*stake > total
+}
+
+// VerifyCommit checks the commit holds valid precommits for its block
+// of validators in set with more than 2/3 of the stake.
+func VerifyCommit(commit *proto.Commit, set ValidatorSet) error {
+	var (
+		stake  int64
+		voters = make(map[string]bool)
+	)
+	for _, v := range commit.Precommits {
+		if v.Type != proto.VoteType_PRECOMMIT {
+			return fmt.Errorf("commit holds a %s vote", v.Type)
+		}
+		if v.Height != commit.Height || v.Round != commit.Round {
+			return fmt.Errorf("commit holds a vote for height %d round %d, expected height %d round %d",
+				v.Height, v.Round, commit.Height, commit.Round)
+		}
+		if !bytes.Equal(v.BlockHash, commit.BlockHash) {
+			return fmt.Errorf("commit holds a vote for another block")
+		}
+		if !types.VerifyVote(v) {
+			return fmt.Errorf("commit holds a vote with an invalid signature")
+		}
+		address := crypto.PublicKeyFromBytes(v.PublicKey).Address().String()
+		if voters[address] {
+			return fmt.Errorf("commit holds more than one vote of %s", address)
+		}
+		voters[address] = true
+		stake += set.Stake(address)
+	}
+	if !hasQuorum(stake, set.TotalStake()) {
+		return fmt.Errorf("commit holds votes of (%d) stake, not more than 2/3 of (%d)", stake, set.TotalStake())
+	}
+	return nil
+}
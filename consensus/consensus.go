@@ -0,0 +1,606 @@
+// Package consensus decides the blocks of the chain with a Tendermint
+// style consensus. For every height the validators go through rounds of
+// a proposal, a prevote and a precommit. A block is final once the
+// validators holding more than 2/3 of the stake precommit it in the same
+// round, their precommits make up the commit of the block.
+package consensus
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	pb "github.com/golang/protobuf/proto"
+	"github.com/s809616134/go-blocker/crypto"
+	"github.com/s809616134/go-blocker/proto"
+	"github.com/s809616134/go-blocker/types"
+	"go.uber.org/zap"
+)
+
+// ErrFutureHeight is returned for messages of a height we can't decide
+// yet, we are missing blocks.
+var ErrFutureHeight = errors.New("message for a future height")
+
+// ConflictingVoteError is returned for a vote of a validator that voted
+// for another block in the same round before. The two votes convict it
+// of double signing.
+type ConflictingVoteError struct {
+	Prev *proto.Vote
+	Vote *proto.Vote
+}
+
+func (e *ConflictingVoteError) Error() string {
+	return fmt.Sprintf("conflicting %s of %s for height %d round %d",
+		e.Vote.Type, crypto.PublicKeyFromBytes(e.Vote.PublicKey).Address(), e.Vote.Height, e.Vote.Round)
+}
+
+const (
+	// Number of messages for the next height we keep until we decided
+	// the current one
+	maxFutureMessages = 1000
+	// Rounds further ahead of ours are ignored
+	maxRoundsAhead = 100
+)
+
+type step int
+
+const (
+	// waiting for the next height to start
+	stepNewHeight step = iota
+	stepPropose
+	stepPrevote
+	stepPrecommit
+)
+
+type Config struct {
+	// How long we wait for the proposal of a round
+	TimeoutPropose time.Duration
+	// How long we wait for a quorum once validators with 2/3 of the
+	// stake prevoted, or precommitted, without agreeing on a block
+	TimeoutPrevote   time.Duration
+	TimeoutPrecommit time.Duration
+	// Added to the timeouts every round, until they are long enough
+	// for the network to agree
+	TimeoutDelta time.Duration
+	// How long we wait after a block got decided before we start on
+	// the next height, sets the pace of the chain
+	TimeoutCommit time.Duration
+}
+
+var DefaultConfig = Config{
+	TimeoutPropose:   time.Second * 3,
+	TimeoutPrevote:   time.Second,
+	TimeoutPrecommit: time.Second,
+	TimeoutDelta:     time.Millisecond * 500,
+	TimeoutCommit:    time.Second * 5,
+}
+
+// ValidatorSet is the set of validators deciding a height.
+type ValidatorSet interface {
+	// Stake returns the stake of the validator with the given hex
+	// encoded address, 0 when it is not part of the set.
+	Stake(address string) int64
+	TotalStake() int64
+	// Proposer returns the address of the proposer of the round.
+	Proposer(round int32) string
+}
+
+// Backend connects the consensus to the chain and the network. Its
+// methods get called while the state is locked, they must not call back
+// into the state.
+type Backend interface {
+	// ChainID returns the chain the votes are cast on.
+	ChainID() string
+	// Height returns the height of the tip of the chain, the next
+	// block gets decided on top of it.
+	Height() int32
+	// ValidatorSet returns the validators deciding the next block.
+	ValidatorSet() (ValidatorSet, error)
+	// CreateBlock returns a new block on top of the tip.
+	CreateBlock() (*proto.Block, error)
+	// ValidateBlock checks the block proposed in the round is valid on
+	// top of the tip.
+	ValidateBlock(b *proto.Block, round int32) error
+	// Commit adds the decided block to the chain together with its
+	// commit.
+	Commit(b *proto.Block, commit *proto.Commit) error
+	// Broadcast sends a proposal or vote of ours to our peers.
+	Broadcast(msg any)
+}
+
+// State runs the consensus for the next height of the chain. Nodes
+// without a private key follow the votes of the validators and commit
+// the decided blocks without voting themselves.
+type State struct {
+	lock    sync.Mutex
+	cfg     Config
+	backend Backend
+	privKey *crypto.PrivateKey
+	logger  *zap.SugaredLogger
+
+	height     int32
+	round      int32
+	step       step
+	validators ValidatorSet
+	rounds     map[int32]*roundState
+
+	// The block we precommitted and the round we did it in, we only
+	// prevote for other blocks once a later round unlocks us.
+	lockedRound int32
+	lockedBlock *proto.Block
+	// The last block we saw a quorum of prevotes for, proposed again
+	// when it is our turn.
+	validRound int32
+	validBlock *proto.Block
+
+	// messages of the next height that arrived before we decided
+	// the current one
+	future []pb.Message
+}
+
+func NewState(cfg Config, backend Backend, privKey *crypto.PrivateKey, logger *zap.SugaredLogger) *State {
+	return &State{
+		cfg:     cfg,
+		backend: backend,
+		privKey: privKey,
+		logger:  logger,
+		rounds:  make(map[int32]*roundState),
+	}
+}
+
+// Start starts the consensus for the block on top of the tip.
+func (s *State) Start() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.newHeight(0)
+}
+
+// Update moves on to the height on top of the tip when the chain got
+// ahead of us, e.g. after catching up with our peers.
+func (s *State) Update() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.backend.Height() < s.height {
+		return nil
+	}
+	if err := s.newHeight(0); err != nil {
+		return err
+	}
+	s.process()
+	return nil
+}
+
+// Height returns the height we are deciding the block of.
+func (s *State) Height() int32 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.height
+}
+
+// Round returns the round we are in.
+func (s *State) Round() int32 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.round
+}
+
+// HandleProposal processes the proposal of a round.
+func (s *State) HandleProposal(p *proto.Proposal) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := s.handleProposal(p); err != nil {
+		return err
+	}
+	s.process()
+	return nil
+}
+
+// HandleVote processes the prevote or precommit of a validator.
+func (s *State) HandleVote(v *proto.Vote) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := s.handleVote(v); err != nil {
+		return err
+	}
+	s.process()
+	return nil
+}
+
+// newHeight resets the state for the block on top of the tip and starts
+// its first round after the given delay.
+func (s *State) newHeight(delay time.Duration) error {
+	validators, err := s.backend.ValidatorSet()
+	if err != nil {
+		return err
+	}
+	s.height = s.backend.Height() + 1
+	s.round = 0
+	s.step = stepNewHeight
+	s.validators = validators
+	s.rounds = make(map[int32]*roundState)
+	s.lockedRound, s.lockedBlock = -1, nil
+	s.validRound, s.validBlock = -1, nil
+	s.schedule(delay, s.height, 0, stepNewHeight)
+
+	future := s.future
+	s.future = nil
+	for _, msg := range future {
+		var err error
+		switch m := msg.(type) {
+		case *proto.Proposal:
+			err = s.handleProposal(m)
+		case *proto.Vote:
+			err = s.handleVote(m)
+		}
+		if err != nil {
+			s.logger.Debugw("dropping message of the previous height", "err", err)
+		}
+	}
+	return nil
+}
+
+// checkHeight reports whether the message of the given height is one
+// to process now. Messages of the next height are kept for later.
+func (s *State) checkHeight(height int32, msg pb.Message) (bool, error) {
+	switch {
+	case height == s.height:
+		return true, nil
+	case height < s.height:
+		return false, nil
+	case height == s.height+1:
+		if len(s.future) < maxFutureMessages {
+			s.future = append(s.future, msg)
+		}
+		return false, nil
+	default:
+		return false, ErrFutureHeight
+	}
+}
+
+func (s *State) checkRound(round int32) error {
+	if round < 0 || round > s.round+maxRoundsAhead {
+		return fmt.Errorf("invalid round (%d) we are in round (%d)", round, s.round)
+	}
+	return nil
+}
+
+func (s *State) handleProposal(p *proto.Proposal) error {
+	if p.Block == nil || p.Block.Header == nil {
+		return fmt.Errorf("proposal without a block")
+	}
+	ok, err := s.checkHeight(p.Block.Header.Height, p)
+	if !ok {
+		return err
+	}
+	if err := s.checkRound(p.Round); err != nil {
+		return err
+	}
+	if p.PolRound < -1 || p.PolRound >= p.Round {
+		return fmt.Errorf("invalid proof of lock round (%d) for round (%d)", p.PolRound, p.Round)
+	}
+
+	if !types.VerifyProposal(p) {
+		return fmt.Errorf("invalid proposal signature")
+	}
+	rs := s.roundState(p.Round)
+	if rs.proposal != nil {
+		return nil
+	}
+	address := crypto.PublicKeyFromBytes(p.PublicKey).Address().String()
+	if proposer := s.validators.Proposer(p.Round); address != proposer {
+		return fmt.Errorf("proposal of %s for round %d, expected %s", address, p.Round, proposer)
+	}
+	if p.PolRound == -1 && hex.EncodeToString(p.Block.PublicKey) != hex.EncodeToString(p.PublicKey) {
+		return fmt.Errorf("proposal of a block signed by someone else")
+	}
+
+	rs.proposal = p
+	if err := s.backend.ValidateBlock(p.Block, p.Round); err != nil {
+		s.logger.Debugw("invalid block proposed",
+			"height", s.height,
+			"round", p.Round,
+			"err", err)
+		return nil
+	}
+	rs.valid = true
+	return nil
+}
+
+func (s *State) handleVote(v *proto.Vote) error {
+	ok, err := s.checkHeight(v.Height, v)
+	if !ok {
+		return err
+	}
+	if err := s.checkRound(v.Round); err != nil {
+		return err
+	}
+	if v.Type != proto.VoteType_PREVOTE && v.Type != proto.VoteType_PRECOMMIT {
+		return fmt.Errorf("unknown vote type %s", v.Type)
+	}
+	if v.ChainID != s.backend.ChainID() {
+		return fmt.Errorf("vote of chain %q", v.ChainID)
+	}
+	if !types.VerifyVote(v) {
+		return fmt.Errorf("invalid vote signature")
+	}
+	address := crypto.PublicKeyFromBytes(v.PublicKey).Address().String()
+	stake := s.validators.Stake(address)
+	if stake == 0 {
+		return fmt.Errorf("vote of %s, not a validator", address)
+	}
+
+	var (
+		rs    = s.roundState(v.Round)
+		votes = rs.prevotes
+	)
+	if v.Type == proto.VoteType_PRECOMMIT {
+		votes = rs.precommits
+	}
+	if prev, _ := votes.add(address, stake, v); prev != nil {
+		return &ConflictingVoteError{Prev: prev, Vote: v}
+	}
+	return nil
+}
+
+func (s *State) roundState(round int32) *roundState {
+	rs, ok := s.rounds[round]
+	if !ok {
+		rs = newRoundState()
+		s.rounds[round] = rs
+	}
+	return rs
+}
+
+// process applies the rules of the algorithm until none of them fires.
+func (s *State) process() {
+	for s.applyRule() {
+	}
+}
+
+// applyRule applies the first rule that fires and reports whether one
+// did.
+func (s *State) applyRule() bool {
+	total := s.validators.TotalStake()
+
+	// a quorum of precommits decides the block, whatever round we
+	// are in
+	for round, rs := range s.rounds {
+		if rs.valid && hasQuorum(rs.precommits.stake[rs.proposalHash()], total) {
+			s.commit(round, rs)
+			return true
+		}
+	}
+	if s.step == stepNewHeight {
+		return false
+	}
+
+	rs := s.roundState(s.round)
+	hash := rs.proposalHash()
+
+	if s.step == stepPropose && rs.proposal != nil {
+		polRound := rs.proposal.PolRound
+		if polRound == -1 {
+			if rs.valid && (s.lockedRound == -1 || blockHash(s.lockedBlock) == hash) {
+				s.prevote(hash)
+			} else {
+				s.prevote("")
+			}
+			return true
+		}
+		// the block got a quorum of prevotes in an earlier round
+		if pol, ok := s.rounds[polRound]; ok && hasQuorum(pol.prevotes.stake[hash], total) {
+			if rs.valid && (s.lockedRound <= polRound || blockHash(s.lockedBlock) == hash) {
+				s.prevote(hash)
+			} else {
+				s.prevote("")
+			}
+			return true
+		}
+	}
+
+	if s.step == stepPrevote && !rs.prevoteTimeout && hasQuorum(rs.prevotes.total, total) {
+		rs.prevoteTimeout = true
+		s.schedule(s.timeout(s.cfg.TimeoutPrevote), s.height, s.round, stepPrevote)
+		return true
+	}
+
+	if s.step >= stepPrevote && !rs.prevoteQuorum && rs.valid && hasQuorum(rs.prevotes.stake[hash], total) {
+		rs.prevoteQuorum = true
+		if s.step == stepPrevote {
+			s.lockedRound, s.lockedBlock = s.round, rs.proposal.Block
+			s.precommit(hash)
+		}
+		s.validRound, s.validBlock = s.round, rs.proposal.Block
+		return true
+	}
+
+	if s.step == stepPrevote && hasQuorum(rs.prevotes.stake[""], total) {
+		s.precommit("")
+		return true
+	}
+
+	if !rs.precommitTimeout && hasQuorum(rs.precommits.total, total) {
+		rs.precommitTimeout = true
+		s.schedule(s.timeout(s.cfg.TimeoutPrecommit), s.height, s.round, stepPrecommit)
+		return true
+	}
+
+	// validators with more than 1/3 of the stake moved on to a later
+	// round, at least one of them honest
+	for round, rs := range s.rounds {
+		if round > s.round && hasOneThird(rs.voterStake(s.validators), total) {
+			s.startRound(round)
+			return true
+		}
+	}
+	return false
+}
+
+func (s *State) startRound(round int32) {
+	s.round = round
+	s.step = stepPropose
+	s.schedule(s.timeout(s.cfg.TimeoutPropose), s.height, round, stepPropose)
+
+	if !s.isProposer(round) {
+		return
+	}
+	// a block proposed again keeps the signature of its first proposer,
+	// the one its coinbase pays, we only sign the proposal
+	var (
+		block    = s.validBlock
+		polRound = s.validRound
+	)
+	if block == nil {
+		var err error
+		if block, err = s.backend.CreateBlock(); err != nil {
+			s.logger.Errorw("failed to create block", "height", s.height, "err", err)
+			return
+		}
+	}
+
+	p := &proto.Proposal{
+		Block:    block,
+		Round:    round,
+		PolRound: polRound,
+	}
+	types.SignProposal(s.privKey, p)
+	if err := s.handleProposal(p); err != nil {
+		s.logger.Errorw("failed to handle our proposal", "height", s.height, "err", err)
+		return
+	}
+	s.backend.Broadcast(p)
+}
+
+func (s *State) prevote(hash string) {
+	s.vote(proto.VoteType_PREVOTE, hash)
+	s.step = stepPrevote
+}
+
+func (s *State) precommit(hash string) {
+	s.vote(proto.VoteType_PRECOMMIT, hash)
+	s.step = stepPrecommit
+}
+
+// vote signs and broadcasts our vote for the block with the given hash,
+// "" for no block. Nodes that are not validators don't vote.
+func (s *State) vote(voteType proto.VoteType, hash string) {
+	if s.privKey == nil || s.validators.Stake(s.address()) == 0 {
+		return
+	}
+	blockHash, err := hex.DecodeString(hash)
+	if err != nil {
+		panic(err)
+	}
+	v := &proto.Vote{
+		Type:      voteType,
+		Height:    s.height,
+		Round:     s.round,
+		BlockHash: blockHash,
+		ChainID:   s.backend.ChainID(),
+	}
+	types.SignVote(s.privKey, v)
+	if err := s.handleVote(v); err != nil {
+		s.logger.Errorw("failed to handle our vote", "height", s.height, "err", err)
+		return
+	}
+	s.backend.Broadcast(v)
+}
+
+// commit adds the block decided in the round to the chain and moves on
+// to the next height.
+func (s *State) commit(round int32, rs *roundState) {
+	var (
+		hash  = rs.proposalHash()
+		votes = rs.precommits.votesFor(hash)
+	)
+	sort.Slice(votes, func(i, j int) bool {
+		return hex.EncodeToString(votes[i].PublicKey) < hex.EncodeToString(votes[j].PublicKey)
+	})
+	commit := &proto.Commit{
+		Height:     s.height,
+		Round:      round,
+		BlockHash:  types.HashBlock(rs.proposal.Block),
+		Precommits: votes,
+	}
+	if err := s.backend.Commit(rs.proposal.Block, commit); err != nil {
+		// don't try again, we catch up with our peers instead
+		rs.valid = false
+		s.logger.Errorw("failed to commit block", "height", s.height, "hash", hash, "err", err)
+		return
+	}
+	s.logger.Infow("committed block",
+		"height", s.height,
+		"round", round,
+		"hash", hash,
+		"lenTx", len(rs.proposal.Block.Transactions))
+
+	if err := s.newHeight(s.cfg.TimeoutCommit); err != nil {
+		s.logger.Errorw("failed to start the next height", "err", err)
+	}
+}
+
+// schedule fires the timeout of the step after the given delay, unless
+// we moved past it by then.
+func (s *State) schedule(delay time.Duration, height, round int32, st step) {
+	time.AfterFunc(delay, func() {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		s.onTimeout(height, round, st)
+	})
+}
+
+func (s *State) onTimeout(height, round int32, st step) {
+	if height != s.height {
+		return
+	}
+	switch st {
+	case stepNewHeight:
+		if s.step != stepNewHeight {
+			return
+		}
+		s.startRound(0)
+	case stepPropose:
+		if round != s.round || s.step != stepPropose {
+			return
+		}
+		s.prevote("")
+	case stepPrevote:
+		if round != s.round || s.step != stepPrevote {
+			return
+		}
+		s.precommit("")
+	case stepPrecommit:
+		if round != s.round {
+			return
+		}
+		s.startRound(round + 1)
+	}
+	s.process()
+}
+
+// timeout returns the timeout of the current round, longer with every
+// round.
+func (s *State) timeout(base time.Duration) time.Duration {
+	return base + time.Duration(s.round)*s.cfg.TimeoutDelta
+}
+
+func (s *State) isProposer(round int32) bool {
+	return s.privKey != nil && s.validators.Proposer(round) == s.address()
+}
+
+func (s *State) address() string {
+	return s.privKey.Public().Address().String()
+}
+
+func blockHash(b *proto.Block) string {
+	if b == nil {
+		return ""
+	}
+	return hex.EncodeToString(types.HashBlock(b))
+}
@@ -0,0 +1,324 @@
+package consensus
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/s809616134/go-blocker/crypto"
+	"github.com/s809616134/go-blocker/proto"
+	"github.com/s809616134/go-blocker/types"
+	"github.com/s809616134/go-blocker/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+var testConfig = Config{
+	TimeoutPropose:   time.Millisecond * 100,
+	TimeoutPrevote:   time.Millisecond * 50,
+	TimeoutPrecommit: time.Millisecond * 50,
+	TimeoutDelta:     time.Millisecond * 10,
+	TimeoutCommit:    time.Millisecond * 10,
+}
+
+// testValidatorSet gives every validator the same stake and lets them
+// propose in turns.
+type testValidatorSet struct {
+	addresses []string
+}
+
+func (vs *testValidatorSet) Stake(address string) int64 {
+	for _, a := range vs.addresses {
+		if a == address {
+			return 10
+		}
+	}
+	return 0
+}
+
+func (vs *testValidatorSet) TotalStake() int64 {
+	return int64(10 * len(vs.addresses))
+}
+
+func (vs *testValidatorSet) Proposer(round int32) string {
+	return vs.addresses[int(round)%len(vs.addresses)]
+}
+
+// testNetwork connects the states of the validators, every message gets
+// delivered to all the other states.
+type testNetwork struct {
+	lock    sync.Mutex
+	set     *testValidatorSet
+	backend []*testBackend
+}
+
+func (net *testNetwork) broadcast(from *testBackend, msg any) {
+	net.lock.Lock()
+	defer net.lock.Unlock()
+	for _, b := range net.backend {
+		if b == from || b.offline {
+			continue
+		}
+		go func(s *State) {
+			switch m := msg.(type) {
+			case *proto.Proposal:
+				s.HandleProposal(m)
+			case *proto.Vote:
+				s.HandleVote(m)
+			}
+		}(b.state)
+	}
+}
+
+type testBackend struct {
+	lock    sync.Mutex
+	net     *testNetwork
+	privKey *crypto.PrivateKey
+	state   *State
+	offline bool
+	blocks  []*proto.Block
+	commits []*proto.Commit
+}
+
+func (b *testBackend) ChainID() string {
+	return "test"
+}
+
+func (b *testBackend) Height() int32 {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return int32(len(b.blocks))
+}
+
+func (b *testBackend) ValidatorSet() (ValidatorSet, error) {
+	return b.net.set, nil
+}
+
+func (b *testBackend) CreateBlock() (*proto.Block, error) {
+	block := util.RandomBlock()
+	block.Header.Height = b.Height() + 1
+	types.SignBlock(b.privKey, block)
+	return block, nil
+}
+
+func (b *testBackend) ValidateBlock(block *proto.Block, round int32) error {
+	if block.Header.Height != b.Height()+1 {
+		return fmt.Errorf("invalid height")
+	}
+	return nil
+}
+
+func (b *testBackend) Commit(block *proto.Block, commit *proto.Commit) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.blocks = append(b.blocks, block)
+	b.commits = append(b.commits, commit)
+	return nil
+}
+
+func (b *testBackend) Broadcast(msg any) {
+	b.net.broadcast(b, msg)
+}
+
+func newTestNetwork(t *testing.T, n int) *testNetwork {
+	net := &testNetwork{set: &testValidatorSet{}}
+	for i := 0; i < n; i++ {
+		b := &testBackend{
+			net:     net,
+			privKey: crypto.GeneratPrivateKey(),
+		}
+		b.state = NewState(testConfig, b, b.privKey, zap.NewNop().Sugar())
+		net.set.addresses = append(net.set.addresses, b.privKey.Public().Address().String())
+		net.backend = append(net.backend, b)
+	}
+	return net
+}
+
+func (net *testNetwork) start(t *testing.T) {
+	for _, b := range net.backend {
+		if !b.offline {
+			require.Nil(t, b.state.Start())
+		}
+	}
+}
+
+// waitForHeight waits until the online validators committed the given
+// number of blocks.
+func (net *testNetwork) waitForHeight(t *testing.T, height int32) {
+	require.Eventually(t, func() bool {
+		for _, b := range net.backend {
+			if !b.offline && b.Height() < height {
+				return false
+			}
+		}
+		return true
+	}, time.Second*10, time.Millisecond*10)
+}
+
+func TestConsensusCommitsBlocks(t *testing.T) {
+	net := newTestNetwork(t, 4)
+	net.start(t)
+	net.waitForHeight(t, 3)
+
+	first := net.backend[0]
+	first.lock.Lock()
+	defer first.lock.Unlock()
+	for _, b := range net.backend[1:] {
+		b.lock.Lock()
+		for i := 0; i < 3; i++ {
+			// everybody decided on the same blocks
+			assert.Equal(t, types.HashBlock(first.blocks[i]), types.HashBlock(b.blocks[i]))
+			assert.Equal(t, int32(i+1), b.commits[i].Height)
+		}
+		b.lock.Unlock()
+	}
+	for _, commit := range first.commits {
+		assert.Nil(t, VerifyCommit(commit, net.set))
+	}
+}
+
+func TestConsensusOfflineValidator(t *testing.T) {
+	net := newTestNetwork(t, 4)
+	// the proposer of the first round never shows up
+	net.backend[0].offline = true
+	net.start(t)
+	net.waitForHeight(t, 2)
+
+	// 3 out of 4 validators are enough, but not without a round change
+	b := net.backend[1]
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for _, commit := range b.commits {
+		assert.Nil(t, VerifyCommit(commit, net.set))
+		assert.True(t, len(commit.Precommits) >= 3)
+	}
+	assert.True(t, b.commits[0].Round > 0)
+}
+
+func TestConsensusNoQuorum(t *testing.T) {
+	net := newTestNetwork(t, 3)
+	// 1 out of 3 validators is offline, the others hold exactly 2/3
+	net.backend[2].offline = true
+	net.start(t)
+
+	time.Sleep(time.Millisecond * 500)
+	for _, b := range net.backend {
+		assert.Equal(t, int32(0), b.Height())
+	}
+	assert.Equal(t, int32(1), net.backend[0].state.Height())
+}
+
+func TestConsensusConflictingVote(t *testing.T) {
+	net := newTestNetwork(t, 4)
+	for _, b := range net.backend[1:] {
+		b.offline = true
+	}
+	s := net.backend[0].state
+	require.Nil(t, s.Start())
+
+	prevote := func(hash []byte) *proto.Vote {
+		v := &proto.Vote{
+			Type:      proto.VoteType_PREVOTE,
+			Height:    1,
+			BlockHash: hash,
+			ChainID:   "test",
+		}
+		types.SignVote(net.backend[1].privKey, v)
+		return v
+	}
+	first := prevote(util.RandomHash())
+	require.Nil(t, s.HandleVote(first))
+	// the same vote again is fine, a vote for another block is not
+	require.Nil(t, s.HandleVote(first))
+	second := prevote(nil)
+	err := s.HandleVote(second)
+	var conflict *ConflictingVoteError
+	require.ErrorAs(t, err, &conflict)
+	assert.Equal(t, first, conflict.Prev)
+	assert.Equal(t, second, conflict.Vote)
+
+	// a precommit is no prevote
+	precommit := prevote(nil)
+	precommit.Type = proto.VoteType_PRECOMMIT
+	types.SignVote(net.backend[1].privKey, precommit)
+	assert.Nil(t, s.HandleVote(precommit))
+
+	// and votes of other chains don't count
+	other := prevote(nil)
+	other.Round = 1
+	other.ChainID = "other"
+	types.SignVote(net.backend[1].privKey, other)
+	assert.NotNil(t, s.HandleVote(other))
+}
+
+func TestConsensusProposalOfAnotherBlock(t *testing.T) {
+	net := newTestNetwork(t, 4)
+	for _, b := range net.backend[1:] {
+		b.offline = true
+	}
+	s := net.backend[0].state
+	require.Nil(t, s.Start())
+
+	// the proposer of round 1 proposes a block of another validator
+	block := util.RandomBlock()
+	block.Header.Height = 1
+	types.SignBlock(net.backend[2].privKey, block)
+	proposal := func(polRound int32) *proto.Proposal {
+		p := &proto.Proposal{Block: block, Round: 1, PolRound: polRound}
+		types.SignProposal(net.backend[1].privKey, p)
+		return p
+	}
+	err := s.HandleProposal(proposal(-1))
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "signed by someone else")
+
+	// proposed again, the block keeps the signature of its proposer
+	assert.Nil(t, s.HandleProposal(proposal(0)))
+}
+
+func TestVerifyCommit(t *testing.T) {
+	var (
+		keys = []*crypto.PrivateKey{crypto.GeneratPrivateKey(), crypto.GeneratPrivateKey(), crypto.GeneratPrivateKey()}
+		set  = &testValidatorSet{}
+		hash = util.RandomHash()
+	)
+	for _, key := range keys {
+		set.addresses = append(set.addresses, key.Public().Address().String())
+	}
+	precommit := func(key *crypto.PrivateKey) *proto.Vote {
+		v := &proto.Vote{
+			Type:      proto.VoteType_PRECOMMIT,
+			Height:    1,
+			BlockHash: hash,
+		}
+		types.SignVote(key, v)
+		return v
+	}
+	commit := &proto.Commit{
+		Height:     1,
+		BlockHash:  hash,
+		Precommits: []*proto.Vote{precommit(keys[0]), precommit(keys[1])},
+	}
+	// exactly 2/3 is not enough
+	assert.NotNil(t, VerifyCommit(commit, set))
+
+	// neither is voting twice
+	commit.Precommits = append(commit.Precommits, precommit(keys[1]))
+	assert.NotNil(t, VerifyCommit(commit, set))
+
+	commit.Precommits[2] = precommit(keys[2])
+	assert.Nil(t, VerifyCommit(commit, set))
+
+	// a vote of another round
+	commit.Precommits[2].Round = 1
+	types.SignVote(keys[2], commit.Precommits[2])
+	assert.NotNil(t, VerifyCommit(commit, set))
+
+	// a tampered vote
+	commit.Precommits[2] = precommit(keys[2])
+	commit.Precommits[2].Height = 2
+	commit.Height = 2
+	assert.NotNil(t, VerifyCommit(commit, set))
+}
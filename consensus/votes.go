@@ -0,0 +1,95 @@
+package consensus
+
+import (
+	"bytes"
+	"encoding/hex"
+
+	"github.com/s809616134/go-blocker/proto"
+)
+
+// voteSet holds the votes of one type cast in a round, at most one per
+// validator.
+type voteSet struct {
+	votes map[string]*proto.Vote
+	// stake voting for each hex encoded block hash, "" for no block
+	stake map[string]int64
+	// stake of all the validators that voted
+	total int64
+}
+
+func newVoteSet() *voteSet {
+	return &voteSet{
+		votes: make(map[string]*proto.Vote),
+		stake: make(map[string]int64),
+	}
+}
+
+// add records the vote of the validator with the given address and
+// reports whether it was its first vote in the set. When the validator
+// voted for another block before, its earlier vote is returned.
+func (vs *voteSet) add(address string, stake int64, v *proto.Vote) (*proto.Vote, bool) {
+	if prev, ok := vs.votes[address]; ok {
+		if !bytes.Equal(prev.BlockHash, v.BlockHash) {
+			return prev, false
+		}
+		return nil, false
+	}
+	vs.votes[address] = v
+	vs.stake[hex.EncodeToString(v.BlockHash)] += stake
+	vs.total += stake
+	return nil, true
+}
+
+// votesFor returns the votes for the block with the given hash.
+func (vs *voteSet) votesFor(hash string) []*proto.Vote {
+	votes := []*proto.Vote{}
+	for _, v := range vs.votes {
+		if hex.EncodeToString(v.BlockHash) == hash {
+			votes = append(votes, v)
+		}
+	}
+	return votes
+}
+
+// roundState is what we know about a round of the current height.
+type roundState struct {
+	proposal *proto.Proposal
+	// whether the proposed block is valid on top of our tip
+	valid      bool
+	prevotes   *voteSet
+	precommits *voteSet
+
+	// the rules that only fire once per round
+	prevoteTimeout   bool
+	precommitTimeout bool
+	prevoteQuorum    bool
+}
+
+func newRoundState() *roundState {
+	return &roundState{
+		prevotes:   newVoteSet(),
+		precommits: newVoteSet(),
+	}
+}
+
+func (rs *roundState) proposalHash() string {
+	if rs.proposal == nil {
+		return ""
+	}
+	return blockHash(rs.proposal.Block)
+}
+
+// voterStake returns the stake of the validators that cast any vote in
+// the round.
+func (rs *roundState) voterStake(set ValidatorSet) int64 {
+	var stake int64
+	for address := range rs.prevotes.votes {
+		stake += set.Stake(address)
+	}
+	for address := range rs.precommits.votes {
+		if _, ok := rs.prevotes.votes[address]; !ok {
+			stake += set.Stake(address)
+		}
+	}
+	return stake
+}
@@ -3,69 +3,95 @@ package main
 import (
 	"context"
 	"log"
+	"math/rand"
 	"time"
 
 	"github.com/s809616134/go-blocker/crypto"
 	"github.com/s809616134/go-blocker/node"
 	"github.com/s809616134/go-blocker/proto"
-	"github.com/s809616134/go-blocker/util"
+	"github.com/s809616134/go-blocker/types"
 	"google.golang.org/grpc"
 )
 
 func main() {
-	makeNode(":3000", []string{}, true)
+	validatorKey := node.GenesisValidatorKey()
+	makeNode(":3000", []string{}, validatorKey)
 	time.Sleep(time.Second)
-	makeNode(":4000", []string{":3000"}, false)
+	makeNode(":4000", []string{":3000"}, nil)
 	time.Sleep(time.Second)
-	makeNode(":5000", []string{":4000"}, false)
+	makeNode(":5000", []string{":4000"}, nil)
 
 	for {
 		time.Sleep(time.Second)
-		makeTransaction()
+		makeTransaction(validatorKey)
 	}
 }
 
-func makeNode(listenAddr string, bootstrapNodes []string, isValdidator bool) *node.Node {
+// makeNode starts a node, when a private key is given the node
+// produces blocks.
+func makeNode(listenAddr string, bootstrapNodes []string, privKey *crypto.PrivateKey) *node.Node {
 	cfg := node.ServerConfig{
 		Version:    "Blocker-1",
 		ListenAddr: listenAddr,
+		PrivateKey: privKey,
 	}
-	if isValdidator {
-		cfg.PrivateKey = crypto.GeneratPrivateKey()
+	n, err := node.NewNode(cfg)
+	if err != nil {
+		log.Fatal(err)
 	}
-	n := node.NewNode(cfg)
 	go n.Start(listenAddr, bootstrapNodes)
 	return n
 }
 
-func makeTransaction() {
+// makeTransaction spends an output of the validator, earned by the
+// block rewards, to a random address.
+func makeTransaction(privKey *crypto.PrivateKey) {
 	client, err := grpc.Dial(":3000", grpc.WithInsecure())
 	if err != nil {
 		log.Fatal(err)
 	}
 
 	c := proto.NewNodeClient(client)
-	privKey := crypto.GeneratPrivateKey()
+	unspent, err := c.ListUnspent(context.TODO(), &proto.AddressRequest{
+		Address: privKey.Public().Address().Bytes(),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	// bonded and locked outputs are not ours to spend
+	spendable := []*proto.UnspentOutput{}
+	for _, utxo := range unspent.Outputs {
+		if !utxo.Bonded && utxo.LockHeight == 0 {
+			spendable = append(spendable, utxo)
+		}
+	}
+	if len(spendable) == 0 {
+		return
+	}
+	utxo := spendable[rand.Intn(len(spendable))]
 
 	tx := &proto.Transaction{
 		Version: 1,
+		ChainID: node.DefaultChainID,
 		Inputs: []*proto.TxInput{
 			{
-				PrevTxHash:   util.RandomHash(),
-				PrevOutIndex: 0,
-				PublicKey:    privKey.Public().Address().Bytes(),
+				PrevTxHash:   utxo.TxHash,
+				PrevOutIndex: utxo.OutIndex,
+				PublicKey:    privKey.Public().Bytes(),
 			},
 		},
 		Outputs: []*proto.TxOutput{
 			{
-				Amount:  90,
-				Address: privKey.Public().Address().Bytes(),
+				// leave 1 as fee
+				Amount:  utxo.Amount - 1,
+				Address: crypto.GeneratPrivateKey().Public().Address().Bytes(),
 			},
 		},
 	}
+	tx.Inputs[0].Signature = types.SignTransaction(privKey, tx).Bytes()
 
-	_, err = c.HandleTransaction(context.TODO(), tx)
-	if err != nil {
-		log.Fatal(err)
+	// the output might already be spent by a tx waiting to be mined
+	if _, err := c.HandleTransaction(context.TODO(), tx); err != nil {
+		log.Println(err)
 	}
 }
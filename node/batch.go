@@ -0,0 +1,236 @@
+package node
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/s809616134/go-blocker/proto"
+	"github.com/s809616134/go-blocker/types"
+)
+
+// batch stages the changes a block makes to the stores of the chain.
+// Reads of the UTXO set go through the batch, so the block sees its own
+// changes. Nothing reaches the stores before commit, which either
+// applies all the changes or, when one of the stores fails, reverts the
+// changes it already wrote.
+type batch struct {
+	chain     *Chain
+	blocks    []*proto.Block
+	undo      map[string]*BlockUndo
+	commits   map[string]*proto.Commit
+	txx       []*proto.Transaction
+	utxos     map[string]*UTXO
+	utxoKeys  []string
+	bestBlock string
+}
+
+func (c *Chain) newBatch() *batch {
+	return &batch{
+		chain:   c,
+		undo:    make(map[string]*BlockUndo),
+		commits: make(map[string]*proto.Commit),
+		utxos:   make(map[string]*UTXO),
+	}
+}
+
+func (b *batch) putBlock(block *proto.Block) {
+	b.blocks = append(b.blocks, block)
+}
+
+func (b *batch) putUndo(hash string, undo *BlockUndo) {
+	b.undo[hash] = undo
+}
+
+func (b *batch) putCommit(hash string, commit *proto.Commit) {
+	b.commits[hash] = commit
+}
+
+func (b *batch) putTx(tx *proto.Transaction) {
+	b.txx = append(b.txx, tx)
+}
+
+// getUTXO returns a copy of the UTXO, changing it has no effect
+// until it is put back into the batch.
+func (b *batch) getUTXO(key string) (*UTXO, error) {
+	if utxo, ok := b.utxos[key]; ok {
+		if utxo == nil {
+			return nil, fmt.Errorf("could not find utxo with hash %s", key)
+		}
+		u := *utxo
+		return &u, nil
+	}
+
+	utxo, err := b.chain.utxoStore.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	u := *utxo
+	return &u, nil
+}
+
+// listUnbonding returns copies of the unspent UTXOs unbonded by an
+// unstake or undelegate tx, including the changes staged in the batch.
+func (b *batch) listUnbonding() ([]*UTXO, error) {
+	stored, err := b.chain.utxoStore.ListUnbonding()
+	if err != nil {
+		return nil, err
+	}
+	return b.merge(stored, func(utxo *UTXO) bool {
+		return !utxo.Bonded && utxo.Validator != ""
+	}), nil
+}
+
+// listBonded returns copies of the unspent bonded UTXOs, including the
+// changes staged in the batch.
+func (b *batch) listBonded() ([]*UTXO, error) {
+	stored, err := b.chain.utxoStore.ListBonded()
+	if err != nil {
+		return nil, err
+	}
+	return b.merge(stored, func(utxo *UTXO) bool {
+		return utxo.Bonded
+	}), nil
+}
+
+// merge returns copies of the stored UTXOs the batch did not change and
+// of the unspent UTXOs staged in the batch that match.
+func (b *batch) merge(stored []*UTXO, match func(*UTXO) bool) []*UTXO {
+	utxos := []*UTXO{}
+	for _, utxo := range stored {
+		// staged UTXOs are added below
+		if _, ok := b.utxos[fmt.Sprintf("%s_%d", utxo.Hash, utxo.OutIndex)]; ok {
+			continue
+		}
+		u := *utxo
+		utxos = append(utxos, &u)
+	}
+	for _, key := range b.utxoKeys {
+		if utxo := b.utxos[key]; utxo != nil && !utxo.Spent && match(utxo) {
+			u := *utxo
+			utxos = append(utxos, &u)
+		}
+	}
+	return utxos
+}
+
+func (b *batch) putUTXO(utxo *UTXO) {
+	b.stageUTXO(fmt.Sprintf("%s_%d", utxo.Hash, utxo.OutIndex), utxo)
+}
+
+func (b *batch) deleteUTXO(key string) {
+	b.stageUTXO(key, nil)
+}
+
+func (b *batch) stageUTXO(key string, utxo *UTXO) {
+	if _, ok := b.utxos[key]; !ok {
+		b.utxoKeys = append(b.utxoKeys, key)
+	}
+	b.utxos[key] = utxo
+}
+
+func (b *batch) setBestBlock(hash string) {
+	b.bestBlock = hash
+}
+
+// commit writes the staged changes to the stores. The best block goes
+// last, so the UTXO store only points to a block once all of its
+// changes are written. The UTXO changes logged after the last best
+// block are dropped when the store gets reopened, so a crash in the
+// middle of the batch leaves the UTXO set of the previous block behind.
+func (b *batch) commit() error {
+	var (
+		c    = b.chain
+		undo = []func() error{}
+	)
+	fail := func(err error) error {
+		for i := len(undo) - 1; i >= 0; i-- {
+			if uerr := undo[i](); uerr != nil {
+				return fmt.Errorf("%s (reverting the batch failed: %s)", err, uerr)
+			}
+		}
+		return err
+	}
+
+	for _, block := range b.blocks {
+		hash := hex.EncodeToString(types.HashBlock(block))
+		if _, err := c.blockStore.Get(hash); err == nil {
+			continue
+		}
+		if err := c.blockStore.Put(block); err != nil {
+			return fail(err)
+		}
+		undo = append(undo, func() error {
+			return c.blockStore.Delete(hash)
+		})
+	}
+
+	for hash, blockUndo := range b.undo {
+		hash := hash
+		prev, err := c.blockStore.GetUndo(hash)
+		if err != nil {
+			prev = nil
+		}
+		if err := c.blockStore.PutUndo(hash, blockUndo); err != nil {
+			return fail(err)
+		}
+		// a new block takes its undo record with it when it gets
+		// deleted, only a replaced record has to be put back.
+		if prev != nil {
+			undo = append(undo, func() error {
+				return c.blockStore.PutUndo(hash, prev)
+			})
+		}
+	}
+
+	// only new blocks get a commit, which goes when the block
+	// gets deleted
+	for hash, commit := range b.commits {
+		if err := c.blockStore.PutCommit(hash, commit); err != nil {
+			return fail(err)
+		}
+	}
+
+	for _, tx := range b.txx {
+		hash := hex.EncodeToString(types.HashTransaction(tx))
+		if _, err := c.txStore.Get(hash); err == nil {
+			continue
+		}
+		if err := c.txStore.Put(tx); err != nil {
+			return fail(err)
+		}
+		undo = append(undo, func() error {
+			return c.txStore.Delete(hash)
+		})
+	}
+
+	for _, key := range b.utxoKeys {
+		key := key
+		prev, err := c.utxoStore.Get(key)
+		if err != nil {
+			prev = nil
+		}
+
+		if utxo := b.utxos[key]; utxo != nil {
+			err = c.utxoStore.PUT(utxo)
+		} else {
+			err = c.utxoStore.Delete(key)
+		}
+		if err != nil {
+			return fail(err)
+		}
+
+		undo = append(undo, func() error {
+			if prev == nil {
+				return c.utxoStore.Delete(key)
+			}
+			return c.utxoStore.PUT(prev)
+		})
+	}
+
+	if len(b.bestBlock) > 0 {
+		if err := c.utxoStore.PutBestBlock(b.bestBlock); err != nil {
+			return fail(err)
+		}
+	}
+	return nil
+}
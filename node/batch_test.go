@@ -0,0 +1,190 @@
+package node
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/s809616134/go-blocker/crypto"
+	"github.com/s809616134/go-blocker/proto"
+	"github.com/s809616134/go-blocker/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errInjected = errors.New("injected store failure")
+
+// failingUTXOStore fails the failAt-th PUT, counting from 1, and
+// the best block update when failBestBlock is set.
+type failingUTXOStore struct {
+	UTXOStorer
+	puts          int
+	failAt        int
+	failBestBlock bool
+}
+
+func (s *failingUTXOStore) PUT(utxo *UTXO) error {
+	s.puts++
+	if s.puts == s.failAt {
+		return errInjected
+	}
+	return s.UTXOStorer.PUT(utxo)
+}
+
+func (s *failingUTXOStore) PutBestBlock(hash string) error {
+	if s.failBestBlock {
+		return errInjected
+	}
+	return s.UTXOStorer.PutBestBlock(hash)
+}
+
+type failingTXStore struct {
+	TXStorer
+	fail bool
+}
+
+func (s *failingTXStore) Put(tx *proto.Transaction) error {
+	if s.fail {
+		return errInjected
+	}
+	return s.TXStorer.Put(tx)
+}
+
+// blockWithSplitTx returns a block spending the genesis output into
+// several outputs, so committing it takes multiple UTXO writes.
+func blockWithSplitTx(t *testing.T, chain *Chain) (*proto.Block, *proto.Transaction) {
+	tx := spendGenesisTx(t, chain, 400)
+	privKey := crypto.NewPrivateKeyFromSeedStr(godSeed)
+	for i := 0; i < 2; i++ {
+		tx.Outputs = append(tx.Outputs, &proto.TxOutput{
+			Amount:  300,
+			Address: privKey.Public().Address().Bytes(),
+		})
+	}
+	tx.Inputs[0].Signature = types.SignTransaction(privKey, tx).Bytes()
+
+	block := randomBLock(t, chain)
+	block.Transactions = append(block.Transactions, tx)
+	types.SignBlock(privKey, block)
+	return block, tx
+}
+
+func assertNoTrace(t *testing.T, chain *Chain, block *proto.Block, tx *proto.Transaction) {
+	assert.Equal(t, 0, chain.Height())
+	assert.False(t, chain.HasBlock(types.HashBlock(block)))
+
+	_, err := chain.blockStore.Get(hex.EncodeToString(types.HashBlock(block)))
+	assert.NotNil(t, err)
+
+	txHash := hex.EncodeToString(types.HashTransaction(tx))
+	_, err = chain.txStore.Get(txHash)
+	assert.NotNil(t, err)
+	for i := range tx.Outputs {
+		_, err := chain.utxoStore.Get(fmt.Sprintf("%s_%d", txHash, i))
+		assert.NotNil(t, err)
+	}
+
+	// the genesis output is still unspent
+	assert.Nil(t, chain.ValidateTransaction(tx))
+
+	genesis, err := chain.GetBlockByHeight(0)
+	require.Nil(t, err)
+	bestBlock, err := chain.utxoStore.GetBestBlock()
+	require.Nil(t, err)
+	assert.Equal(t, hex.EncodeToString(types.HashBlock(genesis)), bestBlock)
+}
+
+func TestAddBlockUTXOStoreFailure(t *testing.T) {
+	utxoStore := &failingUTXOStore{UTXOStorer: NewMemoryUTXOStore()}
+	chain, err := NewChain(DefaultChainID, NewMemoryBlockStore(), NewMemoryTXStore(), utxoStore)
+	require.Nil(t, err)
+
+	block, tx := blockWithSplitTx(t, chain)
+
+	// fail in the middle of writing the outputs of the tx
+	utxoStore.failAt = utxoStore.puts + 2
+	require.ErrorIs(t, chain.AddBlock(block), errInjected)
+	assertNoTrace(t, chain, block, tx)
+
+	// once the store recovers the block can be added
+	utxoStore.failAt = 0
+	require.Nil(t, chain.AddBlock(block))
+	assert.Equal(t, 1, chain.Height())
+	assert.NotNil(t, chain.ValidateTransaction(tx))
+}
+
+func TestAddBlockBestBlockFailure(t *testing.T) {
+	utxoStore := &failingUTXOStore{UTXOStorer: NewMemoryUTXOStore()}
+	chain, err := NewChain(DefaultChainID, NewMemoryBlockStore(), NewMemoryTXStore(), utxoStore)
+	require.Nil(t, err)
+
+	block, tx := blockWithSplitTx(t, chain)
+
+	// every UTXO is written, only the last step fails
+	utxoStore.failBestBlock = true
+	require.ErrorIs(t, chain.AddBlock(block), errInjected)
+	assertNoTrace(t, chain, block, tx)
+}
+
+func TestAddBlockTXStoreFailure(t *testing.T) {
+	txStore := &failingTXStore{TXStorer: NewMemoryTXStore()}
+	chain, err := NewChain(DefaultChainID, NewMemoryBlockStore(), txStore, NewMemoryUTXOStore())
+	require.Nil(t, err)
+
+	block, tx := blockWithSplitTx(t, chain)
+
+	txStore.fail = true
+	require.ErrorIs(t, chain.AddBlock(block), errInjected)
+	assertNoTrace(t, chain, block, tx)
+}
+
+func TestAddBlockAfterCrash(t *testing.T) {
+	dir := t.TempDir()
+	blockStore, txStore, utxoStore := openDiskStores(t, dir)
+	chain, err := NewChain(DefaultChainID, blockStore, txStore, utxoStore)
+	require.Nil(t, err)
+	block, tx := blockWithSplitTx(t, chain)
+
+	// the node goes down in the middle of committing the block, after
+	// the block and some of its UTXO changes got written
+	require.Nil(t, blockStore.Put(block))
+	txHash := hex.EncodeToString(types.HashTransaction(tx))
+	require.Nil(t, utxoStore.Delete(outpointKey(tx.Inputs[0])))
+	require.Nil(t, utxoStore.PUT(&UTXO{Hash: txHash, Amount: 400}))
+	require.Nil(t, blockStore.Close())
+	require.Nil(t, txStore.Close())
+	require.Nil(t, utxoStore.Close())
+
+	reopen := func() {
+		blockStore, txStore, utxoStore = openDiskStores(t, dir)
+		chain, err = NewChain(DefaultChainID, blockStore, txStore, utxoStore)
+		require.Nil(t, err)
+	}
+	reopen()
+	assert.Equal(t, 0, chain.Height())
+	assert.Nil(t, chain.ValidateTransaction(tx))
+	_, err = utxoStore.Get(fmt.Sprintf("%s_0", txHash))
+	assert.NotNil(t, err)
+
+	// the stored block is not on the main chain and gets committed again
+	assert.True(t, chain.HasBlock(types.HashBlock(block)))
+	assert.False(t, chain.OnMainChain(types.HashBlock(block)))
+	require.Nil(t, chain.CommitBlock(block, newCommit(block, GenesisValidatorKey())))
+	assert.True(t, chain.OnMainChain(types.HashBlock(block)))
+	require.Nil(t, blockStore.Close())
+	require.Nil(t, txStore.Close())
+	require.Nil(t, utxoStore.Close())
+
+	// the changes of the torn batch are gone for good
+	reopen()
+	defer blockStore.Close()
+	defer txStore.Close()
+	defer utxoStore.Close()
+	assert.Equal(t, 1, chain.Height())
+	assert.NotNil(t, chain.ValidateTransaction(tx))
+	for i := range tx.Outputs {
+		_, err := utxoStore.Get(fmt.Sprintf("%s_%d", txHash, i))
+		assert.Nil(t, err)
+	}
+}
@@ -4,7 +4,11 @@ import (
 	"bytes"
 	"encoding/hex"
 	"fmt"
+	"math"
+	"sync"
+	"time"
 
+	"github.com/s809616134/go-blocker/consensus"
 	"github.com/s809616134/go-blocker/crypto"
 	"github.com/s809616134/go-blocker/proto"
 	"github.com/s809616134/go-blocker/types"
@@ -12,6 +16,16 @@ import (
 
 const godSeed = "3d5b34a57112d5a91ae0d4ce57c4b99cdae3a7b12842cbb0a0e0289468df10d7"
 
+// GenesisValidatorKey returns the key of the validator bonded in the
+// genesis block. Its seed is public, it is only meant to bootstrap
+// local networks.
+func GenesisValidatorKey() *crypto.PrivateKey {
+	return crypto.NewPrivateKeyFromSeedStr(godSeed)
+}
+
+// DefaultChainID is the chain of the nodes not configured otherwise.
+const DefaultChainID = "blocker-devnet"
+
 type HeaderList struct {
 	headers []*proto.Header
 }
@@ -33,6 +47,13 @@ func (list *HeaderList) Add(h *proto.Header) {
 	list.headers = append(list.headers, h)
 }
 
+// Pop removes the last header of the list and returns it.
+func (list *HeaderList) Pop() *proto.Header {
+	h := list.headers[len(list.headers)-1]
+	list.headers = list.headers[:len(list.headers)-1]
+	return h
+}
+
 func (list *HeaderList) Height() int {
 	return list.Len() - 1
 }
@@ -45,77 +66,469 @@ type UTXO struct {
 	Hash     string
 	OutIndex int
 	Amount   int64
-	Spent    bool
+	// hex encoded address of the output owner
+	Address string
+	Spent   bool
+	// Bonded as stake of the owner, see StakingParams
+	Bonded bool
+	// Height of the first block the output can be spent in
+	LockHeight int
+	// hex encoded address of the validator the output is or was
+	// bonded to, the owner for its own stake
+	Validator string
+	// Height of the block that created the output
+	Height int
+}
+
+// missingInputError is returned for txs spending an output we don't
+// know of, usually because the tx creating it didn't reach us yet.
+type missingInputError struct {
+	key string
+}
+
+func (e *missingInputError) Error() string {
+	return fmt.Sprintf("could not find utxo with hash %s", e.key)
+}
+
+// outpointKey returns the UTXO key of the output the input spends.
+func outpointKey(input *proto.TxInput) string {
+	return fmt.Sprintf("%s_%d", hex.EncodeToString(input.PrevTxHash), input.PrevOutIndex)
+}
+
+// BlockUndo records the changes a block made to the UTXO set, so they
+// can be reverted when the block gets disconnected.
+type BlockUndo struct {
+	// The UTXOs spent by the block as they were before
+	Spent []*UTXO
+	// The keys of the UTXOs created by the block
+	Created []string
+	// The validator set of the next epoch when the block is the last
+	// of its epoch, nil otherwise
+	Validators []*Validator
+}
+
+// blockNode is an entry of the block tree. Every block of the main
+// chain has one, as well as the blocks that got disconnected or were
+// stored by a batch cut short by a crash, which are left off the main
+// chain until they get added again.
+type blockNode struct {
+	hash   string
+	header *proto.Header
+	parent *blockNode
+	height int
+	// validator set deciding the block on top of this one, known
+	// once the block got connected to the main chain
+	nextValidators []*Validator
 }
 
 type Chain struct {
+	lock       sync.RWMutex
 	txStore    TXStorer
 	blockStore BlockStorer
 	utxoStore  UTXOStorer
-	headers    *HeaderList
+	// headers of the main chain
+	headers *HeaderList
+	// all the blocks we know of by their hash
+	index map[string]*blockNode
+	tip   *blockNode
+	// the highest block decided by the consensus, the main chain
+	// never forks below it
+	finalized *blockNode
+	// the evidence of the main chain by its hash
+	evidence map[string]*includedEvidence
+	// the commissions the stake txs of the main chain set, by the
+	// address of their signer
+	commissions map[string][]*commissionChange
+	// amount a coinbase tx may mint per block
+	rewards RewardSchedule
+	staking StakingParams
+	// set by the genesis block, blocks and txs of other chains
+	// get rejected
+	chainID string
+
+	onBlockConnected func(*proto.Block)
 }
 
-func NewChain(bs BlockStorer, txStore TXStorer) *Chain {
+// NewChain creates a chain on top of the given stores. When the stores
+// already hold a chain, the block tree and the main chain are reloaded
+// from them, otherwise a new chain with the given id starting with the
+// genesis block is created.
+func NewChain(chainID string, bs BlockStorer, txStore TXStorer, utxoStore UTXOStorer) (*Chain, error) {
 	chain := &Chain{
-		txStore:    txStore,
-		blockStore: bs,
-		utxoStore:  NewMemoryUTXOStore(),
-		headers:    NewHeaderList(),
+		chainID:     chainID,
+		txStore:     txStore,
+		blockStore:  bs,
+		utxoStore:   utxoStore,
+		headers:     NewHeaderList(),
+		index:       make(map[string]*blockNode),
+		evidence:    make(map[string]*includedEvidence),
+		commissions: make(map[string][]*commissionChange),
+		rewards:     DefaultRewardSchedule,
+		staking:     DefaultStakingParams,
+	}
+
+	bestBlock, err := utxoStore.GetBestBlock()
+	if err != nil {
+		return nil, err
+	}
+	if len(bestBlock) == 0 {
+		if err := chain.addBlock(createGenesisBlock(chainID)); err != nil {
+			return nil, err
+		}
+		chain.finalized = chain.tip
+		return chain, nil
+	}
+
+	if err := chain.load(bestBlock); err != nil {
+		return nil, err
+	}
+	return chain, nil
+}
+
+// load rebuilds the block tree from the block store and makes the
+// branch ending with bestBlock, the block the UTXO set is up to
+// date with, our main chain.
+func (c *Chain) load(bestBlock string) error {
+	blocks, err := c.blockStore.List()
+	if err != nil {
+		return err
+	}
+	byHash := make(map[string]*proto.Block, len(blocks))
+	for _, b := range blocks {
+		node := c.newBlockNode(b)
+		c.index[node.hash] = node
+		byHash[node.hash] = b
+	}
+
+	tip, ok := c.index[bestBlock]
+	if !ok {
+		return fmt.Errorf("best block %s not found in block store", bestBlock)
+	}
+	mainChain := []*blockNode{}
+	for node := tip; node != nil; node = node.parent {
+		mainChain = append([]*blockNode{node}, mainChain...)
+		c.includeEvidence(byHash[node.hash], node.height)
+	}
+	if genesis := mainChain[0].header; genesis.ChainID != c.chainID {
+		return fmt.Errorf("stores hold chain %q, not %q", genesis.ChainID, c.chainID)
 	}
-	chain.addBlock(createGenesisBlock())
-	return chain
+	for _, node := range mainChain {
+		c.headers.Add(node.header)
+		c.includeCommissions(byHash[node.hash], node.height)
+		undo, err := c.blockStore.GetUndo(node.hash)
+		if err != nil {
+			return err
+		}
+		node.setNextValidators(undo)
+	}
+	c.tip = tip
+
+	// the genesis block is final as well
+	for node := tip; node != nil; node = node.parent {
+		c.finalized = node
+		if _, err := c.blockStore.GetCommit(node.hash); err == nil {
+			break
+		}
+	}
+	return nil
+}
+
+// OnBlockConnected registers a function that receives every block that
+// gets connected to the main chain.
+func (c *Chain) OnBlockConnected(fn func(*proto.Block)) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.onBlockConnected = fn
+}
+
+// SetRewardSchedule replaces the reward schedule blocks are validated
+// against.
+func (c *Chain) SetRewardSchedule(s RewardSchedule) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.rewards = s
+}
+
+// BlockReward returns the amount the coinbase tx of the block at the
+// given height may mint.
+func (c *Chain) BlockReward(height int) int64 {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.rewards.Reward(height)
+}
+
+// ChainID returns the id of the chain set by its genesis block.
+func (c *Chain) ChainID() string {
+	return c.chainID
 }
 
 func (c *Chain) Height() int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
 	return c.headers.Height()
 }
 
+// AddBlock connects a block on top of the tip that was not decided by
+// the consensus, its timestamp picks its proposer. Blocks decided by the
+// consensus are final, so the main chain never forks and a block that
+// doesn't extend the tip is rejected.
 func (c *Chain) AddBlock(b *proto.Block) error {
-	if err := c.ValidateBlock(b); err != nil {
+	c.lock.Lock()
+	if err := c.validateBlock(b); err != nil {
+		c.lock.Unlock()
 		return err
 	}
-	return c.addBlock(b)
+	err := c.addBlock(b)
+	onBlockConnected := c.onBlockConnected
+	c.lock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	// Called without holding the lock, so it can query the chain
+	if onBlockConnected != nil {
+		onBlockConnected(b)
+	}
+	return nil
 }
 
+// CommitBlock adds the block decided by the consensus to the main chain
+// and stores its commit next to it. The block becomes final, it never
+// gets disconnected.
+func (c *Chain) CommitBlock(b *proto.Block, commit *proto.Commit) error {
+	c.lock.Lock()
+	if err := c.validateCommit(b, commit); err != nil {
+		c.lock.Unlock()
+		return err
+	}
+	node := c.newBlockNode(b)
+	err := c.connectBlock(node, b, true, commit)
+	if err == nil {
+		c.index[node.hash] = node
+		c.finalized = node
+	}
+	onBlockConnected := c.onBlockConnected
+	c.lock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if onBlockConnected != nil {
+		onBlockConnected(b)
+	}
+	return nil
+}
+
+// validateCommit validates the block and checks its commit holds the
+// precommits of more than 2/3 of the stake of the validator set at the
+// tip.
+func (c *Chain) validateCommit(b *proto.Block, commit *proto.Commit) error {
+	if err := c.validateHeader(b); err != nil {
+		return err
+	}
+	hash := types.HashBlock(b)
+	if commit.Height != b.Header.Height || !bytes.Equal(commit.BlockHash, hash) {
+		return fmt.Errorf("commit is not for block %s", hex.EncodeToString(hash))
+	}
+	set, err := c.validatorSet()
+	if err != nil {
+		return err
+	}
+	if err := consensus.VerifyCommit(commit, set); err != nil {
+		return err
+	}
+	if commit.Round < 0 {
+		return fmt.Errorf("invalid commit round (%d)", commit.Round)
+	}
+	return c.validateState(b, 0, uint64(commit.Round))
+}
+
+// ValidateProposal checks the block proposed in a consensus round is
+// valid on top of the tip.
+func (c *Chain) ValidateProposal(b *proto.Block, round int32) error {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	if err := c.validateHeader(b); err != nil {
+		return err
+	}
+	if round < 0 {
+		return fmt.Errorf("invalid round (%d)", round)
+	}
+	return c.validateState(b, 0, uint64(round))
+}
+
+// HasBlock reports whether the block with the given hash is known,
+// either on the main chain or left off it, see OnMainChain.
+func (c *Chain) HasBlock(hash []byte) bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	_, ok := c.index[hex.EncodeToString(hash)]
+	return ok
+}
+
+// OnMainChain reports whether the block with the given hash is part of
+// the main chain. A block stored by a batch cut short by a crash, or
+// disconnected, is only known to the block tree and can be added again.
+func (c *Chain) OnMainChain(hash []byte) bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.onMainChain(hex.EncodeToString(hash))
+}
+
+func (c *Chain) onMainChain(hash string) bool {
+	node, ok := c.index[hash]
+	if !ok || node.height > c.headers.Height() {
+		return false
+	}
+	return hex.EncodeToString(types.HashHeader(c.headers.Get(node.height))) == hash
+}
+
+// addBlock connects the block extending the tip to the main chain.
 func (c *Chain) addBlock(b *proto.Block) error {
-	// Add the block header to the header list of the chain
-	c.headers.Add(b.Header)
-	// validation
-	for _, tx := range b.Transactions {
-		if err := c.txStore.Put(tx); err != nil {
-			return err
-		}
+	node := c.newBlockNode(b)
+	if err := c.connectBlock(node, b, true, nil); err != nil {
+		return err
+	}
+	c.index[node.hash] = node
+	return nil
+}
 
-		hash := hex.EncodeToString(types.HashTransaction(tx))
+// newBlockNode creates the entry of the block in the block tree, or
+// returns the one of a block left off the main chain we know already.
+func (c *Chain) newBlockNode(b *proto.Block) *blockNode {
+	if node, ok := c.index[hex.EncodeToString(types.HashBlock(b))]; ok {
+		return node
+	}
+	node := &blockNode{
+		hash:   hex.EncodeToString(types.HashBlock(b)),
+		header: b.Header,
+	}
+	if parent, ok := c.index[hex.EncodeToString(b.Header.PrevHash)]; ok {
+		node.parent = parent
+		node.height = parent.height + 1
+	}
+	return node
+}
 
-		for it, output := range tx.Outputs {
-			utxo := &UTXO{
-				Hash:     hash,
-				Amount:   output.Amount,
-				OutIndex: it,
-				Spent:    false,
-			}
+// connectBlock applies the transactions of the block to the UTXO set
+// and appends its header to the main chain. A block we did not store
+// yet is written within the same batch, so when the batch fails no
+// trace of the block is left. The same goes for the commit of a block
+// decided by the consensus.
+func (c *Chain) connectBlock(node *blockNode, b *proto.Block, store bool, commit *proto.Commit) error {
+	var (
+		batch = c.newBatch()
+		undo  = &BlockUndo{}
+	)
+	if store {
+		batch.putBlock(b)
+	}
 
-			if err := c.utxoStore.PUT(utxo); err != nil {
-				return err
-			}
+	for _, tx := range b.Transactions {
+		batch.putTx(tx)
+
+		for _, utxo := range c.outputUTXOs(tx, node.height) {
+			batch.putUTXO(utxo)
+			undo.Created = append(undo.Created, fmt.Sprintf("%s_%d", utxo.Hash, utxo.OutIndex))
 		}
 
 		for _, input := range tx.Inputs {
 			// retreive the key of map[string]*UTXO
-			key := fmt.Sprintf("%s_%d", hex.EncodeToString(input.PrevTxHash), input.PrevOutIndex)
-			utxo, err := c.utxoStore.Get(key)
+			key := outpointKey(input)
+			utxo, err := batch.getUTXO(key)
 			if err != nil {
 				return err
 			}
+			spent := *utxo
+			undo.Spent = append(undo.Spent, &spent)
 			utxo.Spent = true
-			if err := c.utxoStore.PUT(utxo); err != nil {
-				return err
-			}
+			batch.putUTXO(utxo)
 		}
 	}
 
-	return c.blockStore.Put(b)
+	// the evidence of the block goes last, the stake its txs bonded
+	// gets burned as well
+	if err := c.slash(batch, undo, b, node.height); err != nil {
+		return err
+	}
+	// staking changes take effect once their epoch is over
+	if c.staking.epochEnd(node.height) {
+		validators, err := c.snapshotValidators(batch, b, node.height)
+		if err != nil {
+			return err
+		}
+		undo.Validators = validators
+	}
+
+	batch.putUndo(node.hash, undo)
+	if commit != nil {
+		batch.putCommit(node.hash, commit)
+	}
+	batch.setBestBlock(node.hash)
+	if err := batch.commit(); err != nil {
+		return err
+	}
+
+	// Add the block header to the header list of the chain
+	c.headers.Add(b.Header)
+	node.setNextValidators(undo)
+	c.tip = node
+	c.includeEvidence(b, node.height)
+	c.includeCommissions(b, node.height)
+	return nil
+}
+
+// DisconnectTip removes the tip from the main chain and restores the
+// UTXO set as it was before the block got connected. The block stays
+// known to the block tree.
+func (c *Chain) DisconnectTip() (*proto.Block, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.tip.parent == nil {
+		return nil, fmt.Errorf("can not disconnect the genesis block")
+	}
+	if c.tip == c.finalized {
+		return nil, fmt.Errorf("can not disconnect the finalized block %s", c.tip.hash)
+	}
+	b, err := c.blockStore.Get(c.tip.hash)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.disconnectBlock(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// disconnectBlock reverts the changes the tip made to the UTXO set with
+// the help of its undo record and removes its header from the main chain.
+func (c *Chain) disconnectBlock() error {
+	undo, err := c.blockStore.GetUndo(c.tip.hash)
+	if err != nil {
+		return err
+	}
+
+	batch := c.newBatch()
+	for _, utxo := range undo.Spent {
+		batch.putUTXO(utxo)
+	}
+	// outputs spent within the block itself are gone as well
+	for _, key := range undo.Created {
+		batch.deleteUTXO(key)
+	}
+
+	batch.setBestBlock(c.tip.parent.hash)
+	if err := batch.commit(); err != nil {
+		return err
+	}
+
+	c.headers.Pop()
+	c.excludeEvidence(c.tip.height)
+	c.excludeCommissions(c.tip.height)
+	c.tip = c.tip.parent
+	return nil
 }
 
 func (c *Chain) GetBlockByHash(hash []byte) (*proto.Block, error) {
@@ -123,9 +536,21 @@ func (c *Chain) GetBlockByHash(hash []byte) (*proto.Block, error) {
 	return c.blockStore.Get(hashHex)
 }
 
+// GetCommit returns the commit of the block with the given hash, blocks
+// that were not decided by the consensus have none.
+func (c *Chain) GetCommit(hash []byte) (*proto.Commit, error) {
+	return c.blockStore.GetCommit(hex.EncodeToString(hash))
+}
+
 func (c *Chain) GetBlockByHeight(height int) (*proto.Block, error) {
-	if height > c.Height() {
-		return nil, fmt.Errorf("given height (%d) too high - height (%d)", height, c.Height())
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.getBlockByHeight(height)
+}
+
+func (c *Chain) getBlockByHeight(height int) (*proto.Block, error) {
+	if height > c.headers.Height() {
+		return nil, fmt.Errorf("given height (%d) too high - height (%d)", height, c.headers.Height())
 	}
 
 	header := c.headers.Get(height)
@@ -133,81 +558,374 @@ func (c *Chain) GetBlockByHeight(height int) (*proto.Block, error) {
 	return c.GetBlockByHash(hash)
 }
 
-func (c *Chain) ValidateBlock(b *proto.Block) error {
-	currentBlock, err := c.GetBlockByHeight(c.Height())
+// GetHeaders returns the headers of the main chain in the inclusive
+// range [from, to], clamped to the current height.
+func (c *Chain) GetHeaders(from, to int) []*proto.Header {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	if from < 0 {
+		from = 0
+	}
+	if to > c.headers.Height() {
+		to = c.headers.Height()
+	}
+
+	headers := []*proto.Header{}
+	for i := from; i <= to; i++ {
+		headers = append(headers, c.headers.Get(i))
+	}
+	return headers
+}
+
+// GetUnspent returns the unspent outputs paying to the given address.
+func (c *Chain) GetUnspent(address []byte) ([]*UTXO, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.utxoStore.ListByAddress(hex.EncodeToString(address))
+}
+
+// GetBalance returns the sum of the unspent outputs paying to the
+// given address.
+func (c *Chain) GetBalance(address []byte) (int64, error) {
+	utxos, err := c.GetUnspent(address)
 	if err != nil {
+		return 0, err
+	}
+	var balance int64
+	for _, utxo := range utxos {
+		balance += utxo.Amount
+	}
+	return balance, nil
+}
+
+// GetTransaction returns a tx of the main chain together with the fee
+// it paid.
+func (c *Chain) GetTransaction(hash []byte) (*proto.Transaction, int64, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	tx, err := c.txStore.Get(hex.EncodeToString(hash))
+	if err != nil {
+		return nil, 0, err
+	}
+	// coinbase and genesis txs don't pay fees
+	if len(tx.Inputs) == 0 {
+		return tx, 0, nil
+	}
+
+	var fee int64
+	for _, input := range tx.Inputs {
+		// spent outputs stay in the store
+		utxo, err := c.utxoStore.Get(outpointKey(input))
+		if err != nil {
+			return nil, 0, err
+		}
+		fee += utxo.Amount
+	}
+	for _, output := range tx.Outputs {
+		fee -= output.Amount
+	}
+	return tx, fee, nil
+}
+
+func (c *Chain) ValidateBlock(b *proto.Block) error {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.validateBlock(b)
+}
+
+// validateBlock checks the block extends the tip and is valid on top of
+// it.
+func (c *Chain) validateBlock(b *proto.Block) error {
+	if err := c.validateHeader(b); err != nil {
 		return err
 	}
+	slot := c.timestampSlot(b)
+	return c.validateState(b, slot, slot)
+}
+
+// validateHeader validates the block on its own and checks it extends
+// the tip.
+func (c *Chain) validateHeader(b *proto.Block) error {
+	if b.Header == nil {
+		return fmt.Errorf("block has no header")
+	}
+	hash := hex.EncodeToString(types.HashBlock(b))
+	if c.onMainChain(hash) {
+		return fmt.Errorf("block %s already known", hash)
+	}
+	if b.Header.ChainID != c.chainID {
+		return fmt.Errorf("block %s of chain %q", hash, b.Header.ChainID)
+	}
 	// Validate the signature of the block
 	if !types.VerifyBlock(b) {
 		return fmt.Errorf("invalid block signature")
 	}
 
-	// Validate if the prevHash is the actual hash of the current block
-	hash := types.HashBlock(currentBlock)
-	if !bytes.Equal(hash, b.Header.PrevHash) {
-		return fmt.Errorf("invalid previous block hash")
+	// Blocks decided by the consensus are final, so the main chain
+	// only grows on top of the tip
+	parent := c.tip
+	if hex.EncodeToString(b.Header.PrevHash) != parent.hash {
+		return fmt.Errorf("block %s does not extend the tip", hash)
 	}
+	if int(b.Header.Height) != parent.height+1 {
+		return fmt.Errorf("invalid block height (%d) expected (%d)", b.Header.Height, parent.height+1)
+	}
+	// The timestamp picks the slot, and with it the proposer of the
+	// block. Proposers can't claim later slots ahead of time.
+	if b.Header.Timestamp <= parent.header.Timestamp {
+		return fmt.Errorf("block timestamp (%d) not after its parent (%d)", b.Header.Timestamp, parent.header.Timestamp)
+	}
+	if limit := time.Now().Add(maxClockDrift).UnixNano(); b.Header.Timestamp > limit {
+		return fmt.Errorf("block timestamp (%d) too far in the future", b.Header.Timestamp)
+	}
+	return nil
+}
 
-	for _, tx := range b.Transactions {
-		if err := c.ValidateTransaction(tx); err != nil {
+// validateState validates the block proposed in one of the slots from
+// to to against the state of the chain, the block has to extend the
+// tip.
+func (c *Chain) validateState(b *proto.Block, from, to uint64) error {
+	if err := c.validateValidatorsHash(b); err != nil {
+		return err
+	}
+	if err := c.validateProposer(b, from, to); err != nil {
+		return err
+	}
+	if err := c.validateBlockEvidence(b); err != nil {
+		return err
+	}
+	return c.validateTransactions(b)
+}
+
+func (c *Chain) validateTransactions(b *proto.Block) error {
+	// Outputs spent and created by earlier txs of the same block, the
+	// committed UTXO set doesn't know about them yet.
+	view := newUTXOOverlay(c.utxoStore)
+	var (
+		coinbase *proto.Transaction
+		fees     int64
+	)
+	for i, tx := range b.Transactions {
+		if types.IsCoinbase(tx) {
+			if i != 0 {
+				return fmt.Errorf("coinbase tx at position %d, expected first", i)
+			}
+			coinbase = tx
+			view.apply(tx, c.outputUTXOs(tx, int(b.Header.Height)))
+			continue
+		}
+		fee, err := c.validateTransaction(tx, view)
+		if err != nil {
 			return err
 		}
-		// for _, input := range tx.Inputs {
-		// }
+		fees += fee
+		view.apply(tx, c.outputUTXOs(tx, int(b.Header.Height)))
+	}
+	// The coinbase can only be checked once the fees of the block are known
+	if coinbase != nil {
+		signer := crypto.PublicKeyFromBytes(b.PublicKey).Address()
+		return c.validateCoinbase(coinbase, int(b.Header.Height), fees, signer.String())
 	}
-
 	return nil
 }
 
 func (c *Chain) ValidateTransaction(tx *proto.Transaction) error {
+	_, err := c.TransactionFee(tx)
+	return err
+}
+
+// TransactionFee validates tx against the UTXO set and returns the fee
+// it pays, the amount of its inputs not spent by its outputs.
+func (c *Chain) TransactionFee(tx *proto.Transaction) (int64, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.validateTransaction(tx, c.utxoStore)
+}
+
+// PendingTransactionFee is TransactionFee for a tx that may also spend
+// outputs found in pending, the outputs of unconfirmed txs.
+func (c *Chain) PendingTransactionFee(tx *proto.Transaction, pending UTXOView) (int64, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.validateTransaction(tx, fallbackView{view: c.utxoStore, pending: pending})
+}
+
+// FilterTransactions returns the txs of txx that are valid in a block on
+// top of the tip, when included in the given order, and the fees they
+// pay. The errors of the txs left out are returned by their hash.
+func (c *Chain) FilterTransactions(txx []*proto.Transaction) ([]*proto.Transaction, int64, map[string]error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	var (
+		view    = newUTXOOverlay(c.utxoStore)
+		valid   = []*proto.Transaction{}
+		fees    int64
+		invalid = make(map[string]error)
+	)
+	for _, tx := range txx {
+		fee, err := c.validateTransaction(tx, view)
+		if err != nil {
+			invalid[hex.EncodeToString(types.HashTransaction(tx))] = err
+			continue
+		}
+		view.apply(tx, c.outputUTXOs(tx, c.tip.height+1))
+		valid = append(valid, tx)
+		fees += fee
+	}
+	return valid, fees, invalid
+}
+
+// validateCoinbase checks the coinbase tx of the block at the given
+// height does not mint more than the block reward plus the fees paid
+// by the other txs of the block. Its first outputs have to pay the
+// delegators of the signer of the block their share, see
+// delegatorRewards, the rest has to pay the signer.
+func (c *Chain) validateCoinbase(tx *proto.Transaction, height int, fees int64, signer string) error {
+	if len(tx.Inputs) != 0 {
+		return fmt.Errorf("coinbase tx has inputs")
+	}
+	if int(tx.Height) != height {
+		return fmt.Errorf("invalid coinbase height (%d) expected (%d)", tx.Height, height)
+	}
+	if tx.ChainID != c.chainID {
+		return fmt.Errorf("coinbase tx of chain %q", tx.ChainID)
+	}
+
+	var (
+		sumOutputs int64
+		ok         bool
+	)
+	for _, output := range tx.Outputs {
+		if output.Amount < 0 {
+			return fmt.Errorf("negative coinbase output amount (%d)", output.Amount)
+		}
+		if sumOutputs, ok = addAmount(sumOutputs, output.Amount); !ok {
+			return fmt.Errorf("coinbase outputs overflow")
+		}
+	}
+	reward := c.rewards.Reward(height)
+	if sumOutputs > reward+fees {
+		return fmt.Errorf("coinbase claims (%d) exceeding the block reward (%d) and fees (%d)", sumOutputs, reward, fees)
+	}
+
+	shares, err := c.delegatorRewards(signer, sumOutputs)
+	if err != nil {
+		return err
+	}
+	if len(tx.Outputs) < len(shares) {
+		return fmt.Errorf("coinbase pays (%d) delegators, expected (%d)", len(tx.Outputs), len(shares))
+	}
+	for i, share := range shares {
+		output := tx.Outputs[i]
+		if output.Amount != share.Amount || !bytes.Equal(output.Address, share.Address) {
+			return fmt.Errorf("coinbase output %d does not pay delegator %s its share (%d)", i, hex.EncodeToString(share.Address), share.Amount)
+		}
+	}
+	for i := len(shares); i < len(tx.Outputs); i++ {
+		if hex.EncodeToString(tx.Outputs[i].Address) != signer {
+			return fmt.Errorf("coinbase output %d does not pay the proposer %s", i, signer)
+		}
+	}
+	return nil
+}
+
+// validateTransaction validates tx against the outputs of view and
+// returns its fee.
+func (c *Chain) validateTransaction(tx *proto.Transaction, view UTXOView) (int64, error) {
+	if types.IsCoinbase(tx) {
+		return 0, fmt.Errorf("coinbase tx outside of a block")
+	}
+	// The signatures of a tx of another chain are no proof of anything
+	if tx.ChainID != c.chainID {
+		return 0, fmt.Errorf("tx %s of chain %q", hex.EncodeToString(types.HashTransaction(tx)), tx.ChainID)
+	}
 	// Verify the signature
 	if !types.VerifyTransaction(tx) {
-		return fmt.Errorf("invalid tx signature")
+		return 0, fmt.Errorf("invalid tx signature")
 	}
 	// Check if all the inputs(which has previous tx hash) are unspent
 	nInputs := len(tx.Inputs)
 	txHash := hex.EncodeToString(types.HashTransaction(tx))
 
-	sumInputs := 0
+	var (
+		sumInputs int64
+		ok        bool
+		seen      = make(map[string]bool, nInputs)
+		spends    = make([]*UTXO, nInputs)
+	)
 	for i := 0; i < nInputs; i++ {
-		prevHash := hex.EncodeToString(tx.Inputs[i].PrevTxHash)
 		// set the key of map[string]*UTXO
-		key := fmt.Sprintf("%s_%d", prevHash, i)
-		utxo, err := c.utxoStore.Get(key)
-		sumInputs += int(utxo.Amount)
+		key := outpointKey(tx.Inputs[i])
+		if seen[key] {
+			return 0, fmt.Errorf("input %d of tx %s spends %s twice", i, txHash, key)
+		}
+		seen[key] = true
+		utxo, err := view.Get(key)
 		if err != nil {
-			return err
+			return 0, &missingInputError{key: key}
+		}
+		if sumInputs, ok = addAmount(sumInputs, utxo.Amount); !ok {
+			return 0, fmt.Errorf("inputs of tx %s overflow", txHash)
 		}
 		if utxo.Spent {
-			return fmt.Errorf("input %d of tx %s is already spent", i, txHash)
+			return 0, fmt.Errorf("input %d of tx %s is already spent", i, txHash)
 		}
+		// Only the owner of the output can spend it
+		owner := crypto.PublicKeyFromBytes(tx.Inputs[i].PublicKey).Address()
+		if owner.String() != utxo.Address {
+			return 0, fmt.Errorf("input %d of tx %s is not owned by the signer", i, txHash)
+		}
+		spends[i] = utxo
+	}
+	// txs are validated for the block on top of the tip
+	if err := c.validateStaking(tx, spends, c.tip.height+1); err != nil {
+		return 0, err
 	}
 
-	sumOutputs := 0
+	var sumOutputs int64
 	for _, output := range tx.Outputs {
-		sumOutputs += int(output.Amount)
+		if output.Amount < 0 {
+			return 0, fmt.Errorf("negative output amount (%d) in tx %s", output.Amount, txHash)
+		}
+		if sumOutputs, ok = addAmount(sumOutputs, output.Amount); !ok {
+			return 0, fmt.Errorf("outputs of tx %s overflow", txHash)
+		}
 	}
 
 	if sumInputs < sumOutputs {
-		return fmt.Errorf("insufficient balance got (%d) spending (%d)", sumInputs, sumOutputs)
+		return 0, fmt.Errorf("insufficient balance got (%d) spending (%d)", sumInputs, sumOutputs)
 	}
 
-	return nil
+	// Whatever the outputs don't spend is the fee
+	return sumInputs - sumOutputs, nil
+}
+
+// addAmount adds two non negative amounts and reports whether the sum
+// fits into an int64.
+func addAmount(sum, amount int64) (int64, bool) {
+	if amount > math.MaxInt64-sum {
+		return 0, false
+	}
+	return sum + amount, true
 }
 
-func createGenesisBlock() *proto.Block {
+func createGenesisBlock(chainID string) *proto.Block {
 	// private key seed
 	privKey := crypto.NewPrivateKeyFromSeedStr(godSeed)
 
 	block := &proto.Block{
 		Header: &proto.Header{
 			Version: 1,
+			ChainID: chainID,
 		},
 	}
 
 	tx := &proto.Transaction{
 		Version: 1,
+		ChainID: chainID,
 		Inputs:  []*proto.TxInput{},
 		Outputs: []*proto.TxOutput{
 			{
@@ -216,8 +934,20 @@ func createGenesisBlock() *proto.Block {
 			},
 		},
 	}
+	// Bonds the stake of the first validator
+	stake := &proto.Transaction{
+		Version: 1,
+		ChainID: chainID,
+		Type:    proto.TxType_STAKE,
+		Outputs: []*proto.TxOutput{
+			{
+				Amount:  DefaultStakingParams.MinStake,
+				Address: privKey.Public().Address().Bytes(),
+			},
+		},
+	}
 
-	block.Transactions = append(block.Transactions, tx)
+	block.Transactions = append(block.Transactions, tx, stake)
 	types.SignBlock(privKey, block)
 
 	return block
@@ -1,6 +1,8 @@
 package node
 
 import (
+	"encoding/hex"
+	"math"
 	"testing"
 
 	"github.com/s809616134/go-blocker/crypto"
@@ -11,25 +13,35 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+func newTestChain(t *testing.T) *Chain {
+	chain, err := NewChain(DefaultChainID, NewMemoryBlockStore(), NewMemoryTXStore(), NewMemoryUTXOStore())
+	require.Nil(t, err)
+	return chain
+}
+
 func randomBLock(t *testing.T, chain *Chain) *proto.Block {
-	privKey := crypto.GeneratPrivateKey()
+	privKey := GenesisValidatorKey()
 	b := util.RandomBlock()
 	prevBlock, err := chain.GetBlockByHeight(chain.Height())
 	require.Nil(t, err)
 	b.Header.PrevHash = types.HashBlock(prevBlock)
+	b.Header.Height = prevBlock.Header.Height + 1
+	b.Header.ValidatorsHash = chain.ValidatorsHash()
+	b.Header.ChainID = chain.ChainID()
 	types.SignBlock(privKey, b)
 	return b
 }
 
 func TestNewChain(t *testing.T) {
-	chain := NewChain(NewMemoryBlockStore(), NewMemoryTXStore())
+	chain, err := NewChain(DefaultChainID, NewMemoryBlockStore(), NewMemoryTXStore(), NewMemoryUTXOStore())
+	require.Nil(t, err)
 	assert.Equal(t, 0, chain.Height())
-	_, err := chain.GetBlockByHeight(0)
+	_, err = chain.GetBlockByHeight(0)
 	assert.Nil(t, err)
 }
 
 func TestChainHeight(t *testing.T) {
-	chain := NewChain(NewMemoryBlockStore(), NewMemoryTXStore())
+	chain := newTestChain(t)
 	for i := 0; i < 100; i++ {
 		b := randomBLock(t, chain)
 		require.Nil(t, chain.AddBlock(b))
@@ -38,7 +50,7 @@ func TestChainHeight(t *testing.T) {
 }
 
 func TestAddBlock(t *testing.T) {
-	chain := NewChain(NewMemoryBlockStore(), NewMemoryTXStore())
+	chain := newTestChain(t)
 
 	for i := 0; i < 100; i++ {
 		block := randomBLock(t, chain)
@@ -59,7 +71,7 @@ func TestAddBlock(t *testing.T) {
 
 func TestAddBlockWithTxInsufficientFunds(t *testing.T) {
 	var (
-		chain     = NewChain(NewMemoryBlockStore(), NewMemoryTXStore())
+		chain     = newTestChain(t)
 		block     = randomBLock(t, chain)
 		privKey   = crypto.NewPrivateKeyFromSeedStr(godSeed)
 		recipient = crypto.GeneratPrivateKey().Public().Address().Bytes()
@@ -67,7 +79,7 @@ func TestAddBlockWithTxInsufficientFunds(t *testing.T) {
 
 	// our address got 1000 from genesis block
 	// this is the genesis block tx hash
-	prevTx, err := chain.txStore.Get("35cbbf7481d21f7763864d7632f1c24f07d11ff34cedb6fad79d65b39b05b995")
+	prevTx, err := chain.txStore.Get("6d6434e926ddde9452341ea6f9ddae3238b46537301058a1f3601184689f382c")
 	assert.Nil(t, err)
 
 	// get the 1000 tokens from genesis output
@@ -87,6 +99,7 @@ func TestAddBlockWithTxInsufficientFunds(t *testing.T) {
 	}
 	tx := &proto.Transaction{
 		Version: 1,
+		ChainID: DefaultChainID,
 		Inputs:  inputs,
 		Outputs: outputs,
 	}
@@ -100,7 +113,7 @@ func TestAddBlockWithTxInsufficientFunds(t *testing.T) {
 
 func TestAddBlockWithTx(t *testing.T) {
 	var (
-		chain     = NewChain(NewMemoryBlockStore(), NewMemoryTXStore())
+		chain     = newTestChain(t)
 		block     = randomBLock(t, chain)
 		privKey   = crypto.NewPrivateKeyFromSeedStr(godSeed)
 		recipient = crypto.GeneratPrivateKey().Public().Address().Bytes()
@@ -108,7 +121,7 @@ func TestAddBlockWithTx(t *testing.T) {
 
 	// our address got 1000 from genesis block
 	// this is the genesis block tx hash
-	prevTx, err := chain.txStore.Get("35cbbf7481d21f7763864d7632f1c24f07d11ff34cedb6fad79d65b39b05b995")
+	prevTx, err := chain.txStore.Get("6d6434e926ddde9452341ea6f9ddae3238b46537301058a1f3601184689f382c")
 	assert.Nil(t, err)
 
 	inputs := []*proto.TxInput{
@@ -132,6 +145,7 @@ func TestAddBlockWithTx(t *testing.T) {
 	}
 	tx := &proto.Transaction{
 		Version: 1,
+		ChainID: DefaultChainID,
 		Inputs:  inputs,
 		Outputs: outputs,
 	}
@@ -143,3 +157,575 @@ func TestAddBlockWithTx(t *testing.T) {
 	types.SignBlock(privKey, block)
 	require.Nil(t, chain.AddBlock(block))
 }
+
+func TestGetHeaders(t *testing.T) {
+	chain := newTestChain(t)
+	for i := 0; i < 10; i++ {
+		require.Nil(t, chain.AddBlock(randomBLock(t, chain)))
+	}
+
+	headers := chain.GetHeaders(3, 5)
+	require.Equal(t, 3, len(headers))
+	for i, header := range headers {
+		block, err := chain.GetBlockByHeight(3 + i)
+		require.Nil(t, err)
+		assert.Equal(t, block.Header, header)
+	}
+
+	// the range is clamped to the height of the chain
+	assert.Equal(t, 3, len(chain.GetHeaders(8, 100)))
+	assert.Equal(t, 0, len(chain.GetHeaders(11, 20)))
+}
+
+// randomBlockWithParent returns a block on top of parent, the validator
+// set of the chain has to be the one of parent.
+func randomBlockWithParent(t *testing.T, chain *Chain, parent *proto.Block) *proto.Block {
+	privKey := GenesisValidatorKey()
+	b := util.RandomBlock()
+	b.Header.PrevHash = types.HashBlock(parent)
+	b.Header.Height = parent.Header.Height + 1
+	b.Header.ValidatorsHash = chain.ValidatorsHash()
+	b.Header.ChainID = chain.ChainID()
+	types.SignBlock(privKey, b)
+	return b
+}
+
+func spendGenesisTx(t *testing.T, chain *Chain, amount int64) *proto.Transaction {
+	privKey := crypto.NewPrivateKeyFromSeedStr(godSeed)
+	genesis, err := chain.GetBlockByHeight(0)
+	require.Nil(t, err)
+
+	tx := &proto.Transaction{
+		Version: 1,
+		ChainID: DefaultChainID,
+		Inputs: []*proto.TxInput{
+			{
+				PrevTxHash:   types.HashTransaction(genesis.Transactions[0]),
+				PrevOutIndex: 0,
+				PublicKey:    privKey.Public().Bytes(),
+			},
+		},
+		Outputs: []*proto.TxOutput{
+			{
+				Amount:  amount,
+				Address: crypto.GeneratPrivateKey().Public().Address().Bytes(),
+			},
+		},
+	}
+	tx.Inputs[0].Signature = types.SignTransaction(privKey, tx).Bytes()
+	return tx
+}
+
+func TestAddBlockSideBranch(t *testing.T) {
+	var (
+		chain   = newTestChain(t)
+		genesis = createGenesisBlock(DefaultChainID)
+	)
+	for i := 0; i < 2; i++ {
+		require.Nil(t, chain.AddBlock(randomBLock(t, chain)))
+	}
+	tip, err := chain.GetBlockByHeight(2)
+	require.Nil(t, err)
+
+	// the main chain never forks, blocks have to extend the tip
+	side := randomBlockWithParent(t, chain, genesis)
+	err = chain.AddBlock(side)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "does not extend the tip")
+	assert.False(t, chain.HasBlock(types.HashBlock(side)))
+
+	assert.Equal(t, 2, chain.Height())
+	fetched, err := chain.GetBlockByHeight(2)
+	require.Nil(t, err)
+	assert.Equal(t, tip, fetched)
+}
+
+func openDiskStores(t *testing.T, dir string) (*DiskBlockStore, *DiskTXStore, *DiskUTXOStore) {
+	blockStore, err := NewDiskBlockStore(dir)
+	require.Nil(t, err)
+	txStore, err := NewDiskTXStore(dir)
+	require.Nil(t, err)
+	utxoStore, err := NewDiskUTXOStore(dir)
+	require.Nil(t, err)
+	return blockStore, txStore, utxoStore
+}
+
+func TestNewChainReloadFromDisk(t *testing.T) {
+	dir := t.TempDir()
+	blockStore, txStore, utxoStore := openDiskStores(t, dir)
+	chain, err := NewChain(DefaultChainID, blockStore, txStore, utxoStore)
+	require.Nil(t, err)
+
+	tx := spendGenesisTx(t, chain, 1000)
+	block := randomBLock(t, chain)
+	block.Transactions = append(block.Transactions, tx)
+	types.SignBlock(GenesisValidatorKey(), block)
+	require.Nil(t, chain.AddBlock(block))
+	for i := 0; i < 5; i++ {
+		require.Nil(t, chain.AddBlock(randomBLock(t, chain)))
+	}
+	tip, err := chain.GetBlockByHeight(6)
+	require.Nil(t, err)
+
+	require.Nil(t, blockStore.Close())
+	require.Nil(t, txStore.Close())
+	require.Nil(t, utxoStore.Close())
+
+	blockStore, txStore, utxoStore = openDiskStores(t, dir)
+	chain, err = NewChain(DefaultChainID, blockStore, txStore, utxoStore)
+	require.Nil(t, err)
+
+	assert.Equal(t, 6, chain.Height())
+	fetched, err := chain.GetBlockByHeight(6)
+	require.Nil(t, err)
+	assert.Equal(t, types.HashBlock(tip), types.HashBlock(fetched))
+	// the genesis output stays spent
+	assert.NotNil(t, chain.ValidateTransaction(tx))
+	require.Nil(t, chain.AddBlock(randomBLock(t, chain)))
+
+	// the undo records survived the restart
+	for chain.Height() > 0 {
+		_, err := chain.DisconnectTip()
+		require.Nil(t, err)
+	}
+	assert.Nil(t, chain.ValidateTransaction(tx))
+}
+
+func TestDisconnectTip(t *testing.T) {
+	var (
+		chain = newTestChain(t)
+		tx    = spendGenesisTx(t, chain, 1000)
+		block = randomBLock(t, chain)
+	)
+	block.Transactions = append(block.Transactions, tx)
+	types.SignBlock(GenesisValidatorKey(), block)
+	require.Nil(t, chain.AddBlock(block))
+	require.NotNil(t, chain.ValidateTransaction(tx))
+
+	undo, err := chain.blockStore.GetUndo(hex.EncodeToString(types.HashBlock(block)))
+	require.Nil(t, err)
+	require.Equal(t, 1, len(undo.Spent))
+	assert.False(t, undo.Spent[0].Spent)
+	require.Equal(t, 1, len(undo.Created))
+
+	disconnected, err := chain.DisconnectTip()
+	require.Nil(t, err)
+	assert.Equal(t, block, disconnected)
+	assert.Equal(t, 0, chain.Height())
+
+	// the genesis output can be spent again, the output of the tx is gone
+	assert.Nil(t, chain.ValidateTransaction(tx))
+	_, err = chain.utxoStore.Get(undo.Created[0])
+	assert.NotNil(t, err)
+
+	_, err = chain.DisconnectTip()
+	assert.NotNil(t, err)
+
+	// the chain can be extended again from the new tip
+	require.Nil(t, chain.AddBlock(randomBLock(t, chain)))
+	assert.Equal(t, 1, chain.Height())
+}
+
+func TestGetBalance(t *testing.T) {
+	var (
+		chain   = newTestChain(t)
+		privKey = crypto.NewPrivateKeyFromSeedStr(godSeed)
+		address = privKey.Public().Address().Bytes()
+	)
+
+	// the genesis block also bonds the stake of the genesis validator
+	stake := DefaultStakingParams.MinStake
+	balance, err := chain.GetBalance(address)
+	require.Nil(t, err)
+	assert.Equal(t, 1000+stake, balance)
+
+	// send 100 away and 900 back to ourselves in two outputs
+	tx := spendGenesisTx(t, chain, 100)
+	tx.Outputs = append(tx.Outputs,
+		&proto.TxOutput{Amount: 400, Address: address},
+		&proto.TxOutput{Amount: 500, Address: address},
+	)
+	tx.Inputs[0].Signature = types.SignTransaction(privKey, tx).Bytes()
+
+	block := randomBLock(t, chain)
+	block.Transactions = append(block.Transactions, tx)
+	types.SignBlock(privKey, block)
+	require.Nil(t, chain.AddBlock(block))
+
+	balance, err = chain.GetBalance(address)
+	require.Nil(t, err)
+	assert.Equal(t, 900+stake, balance)
+
+	unspent, err := chain.GetUnspent(address)
+	require.Nil(t, err)
+	utxos := []*UTXO{}
+	for _, utxo := range unspent {
+		if !utxo.Bonded {
+			utxos = append(utxos, utxo)
+		}
+	}
+	require.Equal(t, 2, len(utxos))
+	txHash := hex.EncodeToString(types.HashTransaction(tx))
+	for i, utxo := range utxos {
+		assert.Equal(t, txHash, utxo.Hash)
+		assert.Equal(t, i+1, utxo.OutIndex)
+		assert.Equal(t, hex.EncodeToString(address), utxo.Address)
+	}
+
+	recipient := tx.Outputs[0].Address
+	balance, err = chain.GetBalance(recipient)
+	require.Nil(t, err)
+	assert.Equal(t, int64(100), balance)
+
+	// the balance follows the chain when the block is disconnected
+	_, err = chain.DisconnectTip()
+	require.Nil(t, err)
+	balance, err = chain.GetBalance(address)
+	require.Nil(t, err)
+	assert.Equal(t, 1000+stake, balance)
+	balance, err = chain.GetBalance(recipient)
+	require.Nil(t, err)
+	assert.Equal(t, int64(0), balance)
+}
+
+func TestValidateTransactionTheft(t *testing.T) {
+	var (
+		chain   = newTestChain(t)
+		owner   = crypto.NewPrivateKeyFromSeedStr(godSeed)
+		thief   = crypto.GeneratPrivateKey()
+		genesis = createGenesisBlock(DefaultChainID)
+	)
+
+	// the thief signs for the genesis output with its own key
+	tx := &proto.Transaction{
+		Version: 1,
+		ChainID: DefaultChainID,
+		Inputs: []*proto.TxInput{
+			{
+				PrevTxHash:   types.HashTransaction(genesis.Transactions[0]),
+				PrevOutIndex: 0,
+				PublicKey:    thief.Public().Bytes(),
+			},
+		},
+		Outputs: []*proto.TxOutput{
+			{
+				Amount:  1000,
+				Address: thief.Public().Address().Bytes(),
+			},
+		},
+	}
+	tx.Inputs[0].Signature = types.SignTransaction(thief, tx).Bytes()
+	err := chain.ValidateTransaction(tx)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "not owned")
+
+	block := randomBLock(t, chain)
+	block.Transactions = append(block.Transactions, tx)
+	types.SignBlock(thief, block)
+	require.NotNil(t, chain.AddBlock(block))
+
+	// claiming to be the owner without its private key does not work either
+	tx.Inputs[0].PublicKey = owner.Public().Bytes()
+	tx.Inputs[0].Signature = types.SignTransaction(thief, tx).Bytes()
+	require.NotNil(t, chain.ValidateTransaction(tx))
+
+	// the owner can spend it
+	tx.Inputs[0].Signature = types.SignTransaction(owner, tx).Bytes()
+	require.Nil(t, chain.ValidateTransaction(tx))
+}
+
+func TestAddBlockDoubleSpend(t *testing.T) {
+	var (
+		chain = newTestChain(t)
+		tx1   = spendGenesisTx(t, chain, 1000)
+		tx2   = spendGenesisTx(t, chain, 500)
+	)
+	// each tx is valid on its own
+	require.Nil(t, chain.ValidateTransaction(tx1))
+	require.Nil(t, chain.ValidateTransaction(tx2))
+
+	block := randomBLock(t, chain)
+	block.Transactions = append(block.Transactions, tx1, tx2)
+	types.SignBlock(GenesisValidatorKey(), block)
+	err := chain.AddBlock(block)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "already spent")
+	assert.Equal(t, 0, chain.Height())
+}
+
+func TestValidateTransactionDuplicateInput(t *testing.T) {
+	var (
+		chain   = newTestChain(t)
+		privKey = crypto.NewPrivateKeyFromSeedStr(godSeed)
+		tx      = spendGenesisTx(t, chain, 1000)
+	)
+	// spend the genesis output twice to double the input amount
+	tx.Inputs = append(tx.Inputs, &proto.TxInput{
+		PrevTxHash:   tx.Inputs[0].PrevTxHash,
+		PrevOutIndex: tx.Inputs[0].PrevOutIndex,
+		PublicKey:    privKey.Public().Bytes(),
+	})
+	tx.Outputs[0].Amount = 2000
+	for _, input := range tx.Inputs {
+		input.Signature = nil
+	}
+	sig := types.SignTransaction(privKey, tx).Bytes()
+	for _, input := range tx.Inputs {
+		input.Signature = sig
+	}
+	err := chain.ValidateTransaction(tx)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "twice")
+}
+
+func TestValidateTransactionOutputOverflow(t *testing.T) {
+	var (
+		chain   = newTestChain(t)
+		privKey = crypto.NewPrivateKeyFromSeedStr(godSeed)
+		tx      = spendGenesisTx(t, chain, math.MaxInt64)
+	)
+	// the outputs wrap around to 0, the fee would be the whole input
+	tx.Outputs = append(tx.Outputs,
+		&proto.TxOutput{Amount: math.MaxInt64, Address: tx.Outputs[0].Address},
+		&proto.TxOutput{Amount: 2, Address: tx.Outputs[0].Address},
+	)
+	tx.Inputs[0].Signature = types.SignTransaction(privKey, tx).Bytes()
+	_, err := chain.TransactionFee(tx)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "overflow")
+}
+
+func TestAddBlockCoinbase(t *testing.T) {
+	var (
+		chain = newTestChain(t)
+		// the coinbase pays the signer of the block
+		address = GenesisValidatorKey().Public().Address()
+	)
+	chain.SetRewardSchedule(RewardSchedule{Initial: 50})
+	before, err := chain.GetBalance(address.Bytes())
+	require.Nil(t, err)
+
+	// minting more than the reward
+	block := randomBLock(t, chain)
+	block.Transactions = append(block.Transactions, types.NewCoinbaseTransaction(DefaultChainID, 1, 51, address.Bytes()))
+	types.SignBlock(GenesisValidatorKey(), block)
+	require.NotNil(t, chain.AddBlock(block))
+
+	// outputs wrapping around to 0
+	block = randomBLock(t, chain)
+	coinbase := types.NewCoinbaseTransaction(DefaultChainID, 1, math.MaxInt64, address.Bytes())
+	coinbase.Outputs = append(coinbase.Outputs,
+		&proto.TxOutput{Amount: math.MaxInt64, Address: address.Bytes()},
+		&proto.TxOutput{Amount: 2, Address: address.Bytes()},
+	)
+	block.Transactions = append(block.Transactions, coinbase)
+	types.SignBlock(GenesisValidatorKey(), block)
+	err = chain.AddBlock(block)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "overflow")
+
+	// a coinbase paying someone else
+	block = randomBLock(t, chain)
+	block.Transactions = append(block.Transactions, types.NewCoinbaseTransaction(DefaultChainID, 1, 50, crypto.GeneratPrivateKey().Public().Address().Bytes()))
+	types.SignBlock(GenesisValidatorKey(), block)
+	err = chain.AddBlock(block)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "does not pay the proposer")
+
+	// a coinbase of another chain
+	block = randomBLock(t, chain)
+	block.Transactions = append(block.Transactions, types.NewCoinbaseTransaction("other", 1, 50, address.Bytes()))
+	types.SignBlock(GenesisValidatorKey(), block)
+	err = chain.AddBlock(block)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "of chain")
+
+	// a coinbase that is not the first tx
+	block = randomBLock(t, chain)
+	block.Transactions = append(block.Transactions,
+		spendGenesisTx(t, chain, 1000),
+		types.NewCoinbaseTransaction(DefaultChainID, 1, 50, address.Bytes()),
+	)
+	types.SignBlock(GenesisValidatorKey(), block)
+	require.NotNil(t, chain.AddBlock(block))
+
+	// a coinbase for another height
+	block = randomBLock(t, chain)
+	block.Transactions = append(block.Transactions, types.NewCoinbaseTransaction(DefaultChainID, 2, 50, address.Bytes()))
+	types.SignBlock(GenesisValidatorKey(), block)
+	require.NotNil(t, chain.AddBlock(block))
+
+	block = randomBLock(t, chain)
+	coinbase = types.NewCoinbaseTransaction(DefaultChainID, 1, 50, address.Bytes())
+	block.Transactions = append(block.Transactions, coinbase)
+	types.SignBlock(GenesisValidatorKey(), block)
+	require.Nil(t, chain.AddBlock(block))
+
+	balance, err := chain.GetBalance(address.Bytes())
+	require.Nil(t, err)
+	assert.Equal(t, before+50, balance)
+
+	// coinbase txs are not valid on their own
+	require.NotNil(t, chain.ValidateTransaction(coinbase))
+}
+
+func TestAddBlockCoinbaseClaimsFees(t *testing.T) {
+	var (
+		chain   = newTestChain(t)
+		address = GenesisValidatorKey().Public().Address()
+		tx      = spendGenesisTx(t, chain, 900)
+	)
+	chain.SetRewardSchedule(RewardSchedule{Initial: 50})
+	before, err := chain.GetBalance(address.Bytes())
+	require.Nil(t, err)
+
+	fee, err := chain.TransactionFee(tx)
+	require.Nil(t, err)
+	assert.Equal(t, int64(100), fee)
+
+	// claiming more than the reward and the fees
+	block := randomBLock(t, chain)
+	block.Transactions = append(block.Transactions, types.NewCoinbaseTransaction(DefaultChainID, 1, 151, address.Bytes()), tx)
+	types.SignBlock(GenesisValidatorKey(), block)
+	require.NotNil(t, chain.AddBlock(block))
+
+	block = randomBLock(t, chain)
+	block.Transactions = append(block.Transactions, types.NewCoinbaseTransaction(DefaultChainID, 1, 150, address.Bytes()), tx)
+	types.SignBlock(GenesisValidatorKey(), block)
+	require.Nil(t, chain.AddBlock(block))
+
+	// the genesis output got spent
+	balance, err := chain.GetBalance(address.Bytes())
+	require.Nil(t, err)
+	assert.Equal(t, before-1000+150, balance)
+
+	fetched, fee, err := chain.GetTransaction(types.HashTransaction(tx))
+	require.Nil(t, err)
+	assert.Equal(t, types.HashTransaction(tx), types.HashTransaction(fetched))
+	assert.Equal(t, int64(100), fee)
+
+	_, fee, err = chain.GetTransaction(types.HashTransaction(block.Transactions[0]))
+	require.Nil(t, err)
+	assert.Equal(t, int64(0), fee)
+}
+
+// spendGenesisChain returns a tx spending the genesis output and a tx
+// spending the output of the first one.
+func spendGenesisChain(t *testing.T, chain *Chain) (*proto.Transaction, *proto.Transaction) {
+	var (
+		genesisKey = crypto.NewPrivateKeyFromSeedStr(godSeed)
+		privKey    = crypto.GeneratPrivateKey()
+	)
+	genesis, err := chain.GetBlockByHeight(0)
+	require.Nil(t, err)
+
+	parent := &proto.Transaction{
+		Version: 1,
+		ChainID: DefaultChainID,
+		Inputs: []*proto.TxInput{
+			{
+				PrevTxHash: types.HashTransaction(genesis.Transactions[0]),
+				PublicKey:  genesisKey.Public().Bytes(),
+			},
+		},
+		Outputs: []*proto.TxOutput{
+			{
+				Amount:  900,
+				Address: privKey.Public().Address().Bytes(),
+			},
+		},
+	}
+	parent.Inputs[0].Signature = types.SignTransaction(genesisKey, parent).Bytes()
+
+	child := &proto.Transaction{
+		Version: 1,
+		ChainID: DefaultChainID,
+		Inputs: []*proto.TxInput{
+			{
+				PrevTxHash: types.HashTransaction(parent),
+				PublicKey:  privKey.Public().Bytes(),
+			},
+		},
+		Outputs: []*proto.TxOutput{
+			{
+				Amount:  800,
+				Address: crypto.GeneratPrivateKey().Public().Address().Bytes(),
+			},
+		},
+	}
+	child.Inputs[0].Signature = types.SignTransaction(privKey, child).Bytes()
+	return parent, child
+}
+
+func TestAddBlockWithChainedTxs(t *testing.T) {
+	var (
+		chain         = newTestChain(t)
+		parent, child = spendGenesisChain(t, chain)
+	)
+	// the child can't come before its parent
+	block := randomBLock(t, chain)
+	block.Transactions = append(block.Transactions, child, parent)
+	types.SignBlock(GenesisValidatorKey(), block)
+	require.NotNil(t, chain.AddBlock(block))
+
+	valid, fees, invalid := chain.FilterTransactions([]*proto.Transaction{child, parent})
+	assert.Equal(t, []*proto.Transaction{parent}, valid)
+	assert.Equal(t, int64(100), fees)
+	assert.Len(t, invalid, 1)
+
+	block = randomBLock(t, chain)
+	block.Transactions = append(block.Transactions, parent, child)
+	types.SignBlock(GenesisValidatorKey(), block)
+	require.Nil(t, chain.AddBlock(block))
+
+	balance, err := chain.GetBalance(child.Outputs[0].Address)
+	require.Nil(t, err)
+	assert.Equal(t, int64(800), balance)
+}
+
+func TestChainID(t *testing.T) {
+	chain := newTestChain(t)
+	assert.Equal(t, DefaultChainID, chain.ChainID())
+
+	// blocks and txs of another chain
+	block := randomBLock(t, chain)
+	block.Header.ChainID = "testnet"
+	types.SignBlock(GenesisValidatorKey(), block)
+	err := chain.AddBlock(block)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "testnet")
+
+	tx := spendGenesisTx(t, chain, 1000)
+	tx.ChainID = "testnet"
+	tx.Inputs[0].Signature = types.SignTransaction(GenesisValidatorKey(), tx).Bytes()
+	err = chain.ValidateTransaction(tx)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "testnet")
+
+	// every chain starts with a genesis block of its own
+	other, err := NewChain("testnet", NewMemoryBlockStore(), NewMemoryTXStore(), NewMemoryUTXOStore())
+	require.Nil(t, err)
+	genesis, err := chain.GetBlockByHeight(0)
+	require.Nil(t, err)
+	otherGenesis, err := other.GetBlockByHeight(0)
+	require.Nil(t, err)
+	assert.NotEqual(t, types.HashBlock(genesis), types.HashBlock(otherGenesis))
+}
+
+func TestChainIDReloadFromDisk(t *testing.T) {
+	dir := t.TempDir()
+	blockStore, txStore, utxoStore := openDiskStores(t, dir)
+	_, err := NewChain(DefaultChainID, blockStore, txStore, utxoStore)
+	require.Nil(t, err)
+	require.Nil(t, blockStore.Close())
+	require.Nil(t, txStore.Close())
+	require.Nil(t, utxoStore.Close())
+
+	blockStore, txStore, utxoStore = openDiskStores(t, dir)
+	defer blockStore.Close()
+	defer txStore.Close()
+	defer utxoStore.Close()
+	_, err = NewChain("testnet", blockStore, txStore, utxoStore)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), DefaultChainID)
+}
@@ -0,0 +1,174 @@
+package node
+
+import (
+	"context"
+	"encoding/hex"
+	"time"
+
+	"github.com/s809616134/go-blocker/consensus"
+	"github.com/s809616134/go-blocker/proto"
+	"github.com/s809616134/go-blocker/types"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// consensusBackend connects the consensus state of the node to its chain
+// and its peers.
+type consensusBackend struct {
+	n *Node
+}
+
+func (b consensusBackend) ChainID() string {
+	return b.n.chain.ChainID()
+}
+
+func (b consensusBackend) Height() int32 {
+	return int32(b.n.chain.Height())
+}
+
+func (b consensusBackend) ValidatorSet() (consensus.ValidatorSet, error) {
+	return b.n.chain.ValidatorSet()
+}
+
+func (b consensusBackend) CreateBlock() (*proto.Block, error) {
+	return b.n.createBlock(time.Now().UnixNano(), b.n.mempool.Select(maxBlockSize))
+}
+
+func (b consensusBackend) ValidateBlock(block *proto.Block, round int32) error {
+	return b.n.chain.ValidateProposal(block, round)
+}
+
+func (b consensusBackend) Commit(block *proto.Block, commit *proto.Commit) error {
+	if err := b.n.chain.CommitBlock(block, commit); err != nil {
+		return err
+	}
+	b.n.announceBlock(block)
+	return nil
+}
+
+func (b consensusBackend) Broadcast(msg any) {
+	// our own messages coming back from our peers are not relayed again
+	switch m := msg.(type) {
+	case *proto.Proposal:
+		b.n.markMessageSeen(m.Block.Header.Height, proposalKey(m))
+	case *proto.Vote:
+		b.n.markMessageSeen(m.Height, voteKey(m))
+	}
+	go func() {
+		if err := b.n.broadcast(msg); err != nil {
+			b.n.logger.Errorw("broadcast error", "err", err)
+		}
+	}()
+}
+
+func (n *Node) HandleProposal(ctx context.Context, p *proto.Proposal) (*proto.Ack, error) {
+	if p.Block == nil || p.Block.Header == nil {
+		return nil, status.Error(codes.InvalidArgument, "proposal without a block")
+	}
+	var (
+		height = p.Block.Header.Height
+		key    = proposalKey(p)
+	)
+	if n.isMessageSeen(height, key) {
+		return &proto.Ack{}, nil
+	}
+	// a second proposal of the round gets rejected below, but not
+	// before we got the evidence out of it
+	n.checkDoubleSign(p)
+	if err := n.consensus.HandleProposal(p); err != nil {
+		return nil, n.consensusError(ctx, err)
+	}
+	if n.markMessageSeen(height, key) {
+		n.relayConsensusMessage(p)
+	}
+	return &proto.Ack{}, nil
+}
+
+func (n *Node) HandleVote(ctx context.Context, v *proto.Vote) (*proto.Ack, error) {
+	key := voteKey(v)
+	if n.isMessageSeen(v.Height, key) {
+		return &proto.Ack{}, nil
+	}
+	if err := n.consensus.HandleVote(v); err != nil {
+		n.checkConflictingVote(err)
+		return nil, n.consensusError(ctx, err)
+	}
+	if n.markMessageSeen(v.Height, key) {
+		n.relayConsensusMessage(v)
+	}
+	return &proto.Ack{}, nil
+}
+
+// consensusError logs a rejected consensus message. A message of a
+// height we can't decide yet means we are behind, so we catch up with
+// our peers.
+func (n *Node) consensusError(ctx context.Context, err error) error {
+	if err == consensus.ErrFutureHeight {
+		go n.sync()
+		return err
+	}
+	peer, _ := peer.FromContext(ctx)
+	n.logger.Debugw("rejected consensus message", "from", peer.Addr, "err", err)
+	return err
+}
+
+// relayConsensusMessage passes the proposals and votes we accepted on,
+// so nodes that are not connected to every validator receive them too.
+func (n *Node) relayConsensusMessage(msg any) {
+	go func() {
+		if err := n.broadcast(msg); err != nil {
+			n.logger.Debugw("relaying consensus message failed", "err", err)
+		}
+	}()
+}
+
+// proposalKey and voteKey identify a signed message. The signature is
+// part of the key, so a copy with a forged signature doesn't keep the
+// original from being handled.
+func proposalKey(p *proto.Proposal) string {
+	return hex.EncodeToString(types.HashProposal(p)) + "_" + hex.EncodeToString(p.Signature)
+}
+
+func voteKey(v *proto.Vote) string {
+	return hex.EncodeToString(types.HashVote(v)) + "_" + hex.EncodeToString(v.Signature)
+}
+
+func (n *Node) isMessageSeen(height int32, key string) bool {
+	n.seenLock.Lock()
+	defer n.seenLock.Unlock()
+	return n.seenMessages[height][key]
+}
+
+// markMessageSeen records a proposal or vote of the given height the
+// consensus accepted and reports whether it was the first time.
+func (n *Node) markMessageSeen(height int32, key string) bool {
+	n.seenLock.Lock()
+	defer n.seenLock.Unlock()
+
+	if n.seenMessages[height] == nil {
+		n.seenMessages[height] = make(map[string]bool)
+	}
+	if n.seenMessages[height][key] {
+		return false
+	}
+	n.seenMessages[height][key] = true
+	return true
+}
+
+// pruneSeenMessages forgets the messages of the heights up to the block
+// that got connected, the consensus is done with them.
+func (n *Node) pruneSeenMessages(height int32) {
+	n.seenLock.Lock()
+	defer n.seenLock.Unlock()
+
+	for h := range n.seenMessages {
+		if h <= height {
+			delete(n.seenMessages, h)
+		}
+	}
+}
+
+func (n *Node) GetCommit(ctx context.Context, r *proto.BlockRequest) (*proto.Commit, error) {
+	return n.chain.GetCommit(r.Hash)
+}
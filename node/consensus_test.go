@@ -0,0 +1,143 @@
+package node
+
+import (
+	"testing"
+
+	"github.com/s809616134/go-blocker/crypto"
+	"github.com/s809616134/go-blocker/proto"
+	"github.com/s809616134/go-blocker/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// newCommit returns the commit of the block in round 0 with the
+// precommits of the given keys.
+func newCommit(b *proto.Block, keys ...*crypto.PrivateKey) *proto.Commit {
+	commit := &proto.Commit{
+		Height:    b.Header.Height,
+		BlockHash: types.HashBlock(b),
+	}
+	for _, key := range keys {
+		v := &proto.Vote{
+			Type:      proto.VoteType_PRECOMMIT,
+			Height:    commit.Height,
+			BlockHash: commit.BlockHash,
+			ChainID:   b.Header.ChainID,
+		}
+		types.SignVote(key, v)
+		commit.Precommits = append(commit.Precommits, v)
+	}
+	return commit
+}
+
+func TestCommitBlock(t *testing.T) {
+	chain := newTestChain(t)
+	genesis, err := chain.GetBlockByHeight(0)
+	require.Nil(t, err)
+
+	block := randomBLock(t, chain)
+	// precommits of someone that is not a validator
+	require.NotNil(t, chain.CommitBlock(block, newCommit(block, crypto.GeneratPrivateKey())))
+	// a commit of another block
+	other := randomBLock(t, chain)
+	require.NotNil(t, chain.CommitBlock(block, newCommit(other, GenesisValidatorKey())))
+
+	commit := newCommit(block, GenesisValidatorKey())
+	require.Nil(t, chain.CommitBlock(block, commit))
+	assert.Equal(t, 1, chain.Height())
+	stored, err := chain.GetCommit(types.HashBlock(block))
+	require.Nil(t, err)
+	assert.Equal(t, commit, stored)
+
+	// the block is final, no branch may fork below it
+	side := randomBlockWithParent(t, chain, genesis)
+	err = chain.AddBlock(side)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "does not extend the tip")
+	_, err = chain.DisconnectTip()
+	require.NotNil(t, err)
+
+	// blocks without a commit on top of it can still be disconnected
+	require.Nil(t, chain.AddBlock(randomBLock(t, chain)))
+	_, err = chain.DisconnectTip()
+	require.Nil(t, err)
+}
+
+func TestCommitBlockReloadFromDisk(t *testing.T) {
+	dir := t.TempDir()
+	blockStore, txStore, utxoStore := openDiskStores(t, dir)
+	chain, err := NewChain(DefaultChainID, blockStore, txStore, utxoStore)
+	require.Nil(t, err)
+
+	block := randomBLock(t, chain)
+	commit := newCommit(block, GenesisValidatorKey())
+	require.Nil(t, chain.CommitBlock(block, commit))
+	require.Nil(t, chain.AddBlock(randomBLock(t, chain)))
+
+	require.Nil(t, blockStore.Close())
+	require.Nil(t, txStore.Close())
+	require.Nil(t, utxoStore.Close())
+
+	blockStore, txStore, utxoStore = openDiskStores(t, dir)
+	chain, err = NewChain(DefaultChainID, blockStore, txStore, utxoStore)
+	require.Nil(t, err)
+
+	stored, err := chain.GetCommit(types.HashBlock(block))
+	require.Nil(t, err)
+	assert.Equal(t, types.HashVote(commit.Precommits[0]), types.HashVote(stored.Precommits[0]))
+
+	// the block is still final after the restart
+	_, err = chain.DisconnectTip()
+	require.Nil(t, err)
+	_, err = chain.DisconnectTip()
+	require.NotNil(t, err)
+}
+
+func TestValidateProposal(t *testing.T) {
+	chain := newTestChain(t)
+
+	block := randomBLock(t, chain)
+	require.Nil(t, chain.ValidateProposal(block, 0))
+	require.Nil(t, chain.ValidateProposal(block, 3))
+
+	// proposed by someone that is not a validator
+	types.SignBlock(crypto.GeneratPrivateKey(), block)
+	require.NotNil(t, chain.ValidateProposal(block, 0))
+}
+
+func TestHandleProposalWithoutBlock(t *testing.T) {
+	n, err := NewNode(ServerConfig{})
+	require.Nil(t, err)
+	require.Nil(t, n.consensus.Start())
+
+	for _, p := range []*proto.Proposal{{}, {Block: &proto.Block{}}} {
+		_, err = n.HandleProposal(peerContext(), p)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	}
+}
+
+func TestHandleVoteForgedCopy(t *testing.T) {
+	n, err := NewNode(ServerConfig{})
+	require.Nil(t, err)
+	require.Nil(t, n.consensus.Start())
+
+	vote := &proto.Vote{Type: proto.VoteType_PREVOTE, Height: 1, ChainID: DefaultChainID}
+	types.SignVote(GenesisValidatorKey(), vote)
+
+	// a copy with a forged signature doesn't keep the vote out
+	forged := *vote
+	forged.Signature = make([]byte, crypto.SignatureLen)
+	_, err = n.HandleVote(peerContext(), &forged)
+	require.NotNil(t, err)
+	assert.False(t, n.isMessageSeen(1, voteKey(&forged)))
+
+	_, err = n.HandleVote(peerContext(), vote)
+	require.Nil(t, err)
+	assert.True(t, n.isMessageSeen(1, voteKey(vote)))
+
+	// the messages of a height are gone once its block got connected
+	require.Nil(t, n.chain.AddBlock(randomBLock(t, n.chain)))
+	assert.False(t, n.isMessageSeen(1, voteKey(vote)))
+}
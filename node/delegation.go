@@ -0,0 +1,167 @@
+package node
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/s809616134/go-blocker/crypto"
+	"github.com/s809616134/go-blocker/proto"
+	"github.com/s809616134/go-blocker/types"
+)
+
+type Delegation struct {
+	// hex encoded addresses
+	Delegator string
+	Validator string
+	Amount    int64
+}
+
+// validateDelegate checks that the delegate tx signed by signer bonds its
+// stake to a validator other than the signer.
+func (c *Chain) validateDelegate(tx *proto.Transaction, signer crypto.Address) error {
+	txHash := hex.EncodeToString(types.HashTransaction(tx))
+	if len(tx.Validator) != crypto.AddressLen {
+		return fmt.Errorf("delegate tx %s has an invalid validator address", txHash)
+	}
+	validator := hex.EncodeToString(tx.Validator)
+	if validator == signer.String() {
+		return fmt.Errorf("delegate tx %s delegates to its signer, use a stake tx", txHash)
+	}
+	if !c.isValidator(validator) {
+		return fmt.Errorf("delegate tx %s delegates to %s, which is not a validator", txHash, validator)
+	}
+	return nil
+}
+
+// Delegations returns the stake bonded by delegator to validator at the
+// tip of the chain, sorted by delegator and validator. An empty address
+// matches any.
+func (c *Chain) Delegations(delegator, validator string) ([]*Delegation, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.delegations(delegator, validator)
+}
+
+func (c *Chain) delegations(delegator, validator string) ([]*Delegation, error) {
+	bonded, err := c.utxoStore.ListBonded()
+	if err != nil {
+		return nil, err
+	}
+	var (
+		byKey       = make(map[string]*Delegation)
+		delegations = []*Delegation{}
+	)
+	for _, utxo := range bonded {
+		// the own stake of a validator is no delegation
+		if utxo.Validator == utxo.Address {
+			continue
+		}
+		if (delegator != "" && utxo.Address != delegator) || (validator != "" && utxo.Validator != validator) {
+			continue
+		}
+		key := utxo.Address + "_" + utxo.Validator
+		d, ok := byKey[key]
+		if !ok {
+			d = &Delegation{
+				Delegator: utxo.Address,
+				Validator: utxo.Validator,
+			}
+			byKey[key] = d
+			delegations = append(delegations, d)
+		}
+		d.Amount += utxo.Amount
+	}
+	sort.Slice(delegations, func(i, j int) bool {
+		if delegations[i].Delegator == delegations[j].Delegator {
+			return delegations[i].Validator < delegations[j].Validator
+		}
+		return delegations[i].Delegator < delegations[j].Delegator
+	})
+	return delegations, nil
+}
+
+// DelegatorRewards returns the outputs paying the delegators of the
+// validator their share of the given rewards, see delegatorRewards.
+func (c *Chain) DelegatorRewards(validator crypto.Address, amount int64) ([]*proto.TxOutput, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.delegatorRewards(validator.String(), amount)
+}
+
+// delegatorRewards shares the rewards of a block of the validator: the
+// validator keeps its commission, the rest goes to the stake bonded to
+// it pro rata. The outputs pay the delegators, sorted by address, the
+// validator gets what is left, including whatever the rounding leaves.
+// Unlike the validator set, the shares follow the stake bonded at the
+// tip, so they always add up to the rewards. The commission is the one
+// of the validator set of the epoch.
+func (c *Chain) delegatorRewards(validator string, amount int64) ([]*proto.TxOutput, error) {
+	bonded, err := c.utxoStore.ListBonded()
+	if err != nil {
+		return nil, err
+	}
+	v, ok := bondedStakes(bonded)[validator]
+	outputs := []*proto.TxOutput{}
+	if !ok || amount <= 0 {
+		return outputs, nil
+	}
+	for _, val := range c.validators() {
+		if val.Address == validator {
+			v.Commission = val.Commission
+		}
+	}
+
+	delegations, err := c.delegations("", validator)
+	if err != nil {
+		return nil, err
+	}
+	shared := amount - mulDiv(amount, int64(v.Commission), 100)
+	for _, d := range delegations {
+		share := mulDiv(shared, d.Amount, v.Stake)
+		if share == 0 {
+			continue
+		}
+		address, err := hex.DecodeString(d.Delegator)
+		if err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, &proto.TxOutput{
+			Amount:  share,
+			Address: address,
+		})
+	}
+	return outputs, nil
+}
+
+// mulDiv returns a*b/c, rounded down. The product may overflow, the
+// result has to fit.
+func mulDiv(a, b, c int64) int64 {
+	x := new(big.Int).Mul(big.NewInt(a), big.NewInt(b))
+	return x.Quo(x, big.NewInt(c)).Int64()
+}
+
+// NewDelegateTransaction returns an unsigned tx of the chain delegating
+// amount of the given UTXOs, owned by privKey, to the validator. The
+// rest minus fee goes back to privKey as change.
+func NewDelegateTransaction(chainID string, privKey *crypto.PrivateKey, validator crypto.Address, utxos []*UTXO, amount, fee int64) (*proto.Transaction, error) {
+	tx, err := newStakingTransaction(proto.TxType_DELEGATE, chainID, privKey, utxos, amount, fee)
+	if err != nil {
+		return nil, err
+	}
+	tx.Validator = validator.Bytes()
+	return tx, nil
+}
+
+// NewUndelegateTransaction returns an unsigned tx of the chain
+// undelegating amount of the given UTXOs, owned by privKey and delegated
+// to the validator. The rest minus fee stays delegated.
+func NewUndelegateTransaction(chainID string, privKey *crypto.PrivateKey, validator crypto.Address, utxos []*UTXO, amount, fee int64) (*proto.Transaction, error) {
+	tx, err := newStakingTransaction(proto.TxType_UNDELEGATE, chainID, privKey, utxos, amount, fee)
+	if err != nil {
+		return nil, err
+	}
+	tx.Validator = validator.Bytes()
+	return tx, nil
+}
@@ -0,0 +1,376 @@
+package node
+
+import (
+	"context"
+	"encoding/hex"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/s809616134/go-blocker/crypto"
+	"github.com/s809616134/go-blocker/proto"
+	"github.com/s809616134/go-blocker/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fundAddress pays amount of the genesis output to the address in a new
+// block, the rest goes back to the genesis validator.
+func fundAddress(t *testing.T, chain *Chain, address crypto.Address, amount int64) {
+	tx := spendGenesisTx(t, chain, amount)
+	tx.Outputs[0].Address = address.Bytes()
+	tx.Outputs = append(tx.Outputs, &proto.TxOutput{
+		Amount:  1000 - amount,
+		Address: GenesisValidatorKey().Public().Address().Bytes(),
+	})
+	tx.Inputs[0].Signature = types.SignTransaction(GenesisValidatorKey(), tx).Bytes()
+	require.Nil(t, addSignedBlock(t, chain, GenesisValidatorKey(), tx))
+}
+
+// delegate delegates amount of the unspent outputs of privKey to the
+// validator in a new block.
+func delegate(t *testing.T, chain *Chain, privKey *crypto.PrivateKey, validator crypto.Address, amount int64) *proto.Transaction {
+	utxos, err := chain.GetUnspent(privKey.Public().Address().Bytes())
+	require.Nil(t, err)
+	tx, err := NewDelegateTransaction(DefaultChainID, privKey, validator, utxos, amount, 0)
+	require.Nil(t, err)
+	signInputs(privKey, tx)
+	require.Nil(t, addSignedBlock(t, chain, GenesisValidatorKey(), tx))
+	return tx
+}
+
+func TestDelegateAndUndelegate(t *testing.T) {
+	var (
+		chain     = newTestChain(t)
+		privKey   = crypto.GeneratPrivateKey()
+		address   = privKey.Public().Address()
+		validator = GenesisValidatorKey().Public().Address()
+	)
+	chain.SetStakingParams(StakingParams{
+		MinStake:        100,
+		UnbondingPeriod: 2,
+		SlotDuration:    time.Nanosecond,
+	})
+	fundAddress(t, chain, address, 500)
+
+	utxos, err := chain.GetUnspent(address.Bytes())
+	require.Nil(t, err)
+	// only validators take delegations
+	for _, to := range []crypto.Address{crypto.GeneratPrivateKey().Public().Address(), address} {
+		tx, err := NewDelegateTransaction(DefaultChainID, privKey, to, utxos, 200, 0)
+		require.Nil(t, err)
+		signInputs(privKey, tx)
+		require.NotNil(t, chain.ValidateTransaction(tx))
+	}
+
+	tx := delegate(t, chain, privKey, validator, 200)
+	validators, err := chain.Validators()
+	require.Nil(t, err)
+	require.Len(t, validators, 1)
+	assert.Equal(t, int64(300), validators[0].Stake)
+	assert.Equal(t, int64(100), validators[0].SelfStake)
+
+	// delegating doesn't make the delegator a validator
+	ok, err := chain.IsValidator(address)
+	require.Nil(t, err)
+	assert.False(t, ok)
+
+	delegations, err := chain.Delegations(address.String(), "")
+	require.Nil(t, err)
+	assert.Equal(t, []*Delegation{{Delegator: address.String(), Validator: validator.String(), Amount: 200}}, delegations)
+
+	delegated := &UTXO{
+		Hash:     hex.EncodeToString(types.HashTransaction(tx)),
+		OutIndex: 0,
+		Amount:   200,
+	}
+	// only an undelegate tx naming the validator spends the delegation
+	unstake, err := NewUnstakeTransaction(DefaultChainID, privKey, []*UTXO{delegated}, 200, 0)
+	require.Nil(t, err)
+	signInputs(privKey, unstake)
+	err = chain.ValidateTransaction(unstake)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "delegated")
+
+	undelegate, err := NewUndelegateTransaction(DefaultChainID, privKey, crypto.GeneratPrivateKey().Public().Address(), []*UTXO{delegated}, 200, 0)
+	require.Nil(t, err)
+	signInputs(privKey, undelegate)
+	err = chain.ValidateTransaction(undelegate)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "another validator")
+
+	// the rest stays delegated, the undelegated part gets locked
+	undelegate, err = NewUndelegateTransaction(DefaultChainID, privKey, validator, []*UTXO{delegated}, 50, 0)
+	require.Nil(t, err)
+	signInputs(privKey, undelegate)
+	require.Nil(t, addSignedBlock(t, chain, GenesisValidatorKey(), undelegate))
+
+	delegations, err = chain.Delegations("", validator.String())
+	require.Nil(t, err)
+	require.Len(t, delegations, 1)
+	assert.Equal(t, int64(150), delegations[0].Amount)
+
+	unspent, err := chain.GetUnspent(address.Bytes())
+	require.Nil(t, err)
+	for _, utxo := range unspent {
+		if utxo.Hash == hex.EncodeToString(types.HashTransaction(undelegate)) && utxo.OutIndex == 0 {
+			assert.False(t, utxo.Bonded)
+			assert.Equal(t, chain.Height()+2, utxo.LockHeight)
+		}
+	}
+}
+
+func TestDelegatorRewards(t *testing.T) {
+	n, err := NewNode(ServerConfig{PrivateKey: GenesisValidatorKey()})
+	require.Nil(t, err)
+	var (
+		chain     = n.chain
+		god       = GenesisValidatorKey()
+		validator = god.Public().Address()
+		privKey   = crypto.GeneratPrivateKey()
+		address   = privKey.Public().Address()
+	)
+	chain.SetRewardSchedule(RewardSchedule{Initial: 100})
+	chain.SetStakingParams(StakingParams{
+		MinStake:        100,
+		UnbondingPeriod: 2,
+		SlotDuration:    time.Nanosecond,
+	})
+	fundAddress(t, chain, address, 300)
+
+	// the validator bonds more stake and sets its commission
+	utxos, err := chain.GetUnspent(validator.Bytes())
+	require.Nil(t, err)
+	unbonded := []*UTXO{}
+	for _, utxo := range utxos {
+		if !utxo.Bonded {
+			unbonded = append(unbonded, utxo)
+		}
+	}
+	stake, err := NewStakeTransaction(DefaultChainID, god, unbonded, 100, 0)
+	require.Nil(t, err)
+	stake.Commission = 10
+	signInputs(god, stake)
+	require.Nil(t, addSignedBlock(t, chain, god, stake))
+	delegate(t, chain, privKey, validator, 200)
+
+	validators, err := chain.Validators()
+	require.Nil(t, err)
+	require.Len(t, validators, 1)
+	assert.Equal(t, 10, validators[0].Commission)
+	assert.Equal(t, int64(400), validators[0].Stake)
+
+	// 10 percent commission, half of the stake is delegated
+	shares, err := chain.DelegatorRewards(validator, 100)
+	require.Nil(t, err)
+	require.Len(t, shares, 1)
+	assert.Equal(t, int64(45), shares[0].Amount)
+	assert.Equal(t, address.Bytes(), shares[0].Address)
+
+	// the validator can't keep the rewards of its delegators
+	block := signedBlock(t, chain, god, types.NewCoinbaseTransaction(DefaultChainID, int32(chain.Height()+1), 100, validator.Bytes()))
+	err = chain.AddBlock(block)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "does not pay delegator")
+
+	block, err = n.createBlock(time.Now().UnixNano(), nil)
+	require.Nil(t, err)
+	require.Nil(t, chain.AddBlock(block))
+	balance, err := chain.GetBalance(address.Bytes())
+	require.Nil(t, err)
+	assert.Equal(t, int64(300+45), balance)
+
+	resp, err := n.GetDelegations(context.Background(), &proto.DelegationsRequest{Delegator: address.Bytes()})
+	require.Nil(t, err)
+	require.Len(t, resp.Delegations, 1)
+	assert.Equal(t, validator.Bytes(), resp.Delegations[0].Validator)
+	assert.Equal(t, int64(200), resp.Delegations[0].Amount)
+
+	list, err := n.GetValidators(context.Background(), &proto.ValidatorsRequest{})
+	require.Nil(t, err)
+	require.Len(t, list.Validators, 1)
+	assert.Equal(t, int32(10), list.Validators[0].Commission)
+	assert.Equal(t, int64(200), list.Validators[0].SelfStake)
+
+	// unstaking keeps the commission and can't set one
+	utxos, err = chain.GetUnspent(validator.Bytes())
+	require.Nil(t, err)
+	bonded := []*UTXO{}
+	for _, utxo := range utxos {
+		if utxo.Bonded {
+			bonded = append(bonded, utxo)
+		}
+	}
+	unstake, err := NewUnstakeTransaction(DefaultChainID, god, bonded, 50, 0)
+	require.Nil(t, err)
+	unstake.Commission = 20
+	signInputs(god, unstake)
+	err = chain.ValidateTransaction(unstake)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "sets a commission")
+
+	unstake.Commission = 0
+	signInputs(god, unstake)
+	require.Nil(t, addSignedBlock(t, chain, god, unstake))
+	validators, err = chain.Validators()
+	require.Nil(t, err)
+	require.Len(t, validators, 1)
+	assert.Equal(t, 10, validators[0].Commission)
+	assert.Equal(t, int64(150), validators[0].SelfStake)
+
+	// 10 percent commission, 200 of 350 delegated
+	shares, err = chain.DelegatorRewards(validator, 70)
+	require.Nil(t, err)
+	require.Len(t, shares, 1)
+	assert.Equal(t, int64(36), shares[0].Amount)
+}
+
+func TestMulDiv(t *testing.T) {
+	assert.Equal(t, int64(45), mulDiv(90, 200, 400))
+	// the product doesn't fit into an int64
+	assert.Equal(t, int64(math.MaxInt64/2), mulDiv(math.MaxInt64, 1<<40, 1<<41))
+	assert.Equal(t, int64(math.MaxInt64/3), mulDiv(math.MaxInt64, math.MaxInt64/4, 3*(math.MaxInt64/4)))
+}
+
+func TestCoinbaseProposedAgain(t *testing.T) {
+	var (
+		chain     = newTestChain(t)
+		god       = GenesisValidatorKey()
+		validator = god.Public().Address()
+		privKey   = crypto.GeneratPrivateKey()
+		address   = privKey.Public().Address()
+	)
+	chain.SetRewardSchedule(RewardSchedule{Initial: 100})
+	chain.SetStakingParams(StakingParams{
+		MinStake:        100,
+		UnbondingPeriod: 2,
+		SlotDuration:    time.Nanosecond,
+	})
+	// another validator delegating to god
+	fundAddress(t, chain, address, 500)
+	stakeAddress(t, chain, privKey, 200)
+	utxos, err := chain.GetUnspent(address.Bytes())
+	require.Nil(t, err)
+	unbonded := []*UTXO{}
+	for _, utxo := range utxos {
+		if !utxo.Bonded {
+			unbonded = append(unbonded, utxo)
+		}
+	}
+	tx, err := NewDelegateTransaction(DefaultChainID, privKey, validator, unbonded, 200, 0)
+	require.Nil(t, err)
+	signInputs(privKey, tx)
+	require.Nil(t, addSignedBlock(t, chain, god, tx))
+	ok, err := chain.IsValidator(address)
+	require.Nil(t, err)
+	require.True(t, ok)
+
+	// the block of god proposed again by privKey in a later round keeps
+	// the signature of god and pays the delegators of god
+	shares, err := chain.DelegatorRewards(validator, 100)
+	require.Nil(t, err)
+	require.Len(t, shares, 1)
+	coinbase := func(outputs ...*proto.TxOutput) *proto.Transaction {
+		tx := types.NewCoinbaseTransaction(DefaultChainID, int32(chain.Height()+1), 100-shares[0].Amount, validator.Bytes())
+		tx.Outputs = append(outputs, tx.Outputs...)
+		return tx
+	}
+	var first, again int32 = -1, -1
+	for round := int32(0); again < 0; round++ {
+		proposer, err := chain.proposer(uint64(round))
+		require.Nil(t, err)
+		if proposer.Address == validator.String() && first < 0 {
+			first = round
+		}
+		if proposer.Address == address.String() && first >= 0 {
+			again = round
+		}
+	}
+	block := signedBlock(t, chain, god, coinbase())
+	err = chain.ValidateProposal(block, again)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "does not pay delegator")
+
+	block = signedBlock(t, chain, god, coinbase(shares[0]))
+	require.Nil(t, chain.ValidateProposal(block, again))
+	// god did not propose before its round
+	if first > 0 {
+		err = chain.ValidateProposal(block, first-1)
+		require.NotNil(t, err)
+		assert.Contains(t, err.Error(), "is not the proposer")
+	}
+
+	// signed by privKey, the coinbase has to pay privKey
+	block = signedBlock(t, chain, privKey, coinbase(shares[0]))
+	err = chain.ValidateProposal(block, again)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "does not pay the proposer")
+}
+
+func TestSlashDelegations(t *testing.T) {
+	var (
+		chain   = newTestChain(t)
+		god     = GenesisValidatorKey()
+		privKey = crypto.GeneratPrivateKey()
+		address = privKey.Public().Address()
+	)
+	chain.SetStakingParams(StakingParams{
+		MinStake:        100,
+		UnbondingPeriod: 10,
+		SlotDuration:    time.Nanosecond,
+		JailPeriod:      10,
+	})
+	fundAddress(t, chain, address, 300)
+	delegate(t, chain, privKey, god.Public().Address(), 200)
+
+	// the stake delegated to the offender gets burned with its own
+	require.Nil(t, addEvidenceBlock(t, chain, god, doubleSign(god, int32(chain.Height()))))
+	balance, err := chain.GetBalance(address.Bytes())
+	require.Nil(t, err)
+	assert.Equal(t, int64(100), balance)
+	delegations, err := chain.Delegations(address.String(), "")
+	require.Nil(t, err)
+	assert.Empty(t, delegations)
+}
+
+func TestSlashUndelegating(t *testing.T) {
+	var (
+		chain   = newTestChain(t)
+		god     = GenesisValidatorKey()
+		privKey = crypto.GeneratPrivateKey()
+		address = privKey.Public().Address()
+		other   = crypto.GeneratPrivateKey().Public().Address()
+	)
+	chain.SetStakingParams(StakingParams{
+		MinStake:        100,
+		UnbondingPeriod: 10,
+		SlotDuration:    time.Nanosecond,
+		JailPeriod:      10,
+	})
+	fundAddress(t, chain, address, 300)
+	tx := delegate(t, chain, privKey, god.Public().Address(), 200)
+
+	// the delegation unbonding to another address gets burned as well
+	e := doubleSign(god, int32(chain.Height()))
+	delegated := &UTXO{
+		Hash:   hex.EncodeToString(types.HashTransaction(tx)),
+		Amount: 200,
+	}
+	undelegate, err := NewUndelegateTransaction(DefaultChainID, privKey, god.Public().Address(), []*UTXO{delegated}, 50, 0)
+	require.Nil(t, err)
+	undelegate.Outputs[0].Address = other.Bytes()
+	signInputs(privKey, undelegate)
+	require.Nil(t, addSignedBlock(t, chain, god, undelegate))
+
+	balance, err := chain.GetBalance(other.Bytes())
+	require.Nil(t, err)
+	assert.Equal(t, int64(50), balance)
+
+	require.Nil(t, addEvidenceBlock(t, chain, god, e))
+	balance, err = chain.GetBalance(other.Bytes())
+	require.Nil(t, err)
+	assert.Equal(t, int64(0), balance)
+	balance, err = chain.GetBalance(address.Bytes())
+	require.Nil(t, err)
+	assert.Equal(t, int64(100), balance)
+}
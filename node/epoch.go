@@ -0,0 +1,112 @@
+package node
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/s809616134/go-blocker/proto"
+	"github.com/s809616134/go-blocker/types"
+)
+
+// epochEnd reports whether the block at the given height is the last
+// one of its epoch. The genesis block ends the epoch before the first.
+func (p StakingParams) epochEnd(height int) bool {
+	return p.EpochLength <= 1 || height%p.EpochLength == 0
+}
+
+// snapshotValidators returns the validator set of the epoch following
+// the block at the given height, taken from the state the batch
+// connecting the block leaves behind.
+func (c *Chain) snapshotValidators(batch *batch, b *proto.Block, height int) ([]*Validator, error) {
+	bonded, err := batch.listBonded()
+	if err != nil {
+		return nil, err
+	}
+	// the evidence of the block is not included yet
+	jailed := c.jailed(height)
+	for _, e := range b.Evidence {
+		jailed[types.EvidenceOffender(e).String()] = true
+	}
+	validators := c.selectValidators(bonded, jailed)
+	// and neither are the commissions its stake txs set
+	set := setCommissions(b)
+	for _, v := range validators {
+		if commission, ok := set[v.Address]; ok {
+			v.Commission = commission
+		} else {
+			v.Commission = c.commission(v.Address)
+		}
+	}
+	return validators, nil
+}
+
+// setNextValidators sets the validator set deciding the block on top of
+// the node from the undo record of the block. Only the last block of an
+// epoch records a set, the other blocks keep the one of their parent.
+func (node *blockNode) setNextValidators(undo *BlockUndo) {
+	if undo.Validators != nil {
+		node.nextValidators = undo.Validators
+	} else if node.parent != nil {
+		node.nextValidators = node.parent.nextValidators
+	}
+}
+
+// ValidatorInfos returns the validators the way they get hashed and
+// served to light clients.
+func ValidatorInfos(validators []*Validator) []*proto.ValidatorInfo {
+	list := make([]*proto.ValidatorInfo, len(validators))
+	for i, v := range validators {
+		// we encoded the address ourselves
+		address, _ := hex.DecodeString(v.Address)
+		list[i] = &proto.ValidatorInfo{
+			Address:    address,
+			Stake:      v.Stake,
+			SelfStake:  v.SelfStake,
+			Commission: int32(v.Commission),
+		}
+	}
+	return list
+}
+
+// ValidatorsHash returns the hash of the validator set the header of
+// the block on top of the tip commits to.
+func (c *Chain) ValidatorsHash() []byte {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return types.HashValidators(ValidatorInfos(c.validators()))
+}
+
+// validateValidatorsHash checks the header of the block on top of the
+// tip commits to the validator set of its epoch.
+func (c *Chain) validateValidatorsHash(b *proto.Block) error {
+	expected := types.HashValidators(ValidatorInfos(c.validators()))
+	if !bytes.Equal(b.Header.ValidatorsHash, expected) {
+		return fmt.Errorf("invalid validators hash %s expected %s", hex.EncodeToString(b.Header.ValidatorsHash), hex.EncodeToString(expected))
+	}
+	return nil
+}
+
+// EpochValidators returns the validator set that decided the block of
+// the main chain at the given height, up to the block on top of the tip.
+func (c *Chain) EpochValidators(height int) ([]*Validator, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	validators, err := c.epochValidators(height)
+	if err != nil {
+		return nil, err
+	}
+	return copyValidators(validators), nil
+}
+
+func (c *Chain) epochValidators(height int) ([]*Validator, error) {
+	if height < 1 || height > c.tip.height+1 {
+		return nil, fmt.Errorf("no validator set for height (%d), chain height (%d)", height, c.tip.height)
+	}
+	node := c.tip
+	for node.height >= height {
+		node = node.parent
+	}
+	return node.nextValidators, nil
+}
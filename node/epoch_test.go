@@ -0,0 +1,143 @@
+package node
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/s809616134/go-blocker/crypto"
+	"github.com/s809616134/go-blocker/proto"
+	"github.com/s809616134/go-blocker/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var epochParams = StakingParams{
+	MinStake:        100,
+	UnbondingPeriod: 2,
+	SlotDuration:    time.Nanosecond,
+	EpochLength:     3,
+}
+
+// stakeAddress bonds amount of the unspent outputs of privKey in a new
+// block.
+func stakeAddress(t *testing.T, chain *Chain, privKey *crypto.PrivateKey, amount int64) *proto.Transaction {
+	utxos, err := chain.GetUnspent(privKey.Public().Address().Bytes())
+	require.Nil(t, err)
+	stake, err := NewStakeTransaction(DefaultChainID, privKey, utxos, amount, 0)
+	require.Nil(t, err)
+	signInputs(privKey, stake)
+	require.Nil(t, addSignedBlock(t, chain, GenesisValidatorKey(), stake))
+	return stake
+}
+
+func TestEpochValidators(t *testing.T) {
+	var (
+		chain   = newTestChain(t)
+		privKey = crypto.GeneratPrivateKey()
+		address = privKey.Public().Address()
+	)
+	chain.SetStakingParams(epochParams)
+	genesisHash := chain.ValidatorsHash()
+	fundAddress(t, chain, address, 500)
+	stakeAddress(t, chain, privKey, 100)
+
+	// the stake takes effect once the epoch is over
+	ok, err := chain.IsValidator(address)
+	require.Nil(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, genesisHash, chain.ValidatorsHash())
+	require.NotNil(t, addSignedBlock(t, chain, privKey))
+
+	require.Nil(t, addSignedBlock(t, chain, GenesisValidatorKey()))
+	assert.Equal(t, 3, chain.Height())
+	validators, err := chain.Validators()
+	require.Nil(t, err)
+	require.Len(t, validators, 2)
+	assert.NotEqual(t, genesisHash, chain.ValidatorsHash())
+
+	// the sets of the blocks before stay the ones of their epoch
+	validators, err = chain.EpochValidators(3)
+	require.Nil(t, err)
+	require.Len(t, validators, 1)
+	validators, err = chain.EpochValidators(4)
+	require.Nil(t, err)
+	require.Len(t, validators, 2)
+	_, err = chain.EpochValidators(5)
+	require.NotNil(t, err)
+
+	// a header has to commit to the set of its epoch
+	block := signedBlock(t, chain, privKey)
+	block.Header.ValidatorsHash = genesisHash
+	types.SignBlock(privKey, block)
+	err = chain.AddBlock(block)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "validators hash")
+	require.Nil(t, addSignedBlock(t, chain, privKey))
+
+	// disconnecting the last block of the epoch brings its set back
+	for chain.Height() > 2 {
+		_, err = chain.DisconnectTip()
+		require.Nil(t, err)
+	}
+	assert.Equal(t, genesisHash, chain.ValidatorsHash())
+}
+
+func TestEpochValidatorsReloadFromDisk(t *testing.T) {
+	dir := t.TempDir()
+	blockStore, txStore, utxoStore := openDiskStores(t, dir)
+	chain, err := NewChain(DefaultChainID, blockStore, txStore, utxoStore)
+	require.Nil(t, err)
+	chain.SetStakingParams(epochParams)
+
+	privKey := crypto.GeneratPrivateKey()
+	fundAddress(t, chain, privKey.Public().Address(), 500)
+	require.Nil(t, addSignedBlock(t, chain, GenesisValidatorKey()))
+	require.Nil(t, addSignedBlock(t, chain, GenesisValidatorKey()))
+	// staked within the next epoch
+	stakeAddress(t, chain, privKey, 100)
+	hash := chain.ValidatorsHash()
+
+	require.Nil(t, blockStore.Close())
+	require.Nil(t, txStore.Close())
+	require.Nil(t, utxoStore.Close())
+
+	blockStore, txStore, utxoStore = openDiskStores(t, dir)
+	defer blockStore.Close()
+	defer txStore.Close()
+	defer utxoStore.Close()
+	chain, err = NewChain(DefaultChainID, blockStore, txStore, utxoStore)
+	require.Nil(t, err)
+	chain.SetStakingParams(epochParams)
+
+	assert.Equal(t, hash, chain.ValidatorsHash())
+	validators, err := chain.Validators()
+	require.Nil(t, err)
+	require.Len(t, validators, 1)
+	validators, err = chain.EpochValidators(1)
+	require.Nil(t, err)
+	require.Len(t, validators, 1)
+}
+
+func TestGetValidators(t *testing.T) {
+	n, err := NewNode(ServerConfig{PrivateKey: GenesisValidatorKey()})
+	require.Nil(t, err)
+
+	list, err := n.GetValidators(context.Background(), &proto.ValidatorsRequest{})
+	require.Nil(t, err)
+	require.Len(t, list.Validators, 1)
+	assert.Equal(t, GenesisValidatorKey().Public().Address().Bytes(), list.Validators[0].Address)
+	// light clients check the set against the headers
+	assert.Equal(t, n.chain.ValidatorsHash(), list.Hash)
+
+	block, err := n.createBlock(time.Now().UnixNano(), nil)
+	require.Nil(t, err)
+	assert.Equal(t, list.Hash, block.Header.ValidatorsHash)
+	require.Nil(t, n.chain.AddBlock(block))
+
+	list, err = n.GetValidators(context.Background(), &proto.ValidatorsRequest{Height: 1})
+	require.Nil(t, err)
+	assert.Equal(t, block.Header.ValidatorsHash, list.Hash)
+	_, err = n.GetValidators(context.Background(), &proto.ValidatorsRequest{Height: 3})
+	require.NotNil(t, err)
+}
@@ -0,0 +1,233 @@
+package node
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/s809616134/go-blocker/consensus"
+	"github.com/s809616134/go-blocker/crypto"
+	"github.com/s809616134/go-blocker/proto"
+	"github.com/s809616134/go-blocker/types"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxEvidenceAge is the number of blocks we keep the proposals of a
+// height around to find double signs in.
+const maxEvidenceAge = 100
+
+// maxEvidenceRounds is the number of rounds of a height we keep the
+// proposals of, a validator proposing in rounds nobody gets to doesn't
+// fill up the pool.
+const maxEvidenceRounds = 100
+
+// maxPendingEvidence is the number of evidence the pool keeps waiting
+// for a block, any more is dropped.
+const maxPendingEvidence = 1000
+
+// EvidencePool detects validators proposing two different blocks in the
+// same round and keeps the evidence until a block includes it, together
+// with the evidence of validators voting twice the consensus found.
+type EvidencePool struct {
+	lock sync.Mutex
+	// the headers proposed at a height by round and signer address
+	headers map[int32]map[int32]map[string]*proto.SignedHeader
+	// evidence waiting for a block by its hash
+	pending map[string]*proto.Evidence
+}
+
+func NewEvidencePool() *EvidencePool {
+	return &EvidencePool{
+		headers: make(map[int32]map[int32]map[string]*proto.SignedHeader),
+		pending: make(map[string]*proto.Evidence),
+	}
+}
+
+func (pool *EvidencePool) Len() int {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+	return len(pool.pending)
+}
+
+// CheckHeader records the signed header and returns the evidence against
+// its signer when it proposed another block in the same round before.
+// Only headers of the heights evidence can still be included for on
+// top of the tip at the given height are recorded. The signature of the
+// header has to be verified already.
+func (pool *EvidencePool) CheckHeader(h *proto.SignedHeader, tip int) (*proto.Evidence, bool) {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	var (
+		height = h.Header.Height
+		signer = crypto.PublicKeyFromBytes(h.PublicKey).Address().String()
+	)
+	if int(height) < tip-maxEvidenceAge || int(height) > tip+1 {
+		return nil, false
+	}
+	if pool.headers[height] == nil {
+		pool.headers[height] = make(map[int32]map[string]*proto.SignedHeader)
+	}
+	rounds := pool.headers[height]
+	if rounds[h.Round] == nil {
+		if len(rounds) >= maxEvidenceRounds {
+			return nil, false
+		}
+		rounds[h.Round] = make(map[string]*proto.SignedHeader)
+	}
+	prev, ok := rounds[h.Round][signer]
+	if !ok {
+		rounds[h.Round][signer] = h
+		return nil, false
+	}
+	if bytes.Equal(types.HashHeader(prev.Header), types.HashHeader(h.Header)) {
+		return nil, false
+	}
+	e := types.NewEvidence(prev, h)
+	if !pool.add(e) {
+		return nil, false
+	}
+	return e, true
+}
+
+// Add keeps verified evidence, e.g. received from a peer, and reports
+// whether it was new to the pool and the pool had room for it.
+func (pool *EvidencePool) Add(e *proto.Evidence) bool {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+	return pool.add(e)
+}
+
+func (pool *EvidencePool) add(e *proto.Evidence) bool {
+	hash := hex.EncodeToString(types.HashEvidence(e))
+	if _, ok := pool.pending[hash]; ok {
+		return false
+	}
+	if len(pool.pending) >= maxPendingEvidence {
+		return false
+	}
+	pool.pending[hash] = e
+	return true
+}
+
+// Pending returns the evidence waiting for a block, sorted by hash.
+func (pool *EvidencePool) Pending() []*proto.Evidence {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	hashes := make([]string, 0, len(pool.pending))
+	for hash := range pool.pending {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+	list := make([]*proto.Evidence, len(hashes))
+	for i, hash := range hashes {
+		list[i] = pool.pending[hash]
+	}
+	return list
+}
+
+// RemoveBlock drops the evidence the block included, as well as the
+// headers and evidence too old to get a validator convicted on top of
+// it.
+func (pool *EvidencePool) RemoveBlock(b *proto.Block) {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	for _, e := range b.Evidence {
+		delete(pool.pending, hex.EncodeToString(types.HashEvidence(e)))
+	}
+	oldest := b.Header.Height - maxEvidenceAge
+	for height := range pool.headers {
+		if height < oldest {
+			delete(pool.headers, height)
+		}
+	}
+	for hash, e := range pool.pending {
+		if types.EvidenceHeight(e) < oldest {
+			delete(pool.pending, hash)
+		}
+	}
+}
+
+// checkDoubleSign looks for another block the proposer of p proposed in
+// the same round and spreads the evidence when it finds one.
+func (n *Node) checkDoubleSign(p *proto.Proposal) {
+	if p.Block == nil || p.Block.Header == nil || !types.VerifyProposal(p) {
+		return
+	}
+	// nobody else can be convicted, so nobody else fills up the pool
+	signer := crypto.PublicKeyFromBytes(p.PublicKey).Address()
+	if ok, err := n.chain.IsValidator(signer); err != nil || !ok {
+		return
+	}
+	e, ok := n.evidence.CheckHeader(types.SignedHeaderOf(p), n.chain.Height())
+	if !ok {
+		return
+	}
+	n.logger.Infow("validator double signed",
+		"offender", signer,
+		"height", p.Block.Header.Height,
+		"round", p.Round)
+	n.relayEvidence(e)
+}
+
+// checkConflictingVote spreads the evidence against a validator the
+// consensus caught voting for two blocks in the same round.
+func (n *Node) checkConflictingVote(err error) {
+	var conflict *consensus.ConflictingVoteError
+	if !errors.As(err, &conflict) {
+		return
+	}
+	e := types.NewVoteEvidence(conflict.Prev, conflict.Vote)
+	if !n.evidence.Add(e) {
+		return
+	}
+	n.logger.Infow("validator double voted",
+		"offender", types.EvidenceOffender(e),
+		"height", conflict.Vote.Height,
+		"round", conflict.Vote.Round)
+	n.relayEvidence(e)
+}
+
+func (n *Node) HandleEvidence(ctx context.Context, e *proto.Evidence) (*proto.Ack, error) {
+	if err := n.checkEvidence(e); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "%s", err)
+	}
+	if n.evidence.Add(e) {
+		n.relayEvidence(e)
+	}
+	return &proto.Ack{}, nil
+}
+
+// checkEvidence checks evidence received from a peer can still convict
+// a validator, so only evidence worth a block fills up the pool.
+func (n *Node) checkEvidence(e *proto.Evidence) error {
+	if err := types.VerifyEvidence(e); err != nil {
+		return err
+	}
+	if chainID := types.EvidenceChainID(e); chainID != n.chain.ChainID() {
+		return fmt.Errorf("evidence of chain %q", chainID)
+	}
+	var (
+		height = int(types.EvidenceHeight(e))
+		tip    = n.chain.Height()
+	)
+	if height < tip-maxEvidenceAge || height > tip+1 {
+		return fmt.Errorf("evidence of height (%d) too far from the chain height (%d)", height, tip)
+	}
+	return n.chain.ValidateOffender(e)
+}
+
+func (n *Node) relayEvidence(e *proto.Evidence) {
+	go func() {
+		if err := n.broadcast(e); err != nil {
+			n.logger.Debugw("relaying evidence failed", "err", err)
+		}
+	}()
+}
@@ -0,0 +1,189 @@
+package node
+
+import (
+	"testing"
+	"time"
+
+	"github.com/s809616134/go-blocker/crypto"
+	"github.com/s809616134/go-blocker/proto"
+	"github.com/s809616134/go-blocker/types"
+	"github.com/s809616134/go-blocker/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestEvidencePoolCheckHeader(t *testing.T) {
+	var (
+		pool    = NewEvidencePool()
+		privKey = crypto.GeneratPrivateKey()
+		header  = proposedHeader(privKey, 5, 0)
+	)
+	_, ok := pool.CheckHeader(header, 4)
+	assert.False(t, ok)
+	// the same block proposed again, or another block in a later round
+	_, ok = pool.CheckHeader(header, 4)
+	assert.False(t, ok)
+	_, ok = pool.CheckHeader(proposedHeader(privKey, 5, 1), 4)
+	assert.False(t, ok)
+	// another proposer of the same height
+	_, ok = pool.CheckHeader(proposedHeader(crypto.GeneratPrivateKey(), 5, 0), 4)
+	assert.False(t, ok)
+
+	e, ok := pool.CheckHeader(proposedHeader(privKey, 5, 0), 4)
+	require.True(t, ok)
+	assert.Nil(t, types.VerifyEvidence(e))
+	assert.Equal(t, privKey.Public().Address(), types.EvidenceOffender(e))
+	assert.Equal(t, []*proto.Evidence{e}, pool.Pending())
+
+	// evidence received from a peer only counts once
+	assert.False(t, pool.Add(e))
+
+	block := util.RandomBlock()
+	block.Header.Height = 6
+	block.Evidence = []*proto.Evidence{e}
+	pool.RemoveBlock(block)
+	assert.Equal(t, 0, pool.Len())
+}
+
+func TestEvidencePoolExpire(t *testing.T) {
+	var (
+		pool    = NewEvidencePool()
+		privKey = crypto.GeneratPrivateKey()
+	)
+	assert.True(t, pool.Add(doubleSign(privKey, 1)))
+	_, ok := pool.CheckHeader(proposedHeader(privKey, 2, 0), 1)
+	assert.False(t, ok)
+
+	block := util.RandomBlock()
+	block.Header.Height = maxEvidenceAge + 2
+	pool.RemoveBlock(block)
+	assert.Equal(t, 0, pool.Len())
+	// the header of height 2 is still around
+	_, ok = pool.CheckHeader(proposedHeader(privKey, 2, 0), maxEvidenceAge+2)
+	assert.True(t, ok)
+}
+
+func TestEvidencePoolHeaderLimits(t *testing.T) {
+	var (
+		pool    = NewEvidencePool()
+		privKey = crypto.GeneratPrivateKey()
+		tip     = maxEvidenceAge + 10
+	)
+	// headers of heights no evidence can be included for any more, or
+	// not yet, are not recorded
+	for _, height := range []int{tip - maxEvidenceAge - 1, tip + 2} {
+		pool.CheckHeader(proposedHeader(privKey, int32(height), 0), tip)
+		_, ok := pool.CheckHeader(proposedHeader(privKey, int32(height), 0), tip)
+		assert.False(t, ok)
+	}
+	for _, height := range []int{tip - maxEvidenceAge, tip + 1} {
+		pool.CheckHeader(proposedHeader(privKey, int32(height), 0), tip)
+		_, ok := pool.CheckHeader(proposedHeader(privKey, int32(height), 0), tip)
+		assert.True(t, ok)
+	}
+
+	// neither are the rounds of a height past the limit
+	for round := int32(0); round < maxEvidenceRounds; round++ {
+		pool.CheckHeader(proposedHeader(privKey, int32(tip), round), tip)
+	}
+	pool.CheckHeader(proposedHeader(privKey, int32(tip), maxEvidenceRounds), tip)
+	_, ok := pool.CheckHeader(proposedHeader(privKey, int32(tip), maxEvidenceRounds), tip)
+	assert.False(t, ok)
+	_, ok = pool.CheckHeader(proposedHeader(privKey, int32(tip), maxEvidenceRounds-1), tip)
+	assert.True(t, ok)
+}
+
+func TestHandleProposalDoubleSign(t *testing.T) {
+	n, err := NewNode(ServerConfig{PrivateKey: crypto.GeneratPrivateKey()})
+	require.Nil(t, err)
+	require.Nil(t, n.consensus.Start())
+
+	propose := func() *proto.Proposal {
+		p := &proto.Proposal{
+			Block:    randomBLock(t, n.chain),
+			PolRound: -1,
+		}
+		types.SignProposal(GenesisValidatorKey(), p)
+		return p
+	}
+	n.HandleProposal(peerContext(), propose())
+	assert.Equal(t, 0, n.evidence.Len())
+	// the consensus only takes the first proposal of the round, the
+	// second one convicts the proposer
+	n.HandleProposal(peerContext(), propose())
+	require.Equal(t, 1, n.evidence.Len())
+
+	// the blocks on top of the height include the evidence
+	require.Nil(t, n.chain.AddBlock(randomBLock(t, n.chain)))
+	block, err := n.createBlock(time.Now().UnixNano(), nil)
+	require.Nil(t, err)
+	assert.Len(t, block.Evidence, 1)
+}
+
+func TestHandleVoteDoubleSign(t *testing.T) {
+	n, err := NewNode(ServerConfig{PrivateKey: crypto.GeneratPrivateKey()})
+	require.Nil(t, err)
+	require.Nil(t, n.consensus.Start())
+
+	prevote := func(hash []byte) *proto.Vote {
+		v := &proto.Vote{
+			Type:      proto.VoteType_PREVOTE,
+			Height:    1,
+			BlockHash: hash,
+			ChainID:   DefaultChainID,
+		}
+		types.SignVote(GenesisValidatorKey(), v)
+		return v
+	}
+	_, err = n.HandleVote(peerContext(), prevote(util.RandomHash()))
+	require.Nil(t, err)
+	assert.Equal(t, 0, n.evidence.Len())
+	// voting for no block after voting for one convicts the validator
+	_, err = n.HandleVote(peerContext(), prevote(nil))
+	require.NotNil(t, err)
+	require.Equal(t, 1, n.evidence.Len())
+	e := n.evidence.Pending()[0]
+	assert.Nil(t, n.checkEvidence(e))
+
+	// and the evidence gets it jailed once a block includes it
+	require.Nil(t, n.chain.AddBlock(randomBLock(t, n.chain)))
+	require.Nil(t, addEvidenceBlock(t, n.chain, GenesisValidatorKey(), e))
+	assert.Equal(t, []string{GenesisValidatorKey().Public().Address().String()}, n.chain.Jailed())
+}
+
+func TestHandleEvidence(t *testing.T) {
+	n, err := NewNode(ServerConfig{})
+	require.Nil(t, err)
+
+	e := doubleSign(GenesisValidatorKey(), 1)
+	e.A, e.B = e.B, e.A
+	_, err = n.HandleEvidence(peerContext(), e)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// only evidence able to convict a validator gets into the pool
+	for _, e := range []*proto.Evidence{
+		doubleSign(crypto.GeneratPrivateKey(), 1),
+		doubleSign(GenesisValidatorKey(), 2),
+		doubleSign(GenesisValidatorKey(), 0),
+	} {
+		_, err = n.HandleEvidence(peerContext(), e)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	}
+	assert.Equal(t, 0, n.evidence.Len())
+
+	e.A, e.B = e.B, e.A
+	_, err = n.HandleEvidence(peerContext(), e)
+	require.Nil(t, err)
+	assert.Equal(t, 1, n.evidence.Len())
+}
+
+func TestEvidencePoolFull(t *testing.T) {
+	pool := NewEvidencePool()
+	for i := 0; i < maxPendingEvidence; i++ {
+		require.True(t, pool.Add(doubleSign(GenesisValidatorKey(), int32(i))))
+	}
+	assert.False(t, pool.Add(doubleSign(GenesisValidatorKey(), maxPendingEvidence)))
+	assert.Equal(t, maxPendingEvidence, pool.Len())
+}
@@ -0,0 +1,520 @@
+package node
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	pb "github.com/golang/protobuf/proto"
+	"github.com/s809616134/go-blocker/proto"
+	"github.com/s809616134/go-blocker/types"
+)
+
+var errTxKnown = errors.New("tx already in the mempool")
+
+type MempoolConfig struct {
+	// Maximum number of txs in the pool
+	MaxTxs int
+	// Maximum size in bytes of all the txs in the pool
+	MaxBytes int
+	// Minimum fee per 1000 bytes a tx has to pay to get accepted
+	MinRelayFee int64
+	// Minimum fee per 1000 bytes a replacement has to pay on top
+	// of the fees of the txs it replaces
+	IncrementalRelayFee int64
+	// Maximum number and size in bytes of the unconfirmed txs a
+	// tx depends on, the tx itself included
+	MaxAncestors    int
+	MaxAncestorSize int
+	// Maximum number and size in bytes of the txs depending on an
+	// unconfirmed tx, the tx itself included
+	MaxDescendants    int
+	MaxDescendantSize int
+}
+
+var DefaultMempoolConfig = MempoolConfig{
+	MaxTxs:              5000,
+	MaxBytes:            16 << 20,
+	MinRelayFee:         1,
+	IncrementalRelayFee: 1,
+	MaxAncestors:        25,
+	MaxAncestorSize:     100000,
+	MaxDescendants:      25,
+	MaxDescendantSize:   100000,
+}
+
+// maximum number of txs a replacement may evict
+const maxReplacements = 100
+
+type mempoolEntry struct {
+	tx   *proto.Transaction
+	hash string
+	fee  int64
+	size int
+}
+
+// pays more per byte than other
+func (e *mempoolEntry) higherFeeRate(other *mempoolEntry) bool {
+	return e.fee*int64(other.size) > other.fee*int64(e.size)
+}
+
+// Mempool holds the txs waiting to be included in a block, ordered by
+// the fee they pay per byte. When the pool is full the txs paying the
+// lowest fee rate get evicted.
+//
+// Txs may spend the outputs of other txs in the pool, the txs they
+// depend on are their ancestors, the ones depending on them their
+// descendants.
+type Mempool struct {
+	lock sync.RWMutex
+	cfg  MempoolConfig
+	txx  map[string]*mempoolEntry
+	// all the entries, highest fee rate first
+	byFee []*mempoolEntry
+	size  int
+	// outpoint key => hash of the tx spending it, used to
+	// detect conflicting txs.
+	spends map[string]string
+}
+
+func NewMempool(cfg MempoolConfig) *Mempool {
+	return &Mempool{
+		cfg:    cfg,
+		txx:    make(map[string]*mempoolEntry),
+		spends: make(map[string]string),
+	}
+}
+
+// Transactions returns all the txs of the pool, highest fee rate first.
+func (pool *Mempool) Transactions() []*proto.Transaction {
+	pool.lock.RLock()
+	defer pool.lock.RUnlock()
+
+	txx := make([]*proto.Transaction, len(pool.byFee))
+	for i, e := range pool.byFee {
+		txx[i] = e.tx
+	}
+	return txx
+}
+
+func (pool *Mempool) Len() int {
+	pool.lock.RLock()
+	defer pool.lock.RUnlock()
+	return len(pool.txx)
+}
+
+// Size returns the size in bytes of all the txs in the pool.
+func (pool *Mempool) Size() int {
+	pool.lock.RLock()
+	defer pool.lock.RUnlock()
+	return pool.size
+}
+
+func (pool *Mempool) Has(tx *proto.Transaction) bool {
+	pool.lock.RLock()
+	defer pool.lock.RUnlock()
+
+	hash := hex.EncodeToString(types.HashTransaction(tx))
+	_, ok := pool.txx[hash]
+	return ok
+}
+
+// Get returns the tx with the given hex encoded hash.
+func (pool *Mempool) Get(hash string) (*proto.Transaction, bool) {
+	pool.lock.RLock()
+	defer pool.lock.RUnlock()
+
+	e, ok := pool.txx[hash]
+	if !ok {
+		return nil, false
+	}
+	return e.tx, true
+}
+
+// View returns the outputs of the txs in the pool.
+func (pool *Mempool) View() UTXOView {
+	return mempoolView{pool}
+}
+
+type mempoolView struct {
+	pool *Mempool
+}
+
+func (v mempoolView) Get(key string) (*UTXO, error) {
+	v.pool.lock.RLock()
+	defer v.pool.lock.RUnlock()
+
+	i := strings.LastIndexByte(key, '_')
+	if i < 0 {
+		return nil, fmt.Errorf("invalid utxo key %s", key)
+	}
+	index, err := strconv.Atoi(key[i+1:])
+	if err != nil {
+		return nil, err
+	}
+	e, ok := v.pool.txx[key[:i]]
+	if !ok || index < 0 || index >= len(e.tx.Outputs) {
+		return nil, fmt.Errorf("could not find utxo with hash %s", key)
+	}
+	// whether they are bonded or locked is only known once mined
+	if e.tx.Type != proto.TxType_TRANSFER {
+		return nil, fmt.Errorf("outputs of unconfirmed %s tx %s can't be spent", e.tx.Type, e.hash)
+	}
+	output := e.tx.Outputs[index]
+	return &UTXO{
+		Hash:     e.hash,
+		OutIndex: index,
+		Amount:   output.Amount,
+		Address:  hex.EncodeToString(output.Address),
+	}, nil
+}
+
+// Add adds tx paying fee to the pool. It fails when the tx is already
+// known, pays less than the minimum relay fee, spends an output twice or
+// exceeds the ancestor or descendant limits.
+//
+// A tx spending an output already spent by txs in the pool replaces them
+// and their descendants, when it pays more than all of them together
+// plus the incremental relay fee for its own size.
+//
+// When the pool is full, txs paying a lower fee rate get evicted to make
+// room, if there are none the tx is rejected.
+func (pool *Mempool) Add(tx *proto.Transaction, fee int64) error {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	e := &mempoolEntry{
+		tx:   tx,
+		hash: hex.EncodeToString(types.HashTransaction(tx)),
+		fee:  fee,
+		size: pb.Size(tx),
+	}
+	if _, ok := pool.txx[e.hash]; ok {
+		return errTxKnown
+	}
+	if e.fee*1000 < pool.cfg.MinRelayFee*int64(e.size) {
+		return fmt.Errorf("tx %s pays fee (%d) below the minimum relay fee (%d per 1000 bytes)", e.hash, e.fee, pool.cfg.MinRelayFee)
+	}
+
+	keys := make(map[string]bool, len(tx.Inputs))
+	conflicts := []string{}
+	for _, input := range tx.Inputs {
+		key := outpointKey(input)
+		if keys[key] {
+			return fmt.Errorf("tx %s spends %s twice", e.hash, key)
+		}
+		if other, ok := pool.spends[key]; ok {
+			conflicts = append(conflicts, other)
+		}
+		keys[key] = true
+	}
+
+	ancestors := pool.ancestors(tx)
+	if err := pool.checkLimits(e, ancestors); err != nil {
+		return err
+	}
+
+	replaced, err := pool.replaceable(e, conflicts)
+	if err != nil {
+		return err
+	}
+	for _, r := range replaced {
+		if _, ok := ancestors[r.hash]; ok {
+			return fmt.Errorf("tx %s replaces tx %s it depends on", e.hash, r.hash)
+		}
+	}
+	for _, r := range replaced {
+		pool.remove(r.hash)
+	}
+
+	if err := pool.makeRoom(e, ancestors); err != nil {
+		// put back what the tx would have replaced
+		for _, r := range replaced {
+			pool.insert(r)
+		}
+		return err
+	}
+	pool.insert(e)
+	return nil
+}
+
+// replaceable checks e may replace the conflicting txs and returns
+// them together with their descendants.
+func (pool *Mempool) replaceable(e *mempoolEntry, conflicts []string) ([]*mempoolEntry, error) {
+	if len(conflicts) == 0 {
+		return nil, nil
+	}
+
+	var (
+		replaced = []*mempoolEntry{}
+		seen     = make(map[string]bool)
+		fees     int64
+	)
+	for _, hash := range conflicts {
+		for _, r := range pool.withDescendants(hash) {
+			if seen[r.hash] {
+				continue
+			}
+			seen[r.hash] = true
+			replaced = append(replaced, r)
+			fees += r.fee
+		}
+	}
+	if len(replaced) > maxReplacements {
+		return nil, fmt.Errorf("tx %s would replace (%d) txs, more than (%d)", e.hash, len(replaced), maxReplacements)
+	}
+	if e.fee <= fees {
+		return nil, fmt.Errorf("tx %s pays fee (%d), not more than the (%d) of the txs it replaces", e.hash, e.fee, fees)
+	}
+	if (e.fee-fees)*1000 < pool.cfg.IncrementalRelayFee*int64(e.size) {
+		return nil, fmt.Errorf("tx %s pays (%d) more than the txs it replaces, below the incremental relay fee (%d per 1000 bytes)",
+			e.hash, e.fee-fees, pool.cfg.IncrementalRelayFee)
+	}
+	return replaced, nil
+}
+
+// checkLimits checks that adding e, depending on the given ancestors,
+// keeps every tx within the ancestor and descendant limits.
+func (pool *Mempool) checkLimits(e *mempoolEntry, ancestors map[string]*mempoolEntry) error {
+	var (
+		cfg  = pool.cfg
+		size = e.size
+	)
+	for _, a := range ancestors {
+		size += a.size
+	}
+	if cfg.MaxAncestors > 0 && len(ancestors)+1 > cfg.MaxAncestors {
+		return fmt.Errorf("tx %s has more than (%d) unconfirmed ancestors", e.hash, cfg.MaxAncestors-1)
+	}
+	if cfg.MaxAncestorSize > 0 && size > cfg.MaxAncestorSize {
+		return fmt.Errorf("tx %s and its ancestors exceed (%d) bytes", e.hash, cfg.MaxAncestorSize)
+	}
+
+	for _, a := range ancestors {
+		var (
+			descendants = pool.withDescendants(a.hash)
+			size        = e.size
+		)
+		for _, d := range descendants {
+			size += d.size
+		}
+		if cfg.MaxDescendants > 0 && len(descendants)+1 > cfg.MaxDescendants {
+			return fmt.Errorf("tx %s would have more than (%d) descendants", a.hash, cfg.MaxDescendants-1)
+		}
+		if cfg.MaxDescendantSize > 0 && size > cfg.MaxDescendantSize {
+			return fmt.Errorf("tx %s and its descendants would exceed (%d) bytes", a.hash, cfg.MaxDescendantSize)
+		}
+	}
+	return nil
+}
+
+// ancestors returns the entries of all the txs in the pool tx depends
+// on by their hash.
+func (pool *Mempool) ancestors(tx *proto.Transaction) map[string]*mempoolEntry {
+	ancestors := make(map[string]*mempoolEntry)
+	var visit func(tx *proto.Transaction)
+	visit = func(tx *proto.Transaction) {
+		for _, input := range tx.Inputs {
+			parent, ok := pool.txx[hex.EncodeToString(input.PrevTxHash)]
+			if !ok {
+				continue
+			}
+			if _, ok := ancestors[parent.hash]; ok {
+				continue
+			}
+			ancestors[parent.hash] = parent
+			visit(parent.tx)
+		}
+	}
+	visit(tx)
+	return ancestors
+}
+
+// withDescendants returns the entry of the tx with the given hash and
+// the entries of all the txs spending its outputs, recursively.
+func (pool *Mempool) withDescendants(hash string) []*mempoolEntry {
+	e, ok := pool.txx[hash]
+	if !ok {
+		return nil
+	}
+	entries := []*mempoolEntry{e}
+	for i := range e.tx.Outputs {
+		if spender, ok := pool.spends[fmt.Sprintf("%s_%d", hash, i)]; ok {
+			entries = append(entries, pool.withDescendants(spender)...)
+		}
+	}
+	return entries
+}
+
+// makeRoom evicts the lowest fee rate txs, together with their
+// descendants, until e fits into the pool. The ancestors of e are kept.
+func (pool *Mempool) makeRoom(e *mempoolEntry, ancestors map[string]*mempoolEntry) error {
+	var (
+		evict = make(map[string]bool)
+		txs   = len(pool.txx)
+		size  = pool.size
+	)
+	// pick the txs to evict before touching the pool
+	for i := len(pool.byFee) - 1; i >= 0 && pool.full(txs+1, size+e.size); i-- {
+		lowest := pool.byFee[i]
+		if !e.higherFeeRate(lowest) {
+			break
+		}
+		if _, ok := ancestors[lowest.hash]; ok || evict[lowest.hash] {
+			continue
+		}
+		for _, d := range pool.withDescendants(lowest.hash) {
+			if !evict[d.hash] {
+				evict[d.hash] = true
+				txs--
+				size -= d.size
+			}
+		}
+	}
+	if pool.full(txs+1, size+e.size) {
+		return fmt.Errorf("mempool is full")
+	}
+
+	for hash := range evict {
+		pool.remove(hash)
+	}
+	return nil
+}
+
+func (pool *Mempool) full(txs, size int) bool {
+	if pool.cfg.MaxTxs > 0 && txs > pool.cfg.MaxTxs {
+		return true
+	}
+	return pool.cfg.MaxBytes > 0 && size > pool.cfg.MaxBytes
+}
+
+// insert adds e to the pool keeping byFee sorted, entries with the same
+// fee rate stay in the order they arrived.
+func (pool *Mempool) insert(e *mempoolEntry) {
+	i := sort.Search(len(pool.byFee), func(i int) bool {
+		return e.higherFeeRate(pool.byFee[i])
+	})
+	pool.byFee = append(pool.byFee, nil)
+	copy(pool.byFee[i+1:], pool.byFee[i:])
+	pool.byFee[i] = e
+
+	for _, input := range e.tx.Inputs {
+		pool.spends[outpointKey(input)] = e.hash
+	}
+	pool.txx[e.hash] = e
+	pool.size += e.size
+}
+
+func (pool *Mempool) remove(hash string) {
+	e, ok := pool.txx[hash]
+	if !ok {
+		return
+	}
+	for i, other := range pool.byFee {
+		if other == e {
+			pool.byFee = append(pool.byFee[:i], pool.byFee[i+1:]...)
+			break
+		}
+	}
+	for _, input := range e.tx.Inputs {
+		key := outpointKey(input)
+		if pool.spends[key] == hash {
+			delete(pool.spends, key)
+		}
+	}
+	delete(pool.txx, hash)
+	pool.size -= e.size
+}
+
+// Remove removes the given txs and their descendants from the pool.
+func (pool *Mempool) Remove(txx []*proto.Transaction) {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	for _, tx := range txx {
+		for _, e := range pool.withDescendants(hex.EncodeToString(types.HashTransaction(tx))) {
+			pool.remove(e.hash)
+		}
+	}
+}
+
+// RemoveBlock removes the txs included in b from the pool, together with
+// the txs spending an output spent by b and their descendants, those can
+// never be mined. The descendants of the included txs stay.
+func (pool *Mempool) RemoveBlock(b *proto.Block) {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	for _, tx := range b.Transactions {
+		pool.remove(hex.EncodeToString(types.HashTransaction(tx)))
+		for _, input := range tx.Inputs {
+			hash, ok := pool.spends[outpointKey(input)]
+			if !ok {
+				continue
+			}
+			for _, e := range pool.withDescendants(hash) {
+				pool.remove(e.hash)
+			}
+		}
+	}
+}
+
+// Select returns the txs with the highest fee rate that fit into
+// maxBytes, without removing them from the pool. A tx is preceded by the
+// txs it depends on, which get selected along with it.
+func (pool *Mempool) Select(maxBytes int) []*proto.Transaction {
+	pool.lock.RLock()
+	defer pool.lock.RUnlock()
+
+	var (
+		txx      []*proto.Transaction
+		size     int
+		selected = make(map[string]bool)
+	)
+	for _, e := range pool.byFee {
+		if selected[e.hash] {
+			continue
+		}
+		pkg := pool.unselectedAncestors(e, selected, []*mempoolEntry{})
+		pkgSize := 0
+		for _, p := range pkg {
+			pkgSize += p.size
+		}
+		// a smaller tx further down might still fit
+		if size+pkgSize > maxBytes {
+			continue
+		}
+		for _, p := range pkg {
+			selected[p.hash] = true
+			txx = append(txx, p.tx)
+		}
+		size += pkgSize
+	}
+	return txx
+}
+
+// unselectedAncestors appends e and its ancestors that are not selected
+// yet to pkg, parents first.
+func (pool *Mempool) unselectedAncestors(e *mempoolEntry, selected map[string]bool, pkg []*mempoolEntry) []*mempoolEntry {
+	for _, input := range e.tx.Inputs {
+		parent, ok := pool.txx[hex.EncodeToString(input.PrevTxHash)]
+		if !ok || selected[parent.hash] || contains(pkg, parent) {
+			continue
+		}
+		pkg = pool.unselectedAncestors(parent, selected, pkg)
+	}
+	return append(pkg, e)
+}
+
+func contains(entries []*mempoolEntry, e *mempoolEntry) bool {
+	for _, other := range entries {
+		if other == e {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,226 @@
+package node
+
+import (
+	"testing"
+
+	pb "github.com/golang/protobuf/proto"
+	"github.com/s809616134/go-blocker/proto"
+	"github.com/s809616134/go-blocker/types"
+	"github.com/s809616134/go-blocker/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func spendTx(prevHash []byte, outIndex uint32, amount int64) *proto.Transaction {
+	return &proto.Transaction{
+		Version: 1,
+		ChainID: DefaultChainID,
+		Inputs: []*proto.TxInput{
+			{
+				PrevTxHash:   prevHash,
+				PrevOutIndex: outIndex,
+			},
+		},
+		Outputs: []*proto.TxOutput{
+			{
+				Amount:  amount,
+				Address: util.RandomHash()[:20],
+			},
+		},
+	}
+}
+
+func TestMempoolAdd(t *testing.T) {
+	pool := NewMempool(MempoolConfig{})
+	tx := spendTx(util.RandomHash(), 0, 10)
+
+	assert.Nil(t, pool.Add(tx, 1))
+	assert.Equal(t, errTxKnown, pool.Add(tx, 1))
+	assert.True(t, pool.Has(tx))
+	assert.Equal(t, 1, pool.Len())
+	assert.Equal(t, pb.Size(tx), pool.Size())
+}
+
+func TestMempoolAddConflict(t *testing.T) {
+	var (
+		pool     = NewMempool(MempoolConfig{})
+		prevHash = util.RandomHash()
+		tx       = spendTx(prevHash, 0, 10)
+	)
+	assert.Nil(t, pool.Add(tx, 1))
+
+	// spends the same outpoint as tx
+	assert.NotNil(t, pool.Add(spendTx(prevHash, 0, 5), 1))
+	// another output of the same tx is fine
+	assert.Nil(t, pool.Add(spendTx(prevHash, 1, 5), 1))
+
+	// duplicate inputs within a single tx
+	dup := spendTx(util.RandomHash(), 0, 10)
+	dup.Inputs = append(dup.Inputs, dup.Inputs[0])
+	assert.NotNil(t, pool.Add(dup, 1))
+
+	// removing tx releases the spent outpoint
+	pool.Remove([]*proto.Transaction{tx})
+	assert.Equal(t, 1, pool.Len())
+	assert.Nil(t, pool.Add(spendTx(prevHash, 0, 5), 1))
+}
+
+func TestMempoolMinRelayFee(t *testing.T) {
+	var (
+		pool = NewMempool(MempoolConfig{MinRelayFee: 1000})
+		tx   = spendTx(util.RandomHash(), 0, 10)
+		size = int64(pb.Size(tx))
+	)
+	assert.NotNil(t, pool.Add(tx, size-1))
+	assert.Nil(t, pool.Add(tx, size))
+}
+
+func TestMempoolSelectByFeeRate(t *testing.T) {
+	var (
+		pool = NewMempool(MempoolConfig{})
+		low  = spendTx(util.RandomHash(), 0, 10)
+		mid  = spendTx(util.RandomHash(), 0, 10)
+		high = spendTx(util.RandomHash(), 0, 10)
+		size = pb.Size(low)
+	)
+	assert.Nil(t, pool.Add(mid, 5))
+	assert.Nil(t, pool.Add(low, 1))
+	assert.Nil(t, pool.Add(high, 10))
+
+	assert.Equal(t, []*proto.Transaction{high, mid, low}, pool.Select(3*size))
+	assert.Equal(t, []*proto.Transaction{high, mid}, pool.Select(3*size-1))
+	assert.Empty(t, pool.Select(size-1))
+	// selecting leaves the txs in the pool
+	assert.Equal(t, 3, pool.Len())
+}
+
+func TestMempoolEviction(t *testing.T) {
+	var (
+		pool = NewMempool(MempoolConfig{MaxTxs: 2})
+		low  = spendTx(util.RandomHash(), 0, 10)
+		mid  = spendTx(util.RandomHash(), 0, 10)
+		high = spendTx(util.RandomHash(), 0, 10)
+	)
+	assert.Nil(t, pool.Add(low, 1))
+	assert.Nil(t, pool.Add(mid, 5))
+
+	// the pool is full and the tx pays less than every tx in it
+	assert.NotNil(t, pool.Add(spendTx(util.RandomHash(), 0, 10), 1))
+
+	// the lowest fee rate tx makes room
+	assert.Nil(t, pool.Add(high, 10))
+	assert.Equal(t, 2, pool.Len())
+	assert.False(t, pool.Has(low))
+	assert.True(t, pool.Has(mid))
+	assert.True(t, pool.Has(high))
+
+	// the evicted tx no longer blocks its outpoint
+	assert.Nil(t, pool.Add(spendTx(low.Inputs[0].PrevTxHash, 0, 5), 20))
+	assert.False(t, pool.Has(mid))
+}
+
+func TestMempoolRemoveBlock(t *testing.T) {
+	var (
+		pool     = NewMempool(MempoolConfig{})
+		included = spendTx(util.RandomHash(), 0, 10)
+		conflict = spendTx(util.RandomHash(), 0, 10)
+		other    = spendTx(util.RandomHash(), 0, 10)
+	)
+	assert.Nil(t, pool.Add(included, 1))
+	assert.Nil(t, pool.Add(conflict, 1))
+	assert.Nil(t, pool.Add(other, 1))
+
+	block := &proto.Block{
+		Transactions: []*proto.Transaction{
+			included,
+			spendTx(conflict.Inputs[0].PrevTxHash, 0, 5),
+		},
+	}
+	pool.RemoveBlock(block)
+	assert.Equal(t, []*proto.Transaction{other}, pool.Transactions())
+}
+
+func TestMempoolReplaceByFee(t *testing.T) {
+	var (
+		pool     = NewMempool(MempoolConfig{IncrementalRelayFee: 1000})
+		prevHash = util.RandomHash()
+		original = spendTx(prevHash, 0, 10)
+		child    = spendTx(types.HashTransaction(original), 0, 5)
+	)
+	assert.Nil(t, pool.Add(original, 10))
+	assert.Nil(t, pool.Add(child, 10))
+
+	replacement := spendTx(prevHash, 0, 8)
+	size := int64(pb.Size(replacement))
+	// has to pay for the original, its child and its own size
+	assert.NotNil(t, pool.Add(replacement, 20))
+	assert.NotNil(t, pool.Add(replacement, 20+size-1))
+	assert.Equal(t, 2, pool.Len())
+
+	assert.Nil(t, pool.Add(replacement, 20+size))
+	assert.Equal(t, []*proto.Transaction{replacement}, pool.Transactions())
+
+	// the original can't come back without paying more
+	assert.NotNil(t, pool.Add(original, 10))
+}
+
+func TestMempoolSelectParentsFirst(t *testing.T) {
+	var (
+		pool   = NewMempool(MempoolConfig{})
+		parent = spendTx(util.RandomHash(), 0, 10)
+		child  = spendTx(types.HashTransaction(parent), 0, 5)
+		other  = spendTx(util.RandomHash(), 0, 10)
+		size   = pb.Size(parent)
+	)
+	assert.Nil(t, pool.Add(parent, 1))
+	assert.Nil(t, pool.Add(other, 5))
+	// pays for its parent
+	assert.Nil(t, pool.Add(child, 20))
+
+	assert.Equal(t, []*proto.Transaction{parent, child, other}, pool.Select(3*size))
+	// the child doesn't fit without its parent
+	assert.Equal(t, []*proto.Transaction{other}, pool.Select(2*size-1))
+
+	utxo, err := pool.View().Get(outpointKey(child.Inputs[0]))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(10), utxo.Amount)
+	_, err = pool.View().Get(outpointKey(child.Inputs[0])[:64] + "_1")
+	assert.NotNil(t, err)
+}
+
+func TestMempoolAncestorLimits(t *testing.T) {
+	pool := NewMempool(MempoolConfig{MaxAncestors: 3, MaxDescendants: 3})
+
+	tx := spendTx(util.RandomHash(), 0, 10)
+	assert.Nil(t, pool.Add(tx, 1))
+	for i := 0; i < 2; i++ {
+		tx = spendTx(types.HashTransaction(tx), 0, 10)
+		assert.Nil(t, pool.Add(tx, 1))
+	}
+	// a fourth tx in a row has too many ancestors
+	assert.NotNil(t, pool.Add(spendTx(types.HashTransaction(tx), 0, 10), 1))
+
+	// another tx spending from the first one would exceed its
+	// descendant limit
+	first := pool.byFee[0].tx
+	assert.NotNil(t, pool.Add(spendTx(types.HashTransaction(first), 1, 10), 1))
+}
+
+func TestMempoolEvictsDescendants(t *testing.T) {
+	var (
+		pool   = NewMempool(MempoolConfig{MaxTxs: 2})
+		parent = spendTx(util.RandomHash(), 0, 10)
+		child  = spendTx(types.HashTransaction(parent), 0, 5)
+		high   = spendTx(util.RandomHash(), 0, 10)
+	)
+	assert.Nil(t, pool.Add(parent, 1))
+	assert.Nil(t, pool.Add(child, 2))
+
+	// evicting the parent takes the child with it
+	assert.Nil(t, pool.Add(high, 10))
+	assert.Equal(t, []*proto.Transaction{high}, pool.Transactions())
+
+	// removing a tx removes its descendants
+	assert.Nil(t, pool.Add(spendTx(types.HashTransaction(high), 0, 5), 10))
+	pool.Remove([]*proto.Transaction{high})
+	assert.Equal(t, 0, pool.Len())
+}
@@ -3,77 +3,46 @@ package node
 import (
 	"context"
 	"encoding/hex"
+	"errors"
+	"fmt"
 	"net"
 	"sync"
 	"time"
 
+	"github.com/s809616134/go-blocker/consensus"
 	"github.com/s809616134/go-blocker/crypto"
 	"github.com/s809616134/go-blocker/proto"
 	"github.com/s809616134/go-blocker/types"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
 )
 
-const blockTime = time.Second * 5
-
-type Mempool struct {
-	lock sync.RWMutex
-	txx  map[string]*proto.Transaction
-}
-
-func NewMempool() *Mempool {
-	return &Mempool{
-		txx: make(map[string]*proto.Transaction),
-	}
-}
-
-func (pool *Mempool) Clear() []*proto.Transaction {
-	pool.lock.Lock()
-	defer pool.lock.Unlock()
-
-	txx := make([]*proto.Transaction, len(pool.txx))
-	it := 0
-	for k, v := range pool.txx {
-		delete(pool.txx, k)
-		txx[it] = v
-		it++
-	}
-	return txx
-}
-
-func (pool *Mempool) Len() int {
-	pool.lock.RLock()
-	defer pool.lock.RUnlock()
-	return len(pool.txx)
-}
-
-func (pool *Mempool) Has(tx *proto.Transaction) bool {
-	pool.lock.RLock()
-	defer pool.lock.RUnlock()
-
-	hash := hex.EncodeToString(types.HashTransaction(tx))
-	_, ok := pool.txx[hash]
-	return ok
-}
-
-func (pool *Mempool) Add(tx *proto.Transaction) bool {
-	if pool.Has(tx) {
-		return false
-	}
-
-	pool.lock.Lock()
-	defer pool.lock.Unlock()
-
-	hash := hex.EncodeToString(types.HashTransaction(tx))
-	pool.txx[hash] = tx
-	return true
-}
+const (
+	blockTime = time.Second * 5
+	// how long a peer gets to take a message we broadcast
+	broadcastTimeout = time.Second * 5
+	// maximum size in bytes of the txs we put into a block
+	maxBlockSize = 1 << 20
+)
 
 type ServerConfig struct {
 	Version    string
 	ListenAddr string
 	PrivateKey *crypto.PrivateKey
+	// Chain the node follows, DefaultChainID when empty. Peers
+	// of other chains get rejected.
+	ChainID string
+	// Directory the chain is persisted to, when empty the
+	// chain only lives in memory.
+	DataDir string
+	// Limits of the mempool, DefaultMempoolConfig when left empty
+	Mempool MempoolConfig
+	// Timeouts of the consensus, consensus.DefaultConfig when
+	// left empty
+	Consensus consensus.Config
 }
 
 type Node struct {
@@ -81,23 +50,91 @@ type Node struct {
 	logger *zap.SugaredLogger
 
 	// Each time we handshake, record to the map
-	peerLock sync.RWMutex
-	peers    map[proto.NodeClient]*proto.Version
-	mempool  *Mempool
+	peerLock  sync.RWMutex
+	peers     map[proto.NodeClient]*proto.Version
+	mempool   *Mempool
+	orphans   *OrphanPool
+	evidence  *EvidencePool
+	chain     *Chain
+	consensus *consensus.State
+
+	// The proposals and votes we already accepted by height, so
+	// gossiped messages are only handled and relayed once.
+	seenLock     sync.Mutex
+	seenMessages map[int32]map[string]bool
+
+	syncLock sync.Mutex
+
+	// Held from validating a tx to adding it to the mempool and while
+	// the txs of a connected block leave the mempool, so a tx validated
+	// before a block connected can't enter the mempool after the block
+	// cleaned it up.
+	poolLock sync.Mutex
 
 	proto.UnimplementedNodeServer
 }
 
-func NewNode(cfg ServerConfig) *Node {
+func NewNode(cfg ServerConfig) (*Node, error) {
 	loggerConfig := zap.NewDevelopmentConfig()
 	loggerConfig.EncoderConfig.TimeKey = ""
 	logger, _ := loggerConfig.Build()
-	return &Node{
+
+	if len(cfg.ChainID) == 0 {
+		cfg.ChainID = DefaultChainID
+	}
+	chain, err := newChain(cfg.ChainID, cfg.DataDir)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Mempool == (MempoolConfig{}) {
+		cfg.Mempool = DefaultMempoolConfig
+	}
+	if cfg.Consensus == (consensus.Config{}) {
+		cfg.Consensus = consensus.DefaultConfig
+	}
+
+	n := &Node{
 		peers:        make(map[proto.NodeClient]*proto.Version),
+		seenMessages: make(map[int32]map[string]bool),
 		logger:       logger.Sugar(),
-		mempool:      NewMempool(),
+		mempool:      NewMempool(cfg.Mempool),
+		orphans:      NewOrphanPool(maxOrphans, orphanTTL),
+		evidence:     NewEvidencePool(),
+		chain:        chain,
 		ServerConfig: cfg,
 	}
+	n.consensus = consensus.NewState(cfg.Consensus, consensusBackend{n}, cfg.PrivateKey, n.logger)
+	n.chain.OnBlockConnected(func(b *proto.Block) {
+		n.poolLock.Lock()
+		n.mempool.RemoveBlock(b)
+		n.poolLock.Unlock()
+		n.evidence.RemoveBlock(b)
+		n.pruneSeenMessages(b.Header.Height)
+		for _, tx := range b.Transactions {
+			n.promoteOrphans(tx)
+		}
+	})
+	return n, nil
+}
+
+func newChain(chainID, dataDir string) (*Chain, error) {
+	if len(dataDir) == 0 {
+		return NewChain(chainID, NewMemoryBlockStore(), NewMemoryTXStore(), NewMemoryUTXOStore())
+	}
+
+	blockStore, err := NewDiskBlockStore(dataDir)
+	if err != nil {
+		return nil, err
+	}
+	txStore, err := NewDiskTXStore(dataDir)
+	if err != nil {
+		return nil, err
+	}
+	utxoStore, err := NewDiskUTXOStore(dataDir)
+	if err != nil {
+		return nil, err
+	}
+	return NewChain(chainID, blockStore, txStore, utxoStore)
 }
 
 func (n *Node) Start(listenAddr string, bootstrapNodes []string) error {
@@ -119,14 +156,18 @@ func (n *Node) Start(listenAddr string, bootstrapNodes []string) error {
 		go n.bootstrapNetwork(bootstrapNodes)
 	}
 
-	if n.PrivateKey != nil {
-		go n.validatorLoop()
+	// Nodes without a private key follow the consensus without voting
+	if err := n.consensus.Start(); err != nil {
+		return err
 	}
 
 	return grpcServer.Serve(ln)
 }
 
 func (n *Node) Handshake(ctx context.Context, v *proto.Version) (*proto.Version, error) {
+	if v.ChainID != n.ChainID {
+		return nil, status.Errorf(codes.FailedPrecondition, "peer %s follows chain %q, we follow %q", v.ListenAddr, v.ChainID, n.ChainID)
+	}
 	c, err := makeNodeClient(v.ListenAddr)
 	if err != nil {
 		return nil, err
@@ -146,42 +187,319 @@ func (n *Node) HandleTransaction(ctx context.Context, tx *proto.Transaction) (*p
 	peer, _ := peer.FromContext(ctx)
 	hash := hex.EncodeToString(types.HashTransaction(tx))
 
-	if n.mempool.Add(tx) {
-		n.logger.Debugw("received tx from", "from", peer.Addr, "hash", hash, "we", n.ListenAddr)
-		go func() {
-			if err := n.broadcast(tx); err != nil {
-				n.logger.Errorw("broadcast error", "err", err)
-			}
-		}()
+	if err := n.addTransaction(tx); err != nil {
+		// Peers relay the txs they accept, so receiving a tx
+		// more than once is expected.
+		if status.Code(err) == codes.AlreadyExists {
+			return &proto.Ack{}, nil
+		}
+		// Kept until its parent arrives, not relayed till then
+		if status.Code(err) == codes.NotFound {
+			n.logger.Debugw("received orphan tx", "from", peer.Addr, "hash", hash, "err", err)
+			return &proto.Ack{}, nil
+		}
+		n.logger.Debugw("rejected tx", "from", peer.Addr, "hash", hash, "err", err)
+		return nil, err
 	}
 
+	n.logger.Debugw("received tx from", "from", peer.Addr, "hash", hash, "we", n.ListenAddr)
+	n.relayTransaction(tx)
 	return &proto.Ack{}, nil
 }
 
-func (n *Node) validatorLoop() {
-	n.logger.Infow("starting validator loop", "pubkey", n.PrivateKey.Public(), "blockTime", blockTime)
-	ticker := time.NewTicker(blockTime)
-	for {
-		<-ticker.C
+func (n *Node) relayTransaction(tx *proto.Transaction) {
+	go func() {
+		if err := n.broadcast(tx); err != nil {
+			n.logger.Errorw("broadcast error", "err", err)
+		}
+	}()
+}
+
+// addTransaction validates tx against the tip of the chain and the txs
+// pending in the mempool before adding it to the mempool. Rejections are
+// reported as gRPC status errors. A tx spending an output we don't know
+// of yet ends up in the orphan pool, reported as NotFound.
+func (n *Node) addTransaction(tx *proto.Transaction) error {
+	if err := n.admitTransaction(tx); err != nil {
+		return err
+	}
+	n.promoteOrphans(tx)
+	return nil
+}
+
+// admitTransaction validates tx and adds it to the mempool, or to the
+// orphans when it spends outputs we don't know of.
+func (n *Node) admitTransaction(tx *proto.Transaction) error {
+	n.poolLock.Lock()
+	defer n.poolLock.Unlock()
 
-		txx := n.mempool.Clear()
-		n.logger.Debugw("time to create a new block", "lenTx", len(txx))
+	hash := hex.EncodeToString(types.HashTransaction(tx))
+	if n.mempool.Has(tx) {
+		return status.Errorf(codes.AlreadyExists, "tx %s is already in the mempool", hash)
+	}
 
+	fee, err := n.chain.PendingTransactionFee(tx, n.mempool.View())
+	if err != nil {
+		var missing *missingInputError
+		if errors.As(err, &missing) {
+			n.orphans.Add(tx, missing.key)
+			return status.Errorf(codes.NotFound, "orphan tx %s: %s", hash, err)
+		}
+		return status.Errorf(codes.InvalidArgument, "invalid tx %s: %s", hash, err)
 	}
+	if err := n.mempool.Add(tx, fee); err != nil {
+		if err == errTxKnown {
+			return status.Errorf(codes.AlreadyExists, "tx %s is already in the mempool", hash)
+		}
+		return status.Errorf(codes.FailedPrecondition, "rejected by the mempool: %s", err)
+	}
+	return nil
 }
 
-// Loop through the peers, broadcast the transaction msg
+// promoteOrphans moves the orphans waiting for an output of tx into the
+// mempool and relays them.
+func (n *Node) promoteOrphans(tx *proto.Transaction) {
+	for _, orphan := range n.orphans.Take(tx) {
+		if err := n.addTransaction(orphan); err != nil {
+			n.logger.Debugw("orphan not promoted",
+				"hash", hex.EncodeToString(types.HashTransaction(orphan)),
+				"err", err)
+			continue
+		}
+		n.relayTransaction(orphan)
+	}
+}
+
+// HandleBlock takes the announcement of a block a peer committed. Blocks
+// only make it into the chain together with their commit, so for a
+// block missing from our main chain we catch up with our peers, who
+// serve it with its commit. The block on top of our tip is left to our
+// consensus, which decides it from the same votes as the peer.
+func (n *Node) HandleBlock(ctx context.Context, b *proto.Block) (*proto.Ack, error) {
+	if b.Header == nil {
+		return nil, fmt.Errorf("block has no header")
+	}
+	if int(b.Header.Height) > n.chain.Height()+1 && !n.chain.OnMainChain(types.HashBlock(b)) {
+		go n.sync()
+	}
+	return &proto.Ack{}, nil
+}
+
+// announceBlock tells our peers about a block our consensus committed,
+// so the ones that fell behind catch up.
+func (n *Node) announceBlock(b *proto.Block) {
+	go func() {
+		if err := n.broadcast(b); err != nil {
+			n.logger.Debugw("announcing block failed", "err", err)
+		}
+	}()
+}
+
+func (n *Node) GetBalance(ctx context.Context, r *proto.AddressRequest) (*proto.Balance, error) {
+	if len(r.Address) != crypto.AddressLen {
+		return nil, fmt.Errorf("invalid address length (%d)", len(r.Address))
+	}
+	balance, err := n.chain.GetBalance(r.Address)
+	if err != nil {
+		return nil, err
+	}
+	return &proto.Balance{
+		Address: r.Address,
+		Amount:  balance,
+	}, nil
+}
+
+func (n *Node) ListUnspent(ctx context.Context, r *proto.AddressRequest) (*proto.UnspentOutputs, error) {
+	if len(r.Address) != crypto.AddressLen {
+		return nil, fmt.Errorf("invalid address length (%d)", len(r.Address))
+	}
+	utxos, err := n.chain.GetUnspent(r.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	outputs := make([]*proto.UnspentOutput, len(utxos))
+	for i, utxo := range utxos {
+		hash, err := hex.DecodeString(utxo.Hash)
+		if err != nil {
+			return nil, err
+		}
+		outputs[i] = &proto.UnspentOutput{
+			TxHash:     hash,
+			OutIndex:   uint32(utxo.OutIndex),
+			Amount:     utxo.Amount,
+			Address:    r.Address,
+			Bonded:     utxo.Bonded,
+			LockHeight: int32(utxo.LockHeight),
+		}
+		if len(utxo.Validator) > 0 {
+			if outputs[i].Validator, err = hex.DecodeString(utxo.Validator); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &proto.UnspentOutputs{Outputs: outputs}, nil
+}
+
+func (n *Node) GetValidators(ctx context.Context, r *proto.ValidatorsRequest) (*proto.ValidatorList, error) {
+	height := int(r.Height)
+	if height == 0 {
+		height = n.chain.Height() + 1
+	}
+	validators, err := n.chain.EpochValidators(height)
+	if err != nil {
+		return nil, status.Errorf(codes.NotFound, "%s", err)
+	}
+	list := &proto.ValidatorList{Validators: ValidatorInfos(validators)}
+	list.Hash = types.HashValidators(list.Validators)
+	return list, nil
+}
+
+func (n *Node) GetDelegations(ctx context.Context, r *proto.DelegationsRequest) (*proto.Delegations, error) {
+	for _, address := range [][]byte{r.Delegator, r.Validator} {
+		if len(address) != 0 && len(address) != crypto.AddressLen {
+			return nil, fmt.Errorf("invalid address length (%d)", len(address))
+		}
+	}
+	delegations, err := n.chain.Delegations(hex.EncodeToString(r.Delegator), hex.EncodeToString(r.Validator))
+	if err != nil {
+		return nil, err
+	}
+	resp := &proto.Delegations{}
+	for _, d := range delegations {
+		delegator, err := hex.DecodeString(d.Delegator)
+		if err != nil {
+			return nil, err
+		}
+		validator, err := hex.DecodeString(d.Validator)
+		if err != nil {
+			return nil, err
+		}
+		resp.Delegations = append(resp.Delegations, &proto.Delegation{
+			Delegator: delegator,
+			Validator: validator,
+			Amount:    d.Amount,
+		})
+	}
+	return resp, nil
+}
+
+func (n *Node) GetTransaction(ctx context.Context, r *proto.TxRequest) (*proto.TransactionInfo, error) {
+	if tx, ok := n.mempool.Get(hex.EncodeToString(r.Hash)); ok {
+		fee, err := n.chain.PendingTransactionFee(tx, n.mempool.View())
+		if err != nil {
+			return nil, err
+		}
+		return &proto.TransactionInfo{
+			Transaction: tx,
+			Fee:         fee,
+			Pending:     true,
+		}, nil
+	}
+
+	tx, fee, err := n.chain.GetTransaction(r.Hash)
+	if err != nil {
+		return nil, err
+	}
+	return &proto.TransactionInfo{
+		Transaction: tx,
+		Fee:         fee,
+	}, nil
+}
+
+// createBlock builds a block on top of the current tip of the chain
+// with the given timestamp and every valid transaction of txx and signs
+// it with our private key.
+func (n *Node) createBlock(timestamp int64, txx []*proto.Transaction) (*proto.Block, error) {
+	prevBlock, err := n.chain.GetBlockByHeight(n.chain.Height())
+	if err != nil {
+		return nil, err
+	}
+
+	height := prevBlock.Header.Height + 1
+	block := &proto.Block{
+		Header: &proto.Header{
+			Version:        1,
+			Height:         height,
+			PrevHash:       types.HashBlock(prevBlock),
+			Timestamp:      timestamp,
+			ValidatorsHash: n.chain.ValidatorsHash(),
+			ChainID:        n.chain.ChainID(),
+		},
+	}
+
+	// txx is in topological order, so txs can spend outputs of the
+	// ones before them
+	valid, fees, invalid := n.chain.FilterTransactions(txx)
+	for hash, err := range invalid {
+		n.logger.Debugw("dropping invalid tx", "hash", hash, "err", err)
+	}
+	block.Transactions = append(block.Transactions, valid...)
+
+	// The coinbase goes first and pays the block reward plus the fees to
+	// our delegators and us
+	var (
+		reward  = n.chain.BlockReward(int(height)) + fees
+		address = n.PrivateKey.Public().Address()
+	)
+	shares, err := n.chain.DelegatorRewards(address, reward)
+	if err != nil {
+		return nil, err
+	}
+	for _, share := range shares {
+		reward -= share.Amount
+	}
+	coinbase := types.NewCoinbaseTransaction(n.chain.ChainID(), height, reward, address.Bytes())
+	coinbase.Outputs = append(shares, coinbase.Outputs...)
+	block.Transactions = append([]*proto.Transaction{coinbase}, block.Transactions...)
+
+	// Get the validators that double signed convicted
+	block.Evidence = n.chain.FilterEvidence(n.evidence.Pending())
+
+	types.SignBlock(n.PrivateKey, block)
+	return block, nil
+}
+
+// Loop through the peers, broadcast the transaction, block or consensus msg
+// A peer rejecting the msg does not keep the others from receiving it,
+// the first error is returned.
 func (n *Node) broadcast(msg any) error {
+	// a slow peer must not hold up adding or removing peers
+	n.peerLock.RLock()
+	peers := make([]proto.NodeClient, 0, len(n.peers))
 	for peer := range n.peers {
-		switch v := msg.(type) {
-		case *proto.Transaction:
-			_, err := peer.HandleTransaction(context.Background(), v)
-			if err != nil {
-				return err
-			}
+		peers = append(peers, peer)
+	}
+	n.peerLock.RUnlock()
+
+	var firstErr error
+	for _, peer := range peers {
+		if err := sendMessage(peer, msg); err != nil && firstErr == nil {
+			firstErr = err
 		}
 	}
-	return nil
+	return firstErr
+}
+
+// sendMessage hands msg to the peer, giving up after broadcastTimeout.
+func sendMessage(peer proto.NodeClient, msg any) error {
+	ctx, cancel := context.WithTimeout(context.Background(), broadcastTimeout)
+	defer cancel()
+
+	var err error
+	switch v := msg.(type) {
+	case *proto.Transaction:
+		_, err = peer.HandleTransaction(ctx, v)
+	case *proto.Block:
+		_, err = peer.HandleBlock(ctx, v)
+	case *proto.Proposal:
+		_, err = peer.HandleProposal(ctx, v)
+	case *proto.Vote:
+		_, err = peer.HandleVote(ctx, v)
+	case *proto.Evidence:
+		_, err = peer.HandleEvidence(ctx, v)
+	}
+	return err
 }
 
 func (n *Node) addPeer(c proto.NodeClient, v *proto.Version) {
@@ -202,6 +520,10 @@ func (n *Node) addPeer(c proto.NodeClient, v *proto.Version) {
 		"we", n.ListenAddr,
 		"remoteNode", v.ListenAddr,
 		"height", v.Height)
+
+	if int(v.Height) > n.chain.Height() {
+		go n.sync()
+	}
 }
 
 func (n *Node) deletePeer(c proto.NodeClient) {
@@ -236,6 +558,9 @@ func (n *Node) dialRemoteNode(addr string) (proto.NodeClient, *proto.Version, er
 	if err != nil {
 		return nil, nil, err
 	}
+	if v.ChainID != n.ChainID {
+		return nil, nil, fmt.Errorf("peer %s follows chain %q, we follow %q", addr, v.ChainID, n.ChainID)
+	}
 
 	return c, v, nil
 }
@@ -243,9 +568,10 @@ func (n *Node) dialRemoteNode(addr string) (proto.NodeClient, *proto.Version, er
 func (n *Node) getVersion() *proto.Version {
 	return &proto.Version{
 		Version:    "block-0.1",
-		Height:     0,
+		Height:     int32(n.chain.Height()),
 		ListenAddr: n.ListenAddr,
 		PeerList:   n.getPeerList(),
+		ChainID:    n.ChainID,
 	}
 }
 
@@ -0,0 +1,312 @@
+package node
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/s809616134/go-blocker/crypto"
+	"github.com/s809616134/go-blocker/proto"
+	"github.com/s809616134/go-blocker/types"
+	"github.com/s809616134/go-blocker/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func peerContext() context.Context {
+	return peer.NewContext(context.Background(), &peer.Peer{
+		Addr: &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 3000},
+	})
+}
+
+func TestHandleTransactionValidation(t *testing.T) {
+	n, err := NewNode(ServerConfig{})
+	require.Nil(t, err)
+
+	// spends an output that does not exist
+	_, err = n.HandleTransaction(peerContext(), spendTx(util.RandomHash(), 0, 10))
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, 0, n.mempool.Len())
+
+	tx := spendGenesisTx(t, n.chain, 900)
+	_, err = n.HandleTransaction(peerContext(), tx)
+	require.Nil(t, err)
+	assert.True(t, n.mempool.Has(tx))
+
+	// receiving it again is fine
+	_, err = n.HandleTransaction(peerContext(), tx)
+	require.Nil(t, err)
+
+	// spends the same output as the pending tx without paying more
+	_, err = n.HandleTransaction(peerContext(), spendGenesisTx(t, n.chain, 950))
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	assert.Equal(t, 1, n.mempool.Len())
+}
+
+func TestMempoolFollowsChain(t *testing.T) {
+	n, err := NewNode(ServerConfig{})
+	require.Nil(t, err)
+
+	pending := spendGenesisTx(t, n.chain, 900)
+	require.Nil(t, n.addTransaction(pending))
+
+	// a block spending the same output evicts the pending tx
+	mined := spendGenesisTx(t, n.chain, 800)
+	block := randomBLock(t, n.chain)
+	block.Transactions = append(block.Transactions, mined)
+	types.SignBlock(GenesisValidatorKey(), block)
+	require.Nil(t, n.chain.AddBlock(block))
+	assert.Equal(t, 0, n.mempool.Len())
+}
+
+func TestMempoolBlockConnectedDuringAdd(t *testing.T) {
+	n, err := NewNode(ServerConfig{})
+	require.Nil(t, err)
+
+	// a tx got validated before a block spending the same output
+	// connected and is about to enter the mempool
+	pending := spendGenesisTx(t, n.chain, 900)
+	fee, err := n.chain.PendingTransactionFee(pending, n.mempool.View())
+	require.Nil(t, err)
+	n.poolLock.Lock()
+
+	block := randomBLock(t, n.chain)
+	block.Transactions = append(block.Transactions, spendGenesisTx(t, n.chain, 800))
+	types.SignBlock(GenesisValidatorKey(), block)
+	done := make(chan error)
+	go func() {
+		done <- n.chain.CommitBlock(block, newCommit(block, GenesisValidatorKey()))
+	}()
+	require.Eventually(t, func() bool {
+		return n.chain.Height() == 1
+	}, time.Second, time.Millisecond*10)
+
+	require.Nil(t, n.mempool.Add(pending, fee))
+	n.poolLock.Unlock()
+	require.Nil(t, <-done)
+	// the block cleans up after the tx made it in
+	assert.False(t, n.mempool.Has(pending))
+}
+
+func TestHandleTransactionOrphan(t *testing.T) {
+	n, err := NewNode(ServerConfig{})
+	require.Nil(t, err)
+
+	parent, child := spendGenesisChain(t, n.chain)
+
+	// the child arrives first
+	_, err = n.HandleTransaction(peerContext(), child)
+	require.Nil(t, err)
+	assert.Equal(t, 0, n.mempool.Len())
+	assert.Equal(t, 1, n.orphans.Len())
+
+	// its parent gets mined
+	block := randomBLock(t, n.chain)
+	block.Transactions = append(block.Transactions, parent)
+	types.SignBlock(GenesisValidatorKey(), block)
+	require.Nil(t, n.chain.AddBlock(block))
+
+	assert.Equal(t, 0, n.orphans.Len())
+	assert.True(t, n.mempool.Has(child))
+}
+
+func TestHandleTransactionUnconfirmedParent(t *testing.T) {
+	n, err := NewNode(ServerConfig{PrivateKey: GenesisValidatorKey()})
+	require.Nil(t, err)
+	parent, child := spendGenesisChain(t, n.chain)
+
+	// the child waits for its parent, which promotes it into the mempool
+	_, err = n.HandleTransaction(peerContext(), child)
+	require.Nil(t, err)
+	_, err = n.HandleTransaction(peerContext(), parent)
+	require.Nil(t, err)
+	assert.Equal(t, 0, n.orphans.Len())
+	assert.Equal(t, 2, n.mempool.Len())
+
+	info, err := n.GetTransaction(context.Background(), &proto.TxRequest{Hash: types.HashTransaction(child)})
+	require.Nil(t, err)
+	assert.True(t, info.Pending)
+	assert.Equal(t, int64(100), info.Fee)
+
+	// both make it into the block, parent first
+	block, err := n.createBlock(time.Now().UnixNano(), n.mempool.Select(maxBlockSize))
+	require.Nil(t, err)
+	require.Len(t, block.Transactions, 3)
+	assert.Equal(t, parent, block.Transactions[1])
+	assert.Equal(t, child, block.Transactions[2])
+	require.Nil(t, n.chain.AddBlock(block))
+	assert.Equal(t, 0, n.mempool.Len())
+}
+
+func TestHandshakeChainID(t *testing.T) {
+	n, err := NewNode(ServerConfig{ListenAddr: ":3000"})
+	require.Nil(t, err)
+
+	_, err = n.Handshake(context.Background(), &proto.Version{ListenAddr: ":4000", ChainID: "testnet"})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	assert.Empty(t, n.getPeerList())
+
+	v, err := n.Handshake(context.Background(), &proto.Version{ListenAddr: ":4000", ChainID: DefaultChainID})
+	require.Nil(t, err)
+	assert.Equal(t, DefaultChainID, v.ChainID)
+	assert.Equal(t, []string{":4000"}, n.getPeerList())
+}
+
+func TestCreateBlock(t *testing.T) {
+	n, err := NewNode(ServerConfig{PrivateKey: GenesisValidatorKey()})
+	require.Nil(t, err)
+	require.Nil(t, n.chain.AddBlock(randomBLock(t, n.chain)))
+	tip, err := n.chain.GetBlockByHeight(1)
+	require.Nil(t, err)
+	tx := spendGenesisTx(t, n.chain, 900)
+	require.Nil(t, n.addTransaction(tx))
+
+	// the block the consensus proposes in our rounds
+	block, err := consensusBackend{n}.CreateBlock()
+	require.Nil(t, err)
+	assert.Equal(t, types.HashBlock(tip), block.Header.PrevHash)
+	assert.Equal(t, int32(2), block.Header.Height)
+	assert.Equal(t, GenesisValidatorKey().Public().Bytes(), block.PublicKey)
+	assert.True(t, types.VerifyBlock(block))
+	require.Len(t, block.Transactions, 2)
+	assert.True(t, types.IsCoinbase(block.Transactions[0]))
+	assert.Equal(t, tx, block.Transactions[1])
+
+	require.Nil(t, n.chain.AddBlock(block))
+	assert.Equal(t, 2, n.chain.Height())
+	assert.Equal(t, 0, n.mempool.Len())
+}
+
+func TestHandleBlockAnnouncement(t *testing.T) {
+	a, err := NewNode(ServerConfig{})
+	require.Nil(t, err)
+	b, err := NewNode(ServerConfig{})
+	require.Nil(t, err)
+	commitBlocks(t, a, 3)
+
+	// a peer we connected to before it got the blocks
+	c := &syncClient{NodeClient: serveNode(t, a)}
+	v := a.getVersion()
+	v.Height = 0
+	b.addPeer(c, v)
+
+	_, err = b.HandleBlock(peerContext(), &proto.Block{})
+	assert.NotNil(t, err)
+
+	// the block on top of our tip is left to our consensus
+	next, err := a.chain.GetBlockByHeight(1)
+	require.Nil(t, err)
+	_, err = b.HandleBlock(peerContext(), next)
+	require.Nil(t, err)
+	assert.Never(t, func() bool {
+		return c.headers.Load() != 0
+	}, time.Millisecond*100, time.Millisecond*10)
+
+	// the announcement of a block we miss makes us catch up
+	tip, err := a.chain.GetBlockByHeight(a.chain.Height())
+	require.Nil(t, err)
+	_, err = b.HandleBlock(peerContext(), tip)
+	require.Nil(t, err)
+	require.Eventually(t, func() bool {
+		// wait for the sync to finish as well
+		if !b.syncLock.TryLock() {
+			return false
+		}
+		defer b.syncLock.Unlock()
+		return b.chain.OnMainChain(types.HashBlock(tip))
+	}, time.Second*5, time.Millisecond*10)
+	assert.Equal(t, 3, b.chain.Height())
+
+	// announcing it again changes nothing
+	syncs := c.headers.Load()
+	_, err = b.HandleBlock(peerContext(), tip)
+	require.Nil(t, err)
+	assert.Never(t, func() bool {
+		return c.headers.Load() != syncs
+	}, time.Millisecond*100, time.Millisecond*10)
+}
+
+// announceClient is a peer recording the blocks announced to it.
+type announceClient struct {
+	proto.NodeClient
+	blocks chan *proto.Block
+}
+
+func (c *announceClient) HandleBlock(ctx context.Context, b *proto.Block, opts ...grpc.CallOption) (*proto.Ack, error) {
+	c.blocks <- b
+	return &proto.Ack{}, nil
+}
+
+func TestCommitAnnouncesBlock(t *testing.T) {
+	n, err := NewNode(ServerConfig{})
+	require.Nil(t, err)
+	c := &announceClient{blocks: make(chan *proto.Block, 1)}
+	n.addPeer(c, &proto.Version{})
+
+	// a block that doesn't make it into the chain is not announced
+	block := randomBLock(t, n.chain)
+	require.NotNil(t, consensusBackend{n}.Commit(block, newCommit(block, crypto.GeneratPrivateKey())))
+	select {
+	case <-c.blocks:
+		t.Fatal("invalid block announced")
+	case <-time.After(time.Millisecond * 100):
+	}
+
+	require.Nil(t, consensusBackend{n}.Commit(block, newCommit(block, GenesisValidatorKey())))
+	select {
+	case announced := <-c.blocks:
+		assert.Equal(t, block, announced)
+	case <-time.After(time.Second):
+		t.Fatal("block not announced")
+	}
+}
+
+// slowClient is a peer taking the txs broadcast to it only once it gets
+// released.
+type slowClient struct {
+	proto.NodeClient
+	called  chan struct{}
+	release chan struct{}
+}
+
+func (c *slowClient) HandleTransaction(ctx context.Context, tx *proto.Transaction, opts ...grpc.CallOption) (*proto.Ack, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		return nil, status.Error(codes.InvalidArgument, "no deadline")
+	}
+	close(c.called)
+	<-c.release
+	return &proto.Ack{}, nil
+}
+
+func TestBroadcastSlowPeer(t *testing.T) {
+	n, err := NewNode(ServerConfig{})
+	require.Nil(t, err)
+	slow := &slowClient{called: make(chan struct{}), release: make(chan struct{})}
+	n.addPeer(slow, &proto.Version{})
+
+	done := make(chan error)
+	go func() {
+		done <- n.broadcast(spendGenesisTx(t, n.chain, 100))
+	}()
+	<-slow.called
+
+	// peers come and go while the slow peer takes its time
+	added := make(chan struct{})
+	go func() {
+		n.addPeer(&announceClient{}, &proto.Version{})
+		close(added)
+	}()
+	select {
+	case <-added:
+	case <-time.After(time.Second):
+		t.Fatal("peer not added during a broadcast")
+	}
+
+	close(slow.release)
+	assert.Nil(t, <-done)
+}
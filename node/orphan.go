@@ -0,0 +1,126 @@
+package node
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/s809616134/go-blocker/proto"
+	"github.com/s809616134/go-blocker/types"
+)
+
+const (
+	maxOrphans = 100
+	orphanTTL  = time.Minute * 20
+)
+
+type orphan struct {
+	tx   *proto.Transaction
+	hash string
+	// outpoint key of the output the tx is waiting for
+	missing string
+	expires time.Time
+}
+
+// OrphanPool holds txs spending outputs we don't know of yet, until the
+// tx creating the output arrives or they expire.
+type OrphanPool struct {
+	lock    sync.Mutex
+	max     int
+	ttl     time.Duration
+	orphans map[string]*orphan
+	// missing outpoint key => hashes of the orphans waiting for it
+	waiting map[string]map[string]bool
+}
+
+func NewOrphanPool(max int, ttl time.Duration) *OrphanPool {
+	return &OrphanPool{
+		max:     max,
+		ttl:     ttl,
+		orphans: make(map[string]*orphan),
+		waiting: make(map[string]map[string]bool),
+	}
+}
+
+func (pool *OrphanPool) Len() int {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	pool.expire(time.Now())
+	return len(pool.orphans)
+}
+
+// Add keeps tx until the output with the missing outpoint key shows up.
+// When the pool is full the orphan closest to expiring gets evicted.
+func (pool *OrphanPool) Add(tx *proto.Transaction, missing string) bool {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	now := time.Now()
+	pool.expire(now)
+
+	hash := hex.EncodeToString(types.HashTransaction(tx))
+	if _, ok := pool.orphans[hash]; ok {
+		return false
+	}
+	if len(pool.orphans) >= pool.max {
+		var oldest *orphan
+		for _, o := range pool.orphans {
+			if oldest == nil || o.expires.Before(oldest.expires) {
+				oldest = o
+			}
+		}
+		pool.remove(oldest)
+	}
+
+	pool.orphans[hash] = &orphan{
+		tx:      tx,
+		hash:    hash,
+		missing: missing,
+		expires: now.Add(pool.ttl),
+	}
+	if pool.waiting[missing] == nil {
+		pool.waiting[missing] = make(map[string]bool)
+	}
+	pool.waiting[missing][hash] = true
+	return true
+}
+
+// Take removes the orphans waiting for an output of parent from the pool
+// and returns them.
+func (pool *OrphanPool) Take(parent *proto.Transaction) []*proto.Transaction {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	pool.expire(time.Now())
+
+	var (
+		hash = hex.EncodeToString(types.HashTransaction(parent))
+		txx  = []*proto.Transaction{}
+	)
+	for i := range parent.Outputs {
+		for orphanHash := range pool.waiting[fmt.Sprintf("%s_%d", hash, i)] {
+			o := pool.orphans[orphanHash]
+			txx = append(txx, o.tx)
+			pool.remove(o)
+		}
+	}
+	return txx
+}
+
+func (pool *OrphanPool) expire(now time.Time) {
+	for _, o := range pool.orphans {
+		if now.After(o.expires) {
+			pool.remove(o)
+		}
+	}
+}
+
+func (pool *OrphanPool) remove(o *orphan) {
+	delete(pool.orphans, o.hash)
+	delete(pool.waiting[o.missing], o.hash)
+	if len(pool.waiting[o.missing]) == 0 {
+		delete(pool.waiting, o.missing)
+	}
+}
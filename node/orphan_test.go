@@ -0,0 +1,44 @@
+package node
+
+import (
+	"testing"
+	"time"
+
+	"github.com/s809616134/go-blocker/types"
+	"github.com/s809616134/go-blocker/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrphanPoolTake(t *testing.T) {
+	var (
+		pool   = NewOrphanPool(10, time.Minute)
+		parent = spendTx(util.RandomHash(), 0, 10)
+		child  = spendTx(types.HashTransaction(parent), 0, 5)
+		other  = spendTx(util.RandomHash(), 0, 5)
+	)
+	assert.True(t, pool.Add(child, outpointKey(child.Inputs[0])))
+	assert.False(t, pool.Add(child, outpointKey(child.Inputs[0])))
+	assert.True(t, pool.Add(other, outpointKey(other.Inputs[0])))
+	assert.Equal(t, 2, pool.Len())
+
+	assert.Empty(t, pool.Take(other))
+	assert.Len(t, pool.Take(parent), 1)
+	assert.Equal(t, 1, pool.Len())
+	assert.Empty(t, pool.Take(parent))
+}
+
+func TestOrphanPoolLimits(t *testing.T) {
+	pool := NewOrphanPool(2, time.Millisecond*20)
+	first := spendTx(util.RandomHash(), 0, 10)
+	pool.Add(first, outpointKey(first.Inputs[0]))
+	for i := 0; i < 2; i++ {
+		tx := spendTx(util.RandomHash(), 0, 10)
+		pool.Add(tx, outpointKey(tx.Inputs[0]))
+	}
+	// the oldest orphan made room
+	assert.Equal(t, 2, pool.Len())
+	assert.True(t, pool.Add(first, outpointKey(first.Inputs[0])))
+
+	time.Sleep(time.Millisecond * 40)
+	assert.Equal(t, 0, pool.Len())
+}
@@ -0,0 +1,165 @@
+package node
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/s809616134/go-blocker/consensus"
+	"github.com/s809616134/go-blocker/crypto"
+	"github.com/s809616134/go-blocker/proto"
+)
+
+// How far the timestamp of a block may be ahead of our clock
+const maxClockDrift = time.Second * 15
+
+// slot returns the slot a block with the given timestamp falls into on
+// top of a parent with parentTime. The first slot is 0.
+func slot(parentTime, timestamp int64, duration time.Duration) uint64 {
+	if duration <= 0 || timestamp <= parentTime {
+		return 0
+	}
+	return uint64((timestamp - parentTime) / int64(duration))
+}
+
+// SelectProposer picks the proposer of a slot on top of the block with
+// parentHash. Every validator gets picked with a chance proportional to
+// its stake, and every node picks the same one.
+func SelectProposer(validators []*Validator, parentHash []byte, slot uint64) *Validator {
+	var total uint64
+	for _, v := range validators {
+		total += uint64(v.Stake)
+	}
+	if total == 0 {
+		return nil
+	}
+
+	seed := make([]byte, len(parentHash)+8)
+	copy(seed, parentHash)
+	binary.BigEndian.PutUint64(seed[len(parentHash):], slot)
+	hash := sha256.Sum256(seed)
+
+	n := binary.BigEndian.Uint64(hash[:8]) % total
+	for _, v := range validators {
+		if n < uint64(v.Stake) {
+			return v
+		}
+		n -= uint64(v.Stake)
+	}
+	return nil
+}
+
+// Proposer returns the address of the validator that may propose the
+// next block with the given timestamp on top of the tip.
+func (c *Chain) Proposer(timestamp int64) (string, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	v, err := c.proposer(slot(c.tip.header.Timestamp, timestamp, c.staking.SlotDuration))
+	if err != nil {
+		return "", err
+	}
+	return v.Address, nil
+}
+
+// timestampSlot returns the slot of a block on top of the tip that was
+// not decided by the consensus, picked by its timestamp.
+func (c *Chain) timestampSlot(b *proto.Block) uint64 {
+	return slot(c.tip.header.Timestamp, b.Header.Timestamp, c.staking.SlotDuration)
+}
+
+func (c *Chain) proposer(s uint64) (*Validator, error) {
+	parentHash, err := hex.DecodeString(c.tip.hash)
+	if err != nil {
+		return nil, err
+	}
+	v := SelectProposer(c.validators(), parentHash, s)
+	if v == nil {
+		return nil, fmt.Errorf("no validators to propose block at height %d", c.tip.height+1)
+	}
+	return v, nil
+}
+
+// validateProposer checks the block is signed by the proposer of one
+// of the slots from to to, the block has to extend the tip. The slots of
+// a block decided by the consensus are the rounds up to the one it got
+// decided in, a block proposed again in a later round keeps the
+// signature of the validator that proposed it first.
+func (c *Chain) validateProposer(b *proto.Block, from, to uint64) error {
+	if len(b.PublicKey) != crypto.PubKeyLen {
+		return fmt.Errorf("invalid block public key length (%d)", len(b.PublicKey))
+	}
+	address := crypto.PublicKeyFromBytes(b.PublicKey).Address().String()
+
+	if !c.isValidator(address) {
+		return fmt.Errorf("block signer %s is not a validator", address)
+	}
+
+	for s := from; ; s++ {
+		proposer, err := c.proposer(s)
+		if err != nil {
+			return err
+		}
+		if proposer.Address == address {
+			return nil
+		}
+		if s == to {
+			return fmt.Errorf("block signer %s is not the proposer of slot %d, expected %s", address, s, proposer.Address)
+		}
+	}
+}
+
+// validatorSet is the set of validators deciding the block on top of
+// the block with parentHash.
+type validatorSet struct {
+	validators []*Validator
+	parentHash []byte
+}
+
+func (vs *validatorSet) Stake(address string) int64 {
+	for _, v := range vs.validators {
+		if v.Address == address {
+			return v.Stake
+		}
+	}
+	return 0
+}
+
+func (vs *validatorSet) TotalStake() int64 {
+	var total int64
+	for _, v := range vs.validators {
+		total += v.Stake
+	}
+	return total
+}
+
+// Proposer returns the proposer of the round, rounds are the slots of
+// the blocks decided by the consensus.
+func (vs *validatorSet) Proposer(round int32) string {
+	v := SelectProposer(vs.validators, vs.parentHash, uint64(round))
+	if v == nil {
+		return ""
+	}
+	return v.Address
+}
+
+// ValidatorSet returns the validators deciding the block on top of the
+// tip.
+func (c *Chain) ValidatorSet() (consensus.ValidatorSet, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.validatorSet()
+}
+
+func (c *Chain) validatorSet() (*validatorSet, error) {
+	parentHash, err := hex.DecodeString(c.tip.hash)
+	if err != nil {
+		return nil, err
+	}
+	return &validatorSet{
+		validators: c.validators(),
+		parentHash: parentHash,
+	}, nil
+}
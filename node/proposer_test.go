@@ -0,0 +1,111 @@
+package node
+
+import (
+	"testing"
+	"time"
+
+	"github.com/s809616134/go-blocker/crypto"
+	"github.com/s809616134/go-blocker/types"
+	"github.com/s809616134/go-blocker/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectProposer(t *testing.T) {
+	var (
+		validators = []*Validator{
+			{Address: "a", Stake: 100},
+			{Address: "b", Stake: 300},
+		}
+		parentHash = util.RandomHash()
+		picked     = map[string]int{}
+	)
+	assert.Nil(t, SelectProposer(nil, parentHash, 0))
+
+	for s := uint64(0); s < 1000; s++ {
+		v := SelectProposer(validators, parentHash, s)
+		require.NotNil(t, v)
+		// every node picks the same proposer
+		assert.Equal(t, v, SelectProposer(validators, parentHash, s))
+		picked[v.Address]++
+	}
+	// b has three times the stake of a
+	assert.InDelta(t, 750, picked["b"], 75)
+	assert.InDelta(t, 250, picked["a"], 75)
+}
+
+func TestSlot(t *testing.T) {
+	assert.Equal(t, uint64(0), slot(100, 100, 10))
+	assert.Equal(t, uint64(0), slot(100, 109, 10))
+	assert.Equal(t, uint64(1), slot(100, 110, 10))
+	assert.Equal(t, uint64(3), slot(100, 135, 10))
+}
+
+func TestAddBlockWrongProposer(t *testing.T) {
+	var (
+		chain   = newTestChain(t)
+		privKey = crypto.GeneratPrivateKey()
+		address = privKey.Public().Address()
+	)
+	chain.SetStakingParams(StakingParams{
+		MinStake:        100,
+		UnbondingPeriod: 100,
+		SlotDuration:    time.Nanosecond,
+	})
+
+	// bond a second validator
+	tx := spendGenesisTx(t, chain, 500)
+	tx.Outputs[0].Address = address.Bytes()
+	tx.Inputs[0].Signature = types.SignTransaction(GenesisValidatorKey(), tx).Bytes()
+	require.Nil(t, addSignedBlock(t, chain, GenesisValidatorKey(), tx))
+	utxos, err := chain.GetUnspent(address.Bytes())
+	require.Nil(t, err)
+	stake, err := NewStakeTransaction(DefaultChainID, privKey, utxos, 200, 0)
+	require.Nil(t, err)
+	signInputs(privKey, stake)
+	require.Nil(t, addSignedBlock(t, chain, GenesisValidatorKey(), stake))
+
+	validators, err := chain.Validators()
+	require.Nil(t, err)
+	require.Len(t, validators, 2)
+
+	block := randomBLock(t, chain)
+	proposer, err := chain.Proposer(block.Header.Timestamp)
+	require.Nil(t, err)
+	keys := map[string]*crypto.PrivateKey{
+		address.String(): privKey,
+		GenesisValidatorKey().Public().Address().String(): GenesisValidatorKey(),
+	}
+	for addr, key := range keys {
+		if addr != proposer {
+			types.SignBlock(key, block)
+			err := chain.AddBlock(block)
+			require.NotNil(t, err)
+			assert.Contains(t, err.Error(), "not the proposer")
+		}
+	}
+	types.SignBlock(keys[proposer], block)
+	require.Nil(t, chain.AddBlock(block))
+}
+
+func TestAddBlockTimestamp(t *testing.T) {
+	chain := newTestChain(t)
+	require.Nil(t, chain.AddBlock(randomBLock(t, chain)))
+	tip, err := chain.GetBlockByHeight(1)
+	require.Nil(t, err)
+
+	// not after the parent
+	block := randomBLock(t, chain)
+	block.Header.Timestamp = tip.Header.Timestamp
+	types.SignBlock(GenesisValidatorKey(), block)
+	require.NotNil(t, chain.AddBlock(block))
+
+	// claiming a slot far in the future
+	block = randomBLock(t, chain)
+	block.Header.Timestamp = time.Now().Add(time.Minute).UnixNano()
+	types.SignBlock(GenesisValidatorKey(), block)
+	require.NotNil(t, chain.AddBlock(block))
+
+	require.Nil(t, chain.AddBlock(randomBLock(t, chain)))
+	assert.Equal(t, 2, chain.Height())
+}
@@ -0,0 +1,26 @@
+package node
+
+// RewardSchedule determines the amount a coinbase tx may mint.
+type RewardSchedule struct {
+	// Reward of the first block
+	Initial int64
+	// Number of blocks after which the reward halves, 0 never halves.
+	HalvingInterval int
+}
+
+var DefaultRewardSchedule = RewardSchedule{
+	Initial:         50,
+	HalvingInterval: 100000,
+}
+
+// Reward returns the block reward at the given height.
+func (s RewardSchedule) Reward(height int) int64 {
+	if s.HalvingInterval <= 0 {
+		return s.Initial
+	}
+	halvings := height / s.HalvingInterval
+	if halvings >= 63 {
+		return 0
+	}
+	return s.Initial >> halvings
+}
@@ -0,0 +1,19 @@
+package node
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRewardScheduleHalving(t *testing.T) {
+	s := RewardSchedule{Initial: 100, HalvingInterval: 10}
+	assert.Equal(t, int64(100), s.Reward(0))
+	assert.Equal(t, int64(100), s.Reward(9))
+	assert.Equal(t, int64(50), s.Reward(10))
+	assert.Equal(t, int64(25), s.Reward(25))
+	assert.Equal(t, int64(0), s.Reward(10*64))
+
+	s.HalvingInterval = 0
+	assert.Equal(t, int64(100), s.Reward(1000000))
+}
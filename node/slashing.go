@@ -0,0 +1,188 @@
+package node
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+
+	"github.com/s809616134/go-blocker/proto"
+	"github.com/s809616134/go-blocker/types"
+)
+
+// includedEvidence is an evidence of the main chain.
+type includedEvidence struct {
+	// hex encoded address of the validator that double signed
+	offender string
+	// height of the block that included the evidence
+	height int
+}
+
+// validateBlockEvidence checks the evidence of the block on top of the
+// tip.
+func (c *Chain) validateBlockEvidence(b *proto.Block) error {
+	seen := make(map[string]bool, len(b.Evidence))
+	for _, e := range b.Evidence {
+		hash := hex.EncodeToString(types.HashEvidence(e))
+		if seen[hash] {
+			return fmt.Errorf("evidence %s included twice", hash)
+		}
+		seen[hash] = true
+		if err := c.validateEvidence(e, int(b.Header.Height)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateEvidence checks the evidence for the block at the given
+// height. The stake of the offender may be gone once its double sign
+// is older than the unbonding period, so older evidence is rejected.
+func (c *Chain) validateEvidence(e *proto.Evidence, height int) error {
+	hash := hex.EncodeToString(types.HashEvidence(e))
+	if err := types.VerifyEvidence(e); err != nil {
+		return fmt.Errorf("invalid evidence %s: %s", hash, err)
+	}
+	if chainID := types.EvidenceChainID(e); chainID != c.chainID {
+		return fmt.Errorf("evidence %s of chain %q", hash, chainID)
+	}
+	if _, ok := c.evidence[hash]; ok {
+		return fmt.Errorf("evidence %s already included", hash)
+	}
+	signed := int(types.EvidenceHeight(e))
+	if signed >= height {
+		return fmt.Errorf("evidence %s of height (%d) not below the block (%d)", hash, signed, height)
+	}
+	if signed+c.staking.UnbondingPeriod < height {
+		return fmt.Errorf("evidence %s of height (%d) older than the unbonding period", hash, signed)
+	}
+	if err := c.validateOffender(e); err != nil {
+		return fmt.Errorf("evidence %s: %s", hash, err)
+	}
+	return nil
+}
+
+// ValidateOffender checks the offender of the evidence was part of the
+// validator set deciding the height it double signed at, the only set
+// it can be convicted in. Evidence up to the block on top of the tip is
+// checked.
+func (c *Chain) ValidateOffender(e *proto.Evidence) error {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.validateOffender(e)
+}
+
+func (c *Chain) validateOffender(e *proto.Evidence) error {
+	height := int(types.EvidenceHeight(e))
+	validators, err := c.epochValidators(height)
+	if err != nil {
+		return err
+	}
+	offender := types.EvidenceOffender(e).String()
+	for _, v := range validators {
+		if v.Address == offender {
+			return nil
+		}
+	}
+	return fmt.Errorf("offender %s not a validator at height (%d)", offender, height)
+}
+
+// FilterEvidence returns the evidence of list that is valid in a block
+// on top of the tip.
+func (c *Chain) FilterEvidence(list []*proto.Evidence) []*proto.Evidence {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	valid := []*proto.Evidence{}
+	for _, e := range list {
+		if c.validateEvidence(e, c.tip.height+1) == nil {
+			valid = append(valid, e)
+		}
+	}
+	return valid
+}
+
+// slash burns the stake of the validators convicted by the evidence of
+// the block: the stake bonded to them and the stake still unbonding from
+// them, delegations included. The burned outputs are recorded as spent
+// in the undo record of the block.
+func (c *Chain) slash(batch *batch, undo *BlockUndo, b *proto.Block, height int) error {
+	for _, e := range b.Evidence {
+		offender := types.EvidenceOffender(e).String()
+		bonded, err := batch.listBonded()
+		if err != nil {
+			return err
+		}
+		unbonding, err := batch.listUnbonding()
+		if err != nil {
+			return err
+		}
+
+		burn := []*UTXO{}
+		for _, utxo := range bonded {
+			if utxo.Validator == offender {
+				burn = append(burn, utxo)
+			}
+		}
+		for _, utxo := range unbonding {
+			if utxo.Validator == offender && utxo.LockHeight > height {
+				burn = append(burn, utxo)
+			}
+		}
+		for _, utxo := range burn {
+			spent := *utxo
+			undo.Spent = append(undo.Spent, &spent)
+			utxo.Spent = true
+			batch.putUTXO(utxo)
+		}
+	}
+	return nil
+}
+
+// includeEvidence records the evidence of the block connected to the
+// main chain at the given height.
+func (c *Chain) includeEvidence(b *proto.Block, height int) {
+	for _, e := range b.Evidence {
+		c.evidence[hex.EncodeToString(types.HashEvidence(e))] = &includedEvidence{
+			offender: types.EvidenceOffender(e).String(),
+			height:   height,
+		}
+	}
+}
+
+// excludeEvidence forgets the evidence of the block at the given height
+// once it got disconnected.
+func (c *Chain) excludeEvidence(height int) {
+	for hash, e := range c.evidence {
+		if e.height == height {
+			delete(c.evidence, hash)
+		}
+	}
+}
+
+// jailed returns the addresses left out of the validator set taken
+// after the block at the given height, the ones convicted within the
+// jail period.
+func (c *Chain) jailed(height int) map[string]bool {
+	jailed := make(map[string]bool)
+	for _, e := range c.evidence {
+		if e.height+c.staking.JailPeriod > height {
+			jailed[e.offender] = true
+		}
+	}
+	return jailed
+}
+
+// Jailed returns the addresses convicted of double signing within the
+// jail period, sorted. They are left out of the validator set of the
+// next epoch.
+func (c *Chain) Jailed() []string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	jailed := []string{}
+	for address := range c.jailed(c.tip.height) {
+		jailed = append(jailed, address)
+	}
+	sort.Strings(jailed)
+	return jailed
+}
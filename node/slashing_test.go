@@ -0,0 +1,209 @@
+package node
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/s809616134/go-blocker/crypto"
+	"github.com/s809616134/go-blocker/proto"
+	"github.com/s809616134/go-blocker/types"
+	"github.com/s809616134/go-blocker/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// proposedHeader returns the header of a random block privKey proposed
+// in the given round.
+func proposedHeader(privKey *crypto.PrivateKey, height, round int32) *proto.SignedHeader {
+	p := &proto.Proposal{
+		Block:    util.RandomBlock(),
+		Round:    round,
+		PolRound: -1,
+	}
+	p.Block.Header.Height = height
+	p.Block.Header.ChainID = DefaultChainID
+	types.SignProposal(privKey, p)
+	return types.SignedHeaderOf(p)
+}
+
+func doubleSign(privKey *crypto.PrivateKey, height int32) *proto.Evidence {
+	return types.NewEvidence(proposedHeader(privKey, height, 0), proposedHeader(privKey, height, 0))
+}
+
+func addEvidenceBlock(t *testing.T, chain *Chain, privKey *crypto.PrivateKey, evidence ...*proto.Evidence) error {
+	block := signedBlock(t, chain, privKey)
+	block.Evidence = evidence
+	types.SignBlock(privKey, block)
+	return chain.AddBlock(block)
+}
+
+func TestSlashDoubleSign(t *testing.T) {
+	var (
+		chain   = newTestChain(t)
+		god     = GenesisValidatorKey()
+		privKey = crypto.GeneratPrivateKey()
+		address = privKey.Public().Address()
+	)
+	chain.SetStakingParams(StakingParams{
+		MinStake:        100,
+		UnbondingPeriod: 10,
+		SlotDuration:    time.Nanosecond,
+		JailPeriod:      2,
+	})
+
+	// fund and bond a second validator, which unbonds part of its stake
+	tx := spendGenesisTx(t, chain, 500)
+	tx.Outputs[0].Address = address.Bytes()
+	tx.Inputs[0].Signature = types.SignTransaction(god, tx).Bytes()
+	require.Nil(t, addSignedBlock(t, chain, god, tx))
+
+	utxos, err := chain.GetUnspent(address.Bytes())
+	require.Nil(t, err)
+	stake, err := NewStakeTransaction(DefaultChainID, privKey, utxos, 200, 0)
+	require.Nil(t, err)
+	signInputs(privKey, stake)
+	require.Nil(t, addSignedBlock(t, chain, god, stake))
+
+	bonded := &UTXO{
+		Hash:     hex.EncodeToString(types.HashTransaction(stake)),
+		OutIndex: 0,
+		Amount:   200,
+	}
+	unstake, err := NewUnstakeTransaction(DefaultChainID, privKey, []*UTXO{bonded}, 50, 0)
+	require.Nil(t, err)
+	signInputs(privKey, unstake)
+	require.Nil(t, addSignedBlock(t, chain, god, unstake))
+
+	validators, err := chain.Validators()
+	require.Nil(t, err)
+	require.Len(t, validators, 2)
+
+	// a double sign has to be in the past
+	err = addEvidenceBlock(t, chain, god, doubleSign(privKey, int32(chain.Height()+1)))
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "not below the block")
+	// of a height the offender was a validator at
+	err = addEvidenceBlock(t, chain, god, doubleSign(privKey, 1))
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "not a validator at height")
+	err = addEvidenceBlock(t, chain, god, doubleSign(crypto.GeneratPrivateKey(), int32(chain.Height())))
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "not a validator at height")
+
+	// the bonded and the unbonding stake get burned
+	e := doubleSign(privKey, int32(chain.Height()))
+	require.Nil(t, addEvidenceBlock(t, chain, god, e))
+	balance, err := chain.GetBalance(address.Bytes())
+	require.Nil(t, err)
+	assert.Equal(t, int64(300), balance)
+	ok, err := chain.IsValidator(address)
+	require.Nil(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, []string{address.String()}, chain.Jailed())
+
+	// nobody gets convicted twice by the same evidence
+	err = addEvidenceBlock(t, chain, god, e)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "already included")
+
+	// new stake doesn't get the offender out of jail early
+	utxos, err = chain.GetUnspent(address.Bytes())
+	require.Nil(t, err)
+	stake, err = NewStakeTransaction(DefaultChainID, privKey, utxos, 100, 0)
+	require.Nil(t, err)
+	signInputs(privKey, stake)
+	require.Nil(t, addSignedBlock(t, chain, god, stake))
+	ok, err = chain.IsValidator(address)
+	require.Nil(t, err)
+	assert.False(t, ok)
+
+	require.Nil(t, addSignedBlock(t, chain, god))
+	ok, err = chain.IsValidator(address)
+	require.Nil(t, err)
+	assert.True(t, ok)
+	assert.Empty(t, chain.Jailed())
+
+	// disconnecting the block with the evidence gives the stake back
+	for i := 0; i < 3; i++ {
+		_, err = chain.DisconnectTip()
+		require.Nil(t, err)
+	}
+	balance, err = chain.GetBalance(address.Bytes())
+	require.Nil(t, err)
+	assert.Equal(t, int64(500), balance)
+	validators, err = chain.Validators()
+	require.Nil(t, err)
+	require.Len(t, validators, 2)
+
+	// and the evidence can be included again
+	block := signedBlock(t, chain, god)
+	block.Evidence = []*proto.Evidence{e}
+	types.SignBlock(god, block)
+	require.Nil(t, chain.ValidateBlock(block))
+}
+
+func TestSlashUnstakedToAnotherAddress(t *testing.T) {
+	var (
+		chain   = newTestChain(t)
+		god     = GenesisValidatorKey()
+		privKey = crypto.GeneratPrivateKey()
+		address = privKey.Public().Address()
+		other   = crypto.GeneratPrivateKey().Public().Address()
+	)
+	chain.SetStakingParams(StakingParams{
+		MinStake:        100,
+		UnbondingPeriod: 10,
+		SlotDuration:    time.Nanosecond,
+		JailPeriod:      2,
+	})
+	fundAddress(t, chain, address, 500)
+	stake := stakeAddress(t, chain, privKey, 200)
+
+	// the offender unstakes to a fresh address before it gets convicted
+	e := doubleSign(privKey, int32(chain.Height()+1))
+	bonded := &UTXO{
+		Hash:   hex.EncodeToString(types.HashTransaction(stake)),
+		Amount: 200,
+	}
+	unstake, err := NewUnstakeTransaction(DefaultChainID, privKey, []*UTXO{bonded}, 200, 0)
+	require.Nil(t, err)
+	unstake.Outputs[0].Address = other.Bytes()
+	signInputs(privKey, unstake)
+	require.Nil(t, addSignedBlock(t, chain, god, unstake))
+	balance, err := chain.GetBalance(other.Bytes())
+	require.Nil(t, err)
+	assert.Equal(t, int64(200), balance)
+
+	require.Nil(t, addEvidenceBlock(t, chain, god, e))
+	balance, err = chain.GetBalance(other.Bytes())
+	require.Nil(t, err)
+	assert.Equal(t, int64(0), balance)
+}
+
+func TestSlashReloadFromDisk(t *testing.T) {
+	dir := t.TempDir()
+	blockStore, txStore, utxoStore := openDiskStores(t, dir)
+	chain, err := NewChain(DefaultChainID, blockStore, txStore, utxoStore)
+	require.Nil(t, err)
+	params := DefaultStakingParams
+	params.EpochLength = 1
+	chain.SetStakingParams(params)
+
+	// the only validator convicts itself
+	god := GenesisValidatorKey()
+	require.Nil(t, addSignedBlock(t, chain, god))
+	require.Nil(t, addEvidenceBlock(t, chain, god, doubleSign(god, 1)))
+	validators, err := chain.Validators()
+	require.Nil(t, err)
+	assert.Empty(t, validators)
+
+	require.Nil(t, blockStore.Close())
+	require.Nil(t, txStore.Close())
+	require.Nil(t, utxoStore.Close())
+
+	blockStore, txStore, utxoStore = openDiskStores(t, dir)
+	chain, err = NewChain(DefaultChainID, blockStore, txStore, utxoStore)
+	require.Nil(t, err)
+	assert.Equal(t, []string{god.Public().Address().String()}, chain.Jailed())
+}
@@ -0,0 +1,373 @@
+package node
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/s809616134/go-blocker/crypto"
+	"github.com/s809616134/go-blocker/proto"
+	"github.com/s809616134/go-blocker/types"
+)
+
+type StakingParams struct {
+	// Minimum stake an address needs bonded to be a validator
+	MinStake int64
+	// Number of blocks the outputs of an unstake tx stay locked
+	UnbondingPeriod int
+	// Length of a proposer slot, counted from the timestamp of the
+	// parent block
+	SlotDuration time.Duration
+	// Number of blocks a validator convicted of double signing is left
+	// out of the validator set, its stake gets burned as well
+	JailPeriod int
+	// Number of blocks of an epoch. Staking changes take effect once
+	// the last block of an epoch got connected, the validator set
+	// stays the same within an epoch. 0 makes every block an epoch.
+	EpochLength int
+}
+
+var DefaultStakingParams = StakingParams{
+	MinStake:        100,
+	UnbondingPeriod: 100,
+	SlotDuration:    blockTime,
+	JailPeriod:      1000,
+	EpochLength:     20,
+}
+
+type Validator struct {
+	// hex encoded address
+	Address string
+	// bonded by the validator and delegated to it
+	Stake int64
+	// bonded by the validator itself
+	SelfStake int64
+	// Percent of the rewards of its blocks the validator keeps
+	// before the rest is shared with the stake bonded to it
+	Commission int
+}
+
+// SetStakingParams replaces the staking params blocks are validated
+// against.
+func (c *Chain) SetStakingParams(p StakingParams) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.staking = p
+}
+
+// Validators returns the validator set of the current epoch, the one
+// deciding the block on top of the tip, sorted by address. Staking
+// changes since the epoch started show up in the set of the next one.
+func (c *Chain) Validators() ([]*Validator, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return copyValidators(c.validators()), nil
+}
+
+func (c *Chain) validators() []*Validator {
+	return c.tip.nextValidators
+}
+
+func copyValidators(validators []*Validator) []*Validator {
+	list := make([]*Validator, len(validators))
+	for i, v := range validators {
+		val := *v
+		list[i] = &val
+	}
+	return list
+}
+
+// bondedStakes returns the stake bonded to every address that bonded
+// stake itself, by address.
+func bondedStakes(bonded []*UTXO) map[string]*Validator {
+	stakes := make(map[string]*Validator)
+	for _, utxo := range bonded {
+		if utxo.Validator != utxo.Address {
+			continue
+		}
+		v, ok := stakes[utxo.Address]
+		if !ok {
+			v = &Validator{Address: utxo.Address}
+			stakes[utxo.Address] = v
+		}
+		v.SelfStake += utxo.Amount
+	}
+	for _, utxo := range bonded {
+		if v, ok := stakes[utxo.Validator]; ok {
+			v.Stake += utxo.Amount
+		}
+	}
+	return stakes
+}
+
+// commissionChange is a commission set by a stake tx of the main chain.
+type commissionChange struct {
+	commission int
+	height     int
+}
+
+// includeCommissions records the commissions set by the stake txs of
+// the block connected to the main chain at the given height.
+func (c *Chain) includeCommissions(b *proto.Block, height int) {
+	for address, commission := range setCommissions(b) {
+		c.commissions[address] = append(c.commissions[address], &commissionChange{
+			commission: commission,
+			height:     height,
+		})
+	}
+}
+
+// excludeCommissions forgets the commissions set by the block at the
+// given height once it got disconnected.
+func (c *Chain) excludeCommissions(height int) {
+	for address, changes := range c.commissions {
+		if last := len(changes) - 1; changes[last].height == height {
+			if last == 0 {
+				delete(c.commissions, address)
+			} else {
+				c.commissions[address] = changes[:last]
+			}
+		}
+	}
+}
+
+// commission returns the commission the last stake tx of the address
+// on the main chain set.
+func (c *Chain) commission(address string) int {
+	changes := c.commissions[address]
+	if len(changes) == 0 {
+		return 0
+	}
+	return changes[len(changes)-1].commission
+}
+
+// setCommissions returns the commissions the stake txs of the block
+// set, by the address of their signer. A stake tx without a commission
+// keeps the one set before.
+func setCommissions(b *proto.Block) map[string]int {
+	commissions := make(map[string]int)
+	for _, tx := range b.Transactions {
+		if tx.Type != proto.TxType_STAKE || tx.Commission == 0 {
+			continue
+		}
+		signer := crypto.PublicKeyFromBytes(tx.Inputs[0].PublicKey).Address()
+		commissions[signer.String()] = int(tx.Commission)
+	}
+	return commissions
+}
+
+// selectValidators returns the validator set of the bonded outputs, the
+// addresses with at least the minimum stake bonded themselves that are
+// not jailed, sorted by address. Their stake includes the stake
+// delegated to them.
+func (c *Chain) selectValidators(bonded []*UTXO, jailed map[string]bool) []*Validator {
+	validators := []*Validator{}
+	for address, v := range bondedStakes(bonded) {
+		if v.SelfStake >= c.staking.MinStake && !jailed[address] {
+			validators = append(validators, v)
+		}
+	}
+	sort.Slice(validators, func(i, j int) bool {
+		return validators[i].Address < validators[j].Address
+	})
+	return validators
+}
+
+// IsValidator reports whether the address is part of the validator set
+// of the current epoch.
+func (c *Chain) IsValidator(address crypto.Address) (bool, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.isValidator(address.String()), nil
+}
+
+func (c *Chain) isValidator(address string) bool {
+	for _, v := range c.validators() {
+		if v.Address == address {
+			return true
+		}
+	}
+	return false
+}
+
+// outputUTXOs returns the UTXOs created by tx in the block at the given
+// height.
+func (c *Chain) outputUTXOs(tx *proto.Transaction, height int) []*UTXO {
+	hash := hex.EncodeToString(types.HashTransaction(tx))
+	utxos := make([]*UTXO, len(tx.Outputs))
+	for i, output := range tx.Outputs {
+		utxos[i] = &UTXO{
+			Hash:     hash,
+			OutIndex: i,
+			Amount:   output.Amount,
+			Address:  hex.EncodeToString(output.Address),
+			Height:   height,
+		}
+		switch tx.Type {
+		case proto.TxType_STAKE, proto.TxType_UNSTAKE:
+			utxos[i].Validator = utxos[i].Address
+			// the unstaked output may pay anyone, it stays the
+			// stake of its signer until it is unlocked
+			if tx.Type == proto.TxType_UNSTAKE && i == 0 {
+				utxos[i].Validator = crypto.PublicKeyFromBytes(tx.Inputs[0].PublicKey).Address().String()
+			}
+		case proto.TxType_DELEGATE, proto.TxType_UNDELEGATE:
+			utxos[i].Validator = hex.EncodeToString(tx.Validator)
+		}
+		switch tx.Type {
+		case proto.TxType_STAKE, proto.TxType_DELEGATE:
+			utxos[i].Bonded = i == 0
+		case proto.TxType_UNSTAKE, proto.TxType_UNDELEGATE:
+			// only the first output gets unbonded
+			if i == 0 {
+				utxos[i].LockHeight = height + c.staking.UnbondingPeriod
+			} else {
+				utxos[i].Bonded = true
+			}
+		}
+		// the change of a staking tx is not bonded to anyone
+		if !utxos[i].Bonded && utxos[i].LockHeight == 0 {
+			utxos[i].Validator = ""
+		}
+	}
+	return utxos
+}
+
+// validateStaking checks the staking rules for tx spending the given
+// UTXOs in the block at the given height.
+func (c *Chain) validateStaking(tx *proto.Transaction, spends []*UTXO, height int) error {
+	txHash := hex.EncodeToString(types.HashTransaction(tx))
+	for i, utxo := range spends {
+		if utxo.LockHeight > height {
+			return fmt.Errorf("input %d of tx %s is locked until height %d", i, txHash, utxo.LockHeight)
+		}
+		if err := validateBondedInput(tx, utxo); err != nil {
+			return fmt.Errorf("input %d of %s", i, err)
+		}
+	}
+
+	switch tx.Type {
+	case proto.TxType_TRANSFER:
+	case proto.TxType_STAKE, proto.TxType_DELEGATE:
+		if len(tx.Inputs) == 0 || len(tx.Outputs) == 0 {
+			return fmt.Errorf("%s tx %s needs an input and an output", strings.ToLower(tx.Type.String()), txHash)
+		}
+		if tx.Outputs[0].Amount <= 0 {
+			return fmt.Errorf("%s tx %s bonds no stake", strings.ToLower(tx.Type.String()), txHash)
+		}
+		// the bonded stake stays with the signer
+		signer := crypto.PublicKeyFromBytes(tx.Inputs[0].PublicKey).Address()
+		if hex.EncodeToString(tx.Outputs[0].Address) != signer.String() {
+			return fmt.Errorf("%s tx %s bonds stake to another address than its signer", strings.ToLower(tx.Type.String()), txHash)
+		}
+		if tx.Type == proto.TxType_DELEGATE {
+			return c.validateDelegate(tx, signer)
+		}
+	case proto.TxType_UNSTAKE, proto.TxType_UNDELEGATE:
+		if len(tx.Inputs) == 0 || len(tx.Outputs) == 0 {
+			return fmt.Errorf("%s tx %s needs an input and an output", strings.ToLower(tx.Type.String()), txHash)
+		}
+		// the stake left bonded stays with the signer
+		signer := crypto.PublicKeyFromBytes(tx.Inputs[0].PublicKey).Address()
+		for _, output := range tx.Outputs[1:] {
+			if hex.EncodeToString(output.Address) != signer.String() {
+				return fmt.Errorf("%s tx %s bonds stake to another address than its signer", strings.ToLower(tx.Type.String()), txHash)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown type %s of tx %s", tx.Type, txHash)
+	}
+	if tx.Commission < 0 || tx.Commission > 100 {
+		return fmt.Errorf("invalid commission (%d) of tx %s", tx.Commission, txHash)
+	}
+	// the validator keeps its commission when its stake changes
+	// otherwise
+	if tx.Commission != 0 && tx.Type != proto.TxType_STAKE {
+		return fmt.Errorf("%s tx %s sets a commission", strings.ToLower(tx.Type.String()), txHash)
+	}
+	return nil
+}
+
+// validateBondedInput checks that only an unstake tx spends the own
+// stake of a validator and only an undelegate tx of the same validator
+// spends stake delegated to it.
+func validateBondedInput(tx *proto.Transaction, utxo *UTXO) error {
+	var (
+		txHash    = hex.EncodeToString(types.HashTransaction(tx))
+		delegated = utxo.Validator != utxo.Address
+	)
+	switch tx.Type {
+	case proto.TxType_UNSTAKE:
+		if !utxo.Bonded {
+			return fmt.Errorf("unstake tx %s is not bonded", txHash)
+		}
+		if delegated {
+			return fmt.Errorf("unstake tx %s is delegated, only an undelegate tx can spend it", txHash)
+		}
+	case proto.TxType_UNDELEGATE:
+		if !utxo.Bonded || !delegated {
+			return fmt.Errorf("undelegate tx %s is not delegated", txHash)
+		}
+		if utxo.Validator != hex.EncodeToString(tx.Validator) {
+			return fmt.Errorf("undelegate tx %s is delegated to another validator", txHash)
+		}
+	default:
+		if utxo.Bonded {
+			return fmt.Errorf("tx %s is bonded, only an unstake or undelegate tx can spend it", txHash)
+		}
+	}
+	return nil
+}
+
+// NewStakeTransaction returns an unsigned tx of the chain bonding amount
+// of the given UTXOs, owned by privKey, as stake. The rest minus fee
+// goes back to privKey as change.
+func NewStakeTransaction(chainID string, privKey *crypto.PrivateKey, utxos []*UTXO, amount, fee int64) (*proto.Transaction, error) {
+	return newStakingTransaction(proto.TxType_STAKE, chainID, privKey, utxos, amount, fee)
+}
+
+// NewUnstakeTransaction returns an unsigned tx of the chain unbonding
+// amount of the given bonded UTXOs, owned by privKey. The rest minus fee
+// stays bonded.
+func NewUnstakeTransaction(chainID string, privKey *crypto.PrivateKey, utxos []*UTXO, amount, fee int64) (*proto.Transaction, error) {
+	return newStakingTransaction(proto.TxType_UNSTAKE, chainID, privKey, utxos, amount, fee)
+}
+
+func newStakingTransaction(txType proto.TxType, chainID string, privKey *crypto.PrivateKey, utxos []*UTXO, amount, fee int64) (*proto.Transaction, error) {
+	tx := &proto.Transaction{
+		Version: 1,
+		ChainID: chainID,
+		Type:    txType,
+	}
+	var sum int64
+	for _, utxo := range utxos {
+		hash, err := hex.DecodeString(utxo.Hash)
+		if err != nil {
+			return nil, err
+		}
+		tx.Inputs = append(tx.Inputs, &proto.TxInput{
+			PrevTxHash:   hash,
+			PrevOutIndex: uint32(utxo.OutIndex),
+			PublicKey:    privKey.Public().Bytes(),
+		})
+		sum += utxo.Amount
+	}
+	if sum < amount+fee {
+		return nil, fmt.Errorf("insufficient balance got (%d) spending (%d)", sum, amount+fee)
+	}
+
+	address := privKey.Public().Address().Bytes()
+	tx.Outputs = append(tx.Outputs, &proto.TxOutput{
+		Amount:  amount,
+		Address: address,
+	})
+	if change := sum - amount - fee; change > 0 {
+		tx.Outputs = append(tx.Outputs, &proto.TxOutput{
+			Amount:  change,
+			Address: address,
+		})
+	}
+	return tx, nil
+}
@@ -0,0 +1,162 @@
+package node
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/s809616134/go-blocker/crypto"
+	"github.com/s809616134/go-blocker/proto"
+	"github.com/s809616134/go-blocker/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func addSignedBlock(t *testing.T, chain *Chain, privKey *crypto.PrivateKey, txx ...*proto.Transaction) error {
+	return chain.AddBlock(signedBlock(t, chain, privKey, txx...))
+}
+
+// signedBlock returns a block on top of the tip signed by privKey in one
+// of its slots.
+func signedBlock(t *testing.T, chain *Chain, privKey *crypto.PrivateKey, txx ...*proto.Transaction) *proto.Block {
+	block := randomBLock(t, chain)
+	block.Transactions = append(block.Transactions, txx...)
+	// move the block into a slot of privKey
+	for i := 0; i < 1000; i++ {
+		proposer, err := chain.Proposer(block.Header.Timestamp)
+		require.Nil(t, err)
+		if proposer == privKey.Public().Address().String() {
+			break
+		}
+		block.Header.Timestamp++
+	}
+	types.SignBlock(privKey, block)
+	return block
+}
+
+func signInputs(privKey *crypto.PrivateKey, tx *proto.Transaction) {
+	sig := types.SignTransaction(privKey, tx).Bytes()
+	for _, input := range tx.Inputs {
+		input.Signature = sig
+	}
+}
+
+func TestGenesisValidator(t *testing.T) {
+	chain := newTestChain(t)
+
+	validators, err := chain.Validators()
+	require.Nil(t, err)
+	require.Len(t, validators, 1)
+	assert.Equal(t, GenesisValidatorKey().Public().Address().String(), validators[0].Address)
+	assert.Equal(t, DefaultStakingParams.MinStake, validators[0].Stake)
+
+	// blocks signed by anyone else are rejected
+	err = addSignedBlock(t, chain, crypto.GeneratPrivateKey())
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "not a validator")
+	assert.Equal(t, 0, chain.Height())
+}
+
+func TestStakeAndUnstake(t *testing.T) {
+	var (
+		chain   = newTestChain(t)
+		privKey = crypto.GeneratPrivateKey()
+		address = privKey.Public().Address()
+	)
+	chain.SetStakingParams(StakingParams{
+		MinStake:        100,
+		UnbondingPeriod: 2,
+		SlotDuration:    time.Nanosecond,
+	})
+
+	// fund the new validator
+	tx := spendGenesisTx(t, chain, 500)
+	tx.Outputs[0].Address = address.Bytes()
+	tx.Inputs[0].Signature = types.SignTransaction(GenesisValidatorKey(), tx).Bytes()
+	require.Nil(t, addSignedBlock(t, chain, GenesisValidatorKey(), tx))
+
+	ok, err := chain.IsValidator(address)
+	require.Nil(t, err)
+	assert.False(t, ok)
+	require.NotNil(t, addSignedBlock(t, chain, privKey))
+
+	// bonding stake for someone else is not allowed
+	utxos, err := chain.GetUnspent(address.Bytes())
+	require.Nil(t, err)
+	stake, err := NewStakeTransaction(DefaultChainID, privKey, utxos, 100, 0)
+	require.Nil(t, err)
+	stake.Outputs[0].Address = crypto.GeneratPrivateKey().Public().Address().Bytes()
+	signInputs(privKey, stake)
+	require.NotNil(t, chain.ValidateTransaction(stake))
+
+	stake, err = NewStakeTransaction(DefaultChainID, privKey, utxos, 100, 0)
+	require.Nil(t, err)
+	signInputs(privKey, stake)
+	require.Nil(t, addSignedBlock(t, chain, GenesisValidatorKey(), stake))
+
+	ok, err = chain.IsValidator(address)
+	require.Nil(t, err)
+	assert.True(t, ok)
+	require.Nil(t, addSignedBlock(t, chain, privKey))
+
+	bonded := &UTXO{
+		Hash:     hex.EncodeToString(types.HashTransaction(stake)),
+		OutIndex: 0,
+		Amount:   100,
+	}
+
+	// the bonded output can't be transferred
+	transfer := &proto.Transaction{
+		Version: 1,
+		ChainID: DefaultChainID,
+		Inputs: []*proto.TxInput{
+			{
+				PrevTxHash: types.HashTransaction(stake),
+				PublicKey:  privKey.Public().Bytes(),
+			},
+		},
+		Outputs: []*proto.TxOutput{
+			{
+				Amount:  100,
+				Address: address.Bytes(),
+			},
+		},
+	}
+	signInputs(privKey, transfer)
+	err = chain.ValidateTransaction(transfer)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "bonded")
+
+	// unbonding drops the validator and locks the output for the
+	// unbonding period
+	unstake, err := NewUnstakeTransaction(DefaultChainID, privKey, []*UTXO{bonded}, 100, 0)
+	require.Nil(t, err)
+	signInputs(privKey, unstake)
+	require.Nil(t, addSignedBlock(t, chain, GenesisValidatorKey(), unstake))
+	height := chain.Height()
+
+	ok, err = chain.IsValidator(address)
+	require.Nil(t, err)
+	assert.False(t, ok)
+
+	unspent, err := chain.GetUnspent(address.Bytes())
+	require.Nil(t, err)
+	var locked *UTXO
+	for _, utxo := range unspent {
+		if utxo.Hash == hex.EncodeToString(types.HashTransaction(unstake)) {
+			locked = utxo
+		}
+	}
+	require.NotNil(t, locked)
+	assert.False(t, locked.Bonded)
+	assert.Equal(t, height+2, locked.LockHeight)
+
+	transfer.Inputs[0].PrevTxHash = types.HashTransaction(unstake)
+	signInputs(privKey, transfer)
+	err = chain.ValidateTransaction(transfer)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "locked")
+
+	require.Nil(t, addSignedBlock(t, chain, GenesisValidatorKey()))
+	require.Nil(t, chain.ValidateTransaction(transfer))
+}
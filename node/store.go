@@ -1,10 +1,20 @@
 package node
 
 import (
+	"bufio"
+	"encoding/binary"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
 	"sync"
 
+	pb "github.com/golang/protobuf/proto"
 	"github.com/s809616134/go-blocker/proto"
 	"github.com/s809616134/go-blocker/types"
 )
@@ -12,16 +22,119 @@ import (
 type UTXOStorer interface {
 	PUT(*UTXO) error
 	Get(string) (*UTXO, error)
+	Delete(string) error
+	// The unspent UTXOs paying to the given hex encoded address
+	ListByAddress(string) ([]*UTXO, error)
+	// The unspent UTXOs bonded as stake
+	ListBonded() ([]*UTXO, error)
+	// The unspent UTXOs unbonded by an unstake or undelegate tx,
+	// locked or not
+	ListUnbonding() ([]*UTXO, error)
+	// The hash of the block the UTXO set is up to date with
+	PutBestBlock(string) error
+	GetBestBlock() (string, error)
+}
+
+// addressIndex maps an address to the keys of its unspent UTXOs.
+type addressIndex map[string]map[string]bool
+
+func (idx addressIndex) put(key string, utxo *UTXO) {
+	if utxo.Spent {
+		idx.delete(key, utxo)
+		return
+	}
+	keys, ok := idx[utxo.Address]
+	if !ok {
+		keys = make(map[string]bool)
+		idx[utxo.Address] = keys
+	}
+	keys[key] = true
+}
+
+func (idx addressIndex) delete(key string, utxo *UTXO) {
+	keys, ok := idx[utxo.Address]
+	if !ok {
+		return
+	}
+	delete(keys, key)
+	if len(keys) == 0 {
+		delete(idx, utxo.Address)
+	}
+}
+
+func (idx addressIndex) list(address string, data map[string]*UTXO) []*UTXO {
+	utxos := []*UTXO{}
+	for key := range idx[address] {
+		utxos = append(utxos, data[key])
+	}
+	sortUTXOs(utxos)
+	return utxos
+}
+
+// all returns the UTXOs of every address.
+func (idx addressIndex) all(data map[string]*UTXO) []*UTXO {
+	utxos := []*UTXO{}
+	for _, keys := range idx {
+		for key := range keys {
+			utxos = append(utxos, data[key])
+		}
+	}
+	sortUTXOs(utxos)
+	return utxos
+}
+
+func sortUTXOs(utxos []*UTXO) {
+	sort.Slice(utxos, func(i, j int) bool {
+		if utxos[i].Hash == utxos[j].Hash {
+			return utxos[i].OutIndex < utxos[j].OutIndex
+		}
+		return utxos[i].Hash < utxos[j].Hash
+	})
+}
+
+// filteredIndex is an addressIndex of the unspent UTXOs matching its
+// filter only.
+type filteredIndex struct {
+	addressIndex
+	match func(*UTXO) bool
+}
+
+func newBondedIndex() filteredIndex {
+	return filteredIndex{make(addressIndex), func(utxo *UTXO) bool {
+		return utxo.Bonded
+	}}
+}
+
+// the outputs of staking txs keep their validator only while they are
+// bonded or unbonding
+func newUnbondingIndex() filteredIndex {
+	return filteredIndex{make(addressIndex), func(utxo *UTXO) bool {
+		return !utxo.Bonded && utxo.Validator != ""
+	}}
+}
+
+func (idx filteredIndex) put(key string, utxo *UTXO) {
+	if !idx.match(utxo) {
+		return
+	}
+	idx.addressIndex.put(key, utxo)
 }
 
 type MemoryUTXOStore struct {
-	lock sync.RWMutex
-	data map[string]*UTXO
+	lock      sync.RWMutex
+	data      map[string]*UTXO
+	byAddress addressIndex
+	bonded    filteredIndex
+	unbonding filteredIndex
+	bestBlock string
 }
 
 func NewMemoryUTXOStore() *MemoryUTXOStore {
 	return &MemoryUTXOStore{
-		data: make(map[string]*UTXO),
+		data:      make(map[string]*UTXO),
+		byAddress: make(addressIndex),
+		bonded:    newBondedIndex(),
+		unbonding: newUnbondingIndex(),
 	}
 }
 
@@ -42,14 +155,72 @@ func (s *MemoryUTXOStore) PUT(utxo *UTXO) error {
 	defer s.lock.Unlock()
 
 	key := fmt.Sprintf("%s_%d", utxo.Hash, utxo.OutIndex)
+	if prev, ok := s.data[key]; ok {
+		s.byAddress.delete(key, prev)
+		s.bonded.delete(key, prev)
+		s.unbonding.delete(key, prev)
+	}
 	s.data[key] = utxo
+	s.byAddress.put(key, utxo)
+	s.bonded.put(key, utxo)
+	s.unbonding.put(key, utxo)
+
+	return nil
+}
+
+func (s *MemoryUTXOStore) Delete(key string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
 
+	if prev, ok := s.data[key]; ok {
+		s.byAddress.delete(key, prev)
+		s.bonded.delete(key, prev)
+		s.unbonding.delete(key, prev)
+	}
+	delete(s.data, key)
 	return nil
 }
 
+func (s *MemoryUTXOStore) ListByAddress(address string) ([]*UTXO, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.byAddress.list(address, s.data), nil
+}
+
+func (s *MemoryUTXOStore) ListBonded() ([]*UTXO, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.bonded.all(s.data), nil
+}
+
+func (s *MemoryUTXOStore) ListUnbonding() ([]*UTXO, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.unbonding.all(s.data), nil
+}
+
+func (s *MemoryUTXOStore) PutBestBlock(hash string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.bestBlock = hash
+	return nil
+}
+
+func (s *MemoryUTXOStore) GetBestBlock() (string, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.bestBlock, nil
+}
+
 type TXStorer interface {
 	Put(*proto.Transaction) error
 	Get(string) (*proto.Transaction, error)
+	Delete(string) error
 }
 
 type MemoryTXStore struct {
@@ -83,19 +254,40 @@ func (s *MemoryTXStore) Put(tx *proto.Transaction) error {
 	return nil
 }
 
+func (s *MemoryTXStore) Delete(hash string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.txx, hash)
+	return nil
+}
+
 type BlockStorer interface {
 	Put(*proto.Block) error
 	Get(string) (*proto.Block, error)
+	Delete(string) error
+	// List returns all the blocks in the order they were put
+	List() ([]*proto.Block, error)
+	// The undo record of a block, keyed by the block hash
+	PutUndo(string, *BlockUndo) error
+	GetUndo(string) (*BlockUndo, error)
+	// The commit that finalized a block, keyed by the block hash
+	PutCommit(string, *proto.Commit) error
+	GetCommit(string) (*proto.Commit, error)
 }
 
 type MemoryBlockStore struct {
-	lock   sync.RWMutex
-	blocks map[string]*proto.Block
+	lock    sync.RWMutex
+	blocks  map[string]*proto.Block
+	undo    map[string]*BlockUndo
+	commits map[string]*proto.Commit
+	order   []string
 }
 
 func NewMemoryBlockStore() *MemoryBlockStore {
 	return &MemoryBlockStore{
-		blocks: make(map[string]*proto.Block),
+		blocks:  make(map[string]*proto.Block),
+		undo:    make(map[string]*BlockUndo),
+		commits: make(map[string]*proto.Commit),
 	}
 }
 
@@ -103,6 +295,9 @@ func (s *MemoryBlockStore) Put(b *proto.Block) error {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 	hash := hex.EncodeToString(types.HashBlock(b))
+	if _, ok := s.blocks[hash]; !ok {
+		s.order = append(s.order, hash)
+	}
 	s.blocks[hash] = b
 	return nil
 }
@@ -117,3 +312,850 @@ func (s *MemoryBlockStore) Get(hash string) (*proto.Block, error) {
 
 	return block, nil
 }
+
+func (s *MemoryBlockStore) Delete(hash string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.blocks[hash]; !ok {
+		return nil
+	}
+	delete(s.blocks, hash)
+	delete(s.undo, hash)
+	delete(s.commits, hash)
+	s.order = removeHash(s.order, hash)
+	return nil
+}
+
+func (s *MemoryBlockStore) PutUndo(hash string, undo *BlockUndo) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.undo[hash] = undo
+	return nil
+}
+
+func (s *MemoryBlockStore) GetUndo(hash string) (*BlockUndo, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	undo, ok := s.undo[hash]
+	if !ok {
+		return nil, fmt.Errorf("undo record of block [%s] does not exist", hash)
+	}
+	return undo, nil
+}
+
+func (s *MemoryBlockStore) PutCommit(hash string, commit *proto.Commit) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.commits[hash] = commit
+	return nil
+}
+
+func (s *MemoryBlockStore) GetCommit(hash string) (*proto.Commit, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	commit, ok := s.commits[hash]
+	if !ok {
+		return nil, fmt.Errorf("commit of block [%s] does not exist", hash)
+	}
+	return commit, nil
+}
+
+func removeHash(hashes []string, hash string) []string {
+	for i, h := range hashes {
+		if h == hash {
+			return append(hashes[:i], hashes[i+1:]...)
+		}
+	}
+	return hashes
+}
+
+func (s *MemoryBlockStore) List() ([]*proto.Block, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	blocks := make([]*proto.Block, len(s.order))
+	for i, hash := range s.order {
+		blocks[i] = s.blocks[hash]
+	}
+	return blocks, nil
+}
+
+// recordLog is an append-only file of length prefixed and checksummed
+// records. Every append is synced to disk before it returns, unless it
+// is left to a later Sync. A record that was only partially written when
+// the process crashed is detected by its checksum and cut off the next
+// time the log is opened.
+type recordLog struct {
+	lock sync.Mutex
+	path string
+	f    *os.File
+	size int64
+}
+
+const recordHeaderLen = 8
+
+// openRecordLog opens the log at path, creating it if needed, and calls
+// fn with every intact record and its offset.
+func openRecordLog(path string, fn func(offset int64, data []byte) error) (*recordLog, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		r      = bufio.NewReader(f)
+		offset int64
+		header = make([]byte, recordHeaderLen)
+	)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			break
+		}
+		data := make([]byte, binary.BigEndian.Uint32(header[:4]))
+		if _, err := io.ReadFull(r, data); err != nil {
+			break
+		}
+		if crc32.ChecksumIEEE(data) != binary.BigEndian.Uint32(header[4:]) {
+			break
+		}
+		if err := fn(offset, data); err != nil {
+			f.Close()
+			return nil, err
+		}
+		offset += int64(recordHeaderLen + len(data))
+	}
+
+	// drop whatever follows the last intact record
+	if err := f.Truncate(offset); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &recordLog{path: path, f: f, size: offset}, nil
+}
+
+func encodeRecord(data []byte) []byte {
+	buf := make([]byte, recordHeaderLen+len(data))
+	binary.BigEndian.PutUint32(buf[:4], uint32(len(data)))
+	binary.BigEndian.PutUint32(buf[4:8], crc32.ChecksumIEEE(data))
+	copy(buf[recordHeaderLen:], data)
+	return buf
+}
+
+// Append writes the record at the end of the log and returns its offset.
+func (l *recordLog) Append(data []byte) (int64, error) {
+	offset, err := l.Write(data)
+	if err != nil {
+		return 0, err
+	}
+	if err := l.Sync(); err != nil {
+		return 0, err
+	}
+	return offset, nil
+}
+
+// Write is Append without syncing the record to disk, the next Sync or
+// Append does.
+func (l *recordLog) Write(data []byte) (int64, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	buf := encodeRecord(data)
+	offset := l.size
+	if _, err := l.f.WriteAt(buf, offset); err != nil {
+		return 0, err
+	}
+	l.size += int64(len(buf))
+	return offset, nil
+}
+
+func (l *recordLog) Sync() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.f.Sync()
+}
+
+// Replace swaps the records of the log for the given ones. They get
+// written to a new file first, which then takes the place of the log,
+// so a crash leaves either the old or the new records behind.
+func (l *recordLog) Replace(records [][]byte) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	tmp := l.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	var (
+		w    = bufio.NewWriter(f)
+		size int64
+	)
+	for _, data := range records {
+		n, err := w.Write(encodeRecord(data))
+		if err != nil {
+			f.Close()
+			return err
+		}
+		size += int64(n)
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := os.Rename(tmp, l.path); err != nil {
+		f.Close()
+		return err
+	}
+	// the rename only lasts once the directory is synced
+	if dir, err := os.Open(filepath.Dir(l.path)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+	l.f.Close()
+	l.f, l.size = f, size
+	return nil
+}
+
+// Read returns the record at the given offset.
+func (l *recordLog) Read(offset int64) ([]byte, error) {
+	header := make([]byte, recordHeaderLen)
+	if _, err := l.f.ReadAt(header, offset); err != nil {
+		return nil, err
+	}
+	data := make([]byte, binary.BigEndian.Uint32(header[:4]))
+	if _, err := l.f.ReadAt(data, offset+recordHeaderLen); err != nil {
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(data) != binary.BigEndian.Uint32(header[4:]) {
+		return nil, errors.New("record checksum mismatch")
+	}
+	return data, nil
+}
+
+// Truncate drops the records from the given offset on.
+func (l *recordLog) Truncate(size int64) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if err := l.f.Truncate(size); err != nil {
+		return err
+	}
+	l.size = size
+	return nil
+}
+
+func (l *recordLog) Close() error {
+	return l.f.Close()
+}
+
+// The block and tx logs hold puts of protobuf encoded items and
+// deletes of the hash of an item, told apart by the first byte.
+const (
+	recordPut    byte = 'p'
+	recordDelete byte = 'd'
+)
+
+func putRecord(item pb.Message) ([]byte, error) {
+	data, err := pb.Marshal(item)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{recordPut}, data...), nil
+}
+
+func deleteRecord(hash string) []byte {
+	return append([]byte{recordDelete}, hash...)
+}
+
+func decodeRecord(data []byte) (byte, []byte, error) {
+	if len(data) == 0 {
+		return 0, nil, errors.New("empty record")
+	}
+	if data[0] != recordPut && data[0] != recordDelete {
+		return 0, nil, fmt.Errorf("unknown record kind %q", data[0])
+	}
+	return data[0], data[1:], nil
+}
+
+// DiskBlockStore keeps the blocks in an append-only log on disk and an
+// in memory index from block hash to the offset of the block in the log.
+// The undo records and the commits of the blocks are kept the same way
+// in logs of their own.
+type DiskBlockStore struct {
+	lock        sync.RWMutex
+	log         *recordLog
+	index       map[string]int64
+	order       []string
+	undoLog     *recordLog
+	undoIndex   map[string]int64
+	commitLog   *recordLog
+	commitIndex map[string]int64
+}
+
+// undoRecord is the undo record of a block as written to disk.
+type undoRecord struct {
+	Hash string     `json:"hash"`
+	Undo *BlockUndo `json:"undo"`
+}
+
+func NewDiskBlockStore(dir string) (*DiskBlockStore, error) {
+	s := &DiskBlockStore{
+		index:       make(map[string]int64),
+		undoIndex:   make(map[string]int64),
+		commitIndex: make(map[string]int64),
+	}
+	log, err := openRecordLog(filepath.Join(dir, "blocks.log"), func(offset int64, data []byte) error {
+		kind, payload, err := decodeRecord(data)
+		if err != nil {
+			return err
+		}
+		if kind == recordDelete {
+			s.delete(string(payload))
+			return nil
+		}
+		b := &proto.Block{}
+		if err := pb.Unmarshal(payload, b); err != nil {
+			return err
+		}
+		s.add(hex.EncodeToString(types.HashBlock(b)), offset)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.log = log
+
+	undoLog, err := openRecordLog(filepath.Join(dir, "undo.log"), func(offset int64, data []byte) error {
+		record := undoRecord{}
+		if err := json.Unmarshal(data, &record); err != nil {
+			return err
+		}
+		s.undoIndex[record.Hash] = offset
+		return nil
+	})
+	if err != nil {
+		log.Close()
+		return nil, err
+	}
+	s.undoLog = undoLog
+
+	commitLog, err := openRecordLog(filepath.Join(dir, "commits.log"), func(offset int64, data []byte) error {
+		commit := &proto.Commit{}
+		if err := pb.Unmarshal(data, commit); err != nil {
+			return err
+		}
+		s.commitIndex[hex.EncodeToString(commit.BlockHash)] = offset
+		return nil
+	})
+	if err != nil {
+		log.Close()
+		undoLog.Close()
+		return nil, err
+	}
+	s.commitLog = commitLog
+
+	// drop the undo records and commits of blocks that got deleted
+	for hash := range s.undoIndex {
+		if _, ok := s.index[hash]; !ok {
+			delete(s.undoIndex, hash)
+		}
+	}
+	for hash := range s.commitIndex {
+		if _, ok := s.index[hash]; !ok {
+			delete(s.commitIndex, hash)
+		}
+	}
+	return s, nil
+}
+
+func (s *DiskBlockStore) add(hash string, offset int64) {
+	if _, ok := s.index[hash]; !ok {
+		s.order = append(s.order, hash)
+	}
+	s.index[hash] = offset
+}
+
+func (s *DiskBlockStore) delete(hash string) {
+	if _, ok := s.index[hash]; !ok {
+		return
+	}
+	delete(s.index, hash)
+	delete(s.undoIndex, hash)
+	delete(s.commitIndex, hash)
+	s.order = removeHash(s.order, hash)
+}
+
+func (s *DiskBlockStore) Put(b *proto.Block) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	hash := hex.EncodeToString(types.HashBlock(b))
+	if _, ok := s.index[hash]; ok {
+		return nil
+	}
+	data, err := putRecord(b)
+	if err != nil {
+		return err
+	}
+	offset, err := s.log.Append(data)
+	if err != nil {
+		return err
+	}
+	s.add(hash, offset)
+	return nil
+}
+
+func (s *DiskBlockStore) Delete(hash string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, ok := s.index[hash]; !ok {
+		return nil
+	}
+	if _, err := s.log.Append(deleteRecord(hash)); err != nil {
+		return err
+	}
+	s.delete(hash)
+	return nil
+}
+
+func (s *DiskBlockStore) Get(hash string) (*proto.Block, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	offset, ok := s.index[hash]
+	if !ok {
+		return nil, fmt.Errorf("block with hash [%s] does not exist", hash)
+	}
+	return s.read(offset)
+}
+
+func (s *DiskBlockStore) read(offset int64) (*proto.Block, error) {
+	data, err := s.log.Read(offset)
+	if err != nil {
+		return nil, err
+	}
+	_, payload, err := decodeRecord(data)
+	if err != nil {
+		return nil, err
+	}
+	b := &proto.Block{}
+	if err := pb.Unmarshal(payload, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (s *DiskBlockStore) List() ([]*proto.Block, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	blocks := make([]*proto.Block, len(s.order))
+	for i, hash := range s.order {
+		b, err := s.read(s.index[hash])
+		if err != nil {
+			return nil, err
+		}
+		blocks[i] = b
+	}
+	return blocks, nil
+}
+
+func (s *DiskBlockStore) PutUndo(hash string, undo *BlockUndo) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	data, err := json.Marshal(undoRecord{Hash: hash, Undo: undo})
+	if err != nil {
+		return err
+	}
+	offset, err := s.undoLog.Append(data)
+	if err != nil {
+		return err
+	}
+	s.undoIndex[hash] = offset
+	return nil
+}
+
+func (s *DiskBlockStore) GetUndo(hash string) (*BlockUndo, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	offset, ok := s.undoIndex[hash]
+	if !ok {
+		return nil, fmt.Errorf("undo record of block [%s] does not exist", hash)
+	}
+	data, err := s.undoLog.Read(offset)
+	if err != nil {
+		return nil, err
+	}
+	record := undoRecord{}
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, err
+	}
+	return record.Undo, nil
+}
+
+func (s *DiskBlockStore) PutCommit(hash string, commit *proto.Commit) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	data, err := pb.Marshal(commit)
+	if err != nil {
+		return err
+	}
+	offset, err := s.commitLog.Append(data)
+	if err != nil {
+		return err
+	}
+	s.commitIndex[hash] = offset
+	return nil
+}
+
+func (s *DiskBlockStore) GetCommit(hash string) (*proto.Commit, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	offset, ok := s.commitIndex[hash]
+	if !ok {
+		return nil, fmt.Errorf("commit of block [%s] does not exist", hash)
+	}
+	data, err := s.commitLog.Read(offset)
+	if err != nil {
+		return nil, err
+	}
+	commit := &proto.Commit{}
+	if err := pb.Unmarshal(data, commit); err != nil {
+		return nil, err
+	}
+	return commit, nil
+}
+
+func (s *DiskBlockStore) Close() error {
+	if err := s.commitLog.Close(); err != nil {
+		return err
+	}
+	if err := s.undoLog.Close(); err != nil {
+		return err
+	}
+	return s.log.Close()
+}
+
+// DiskTXStore keeps the transactions in an append-only log on disk and
+// an in memory index from tx hash to the offset of the tx in the log.
+type DiskTXStore struct {
+	lock  sync.RWMutex
+	log   *recordLog
+	index map[string]int64
+}
+
+func NewDiskTXStore(dir string) (*DiskTXStore, error) {
+	s := &DiskTXStore{
+		index: make(map[string]int64),
+	}
+	log, err := openRecordLog(filepath.Join(dir, "txs.log"), func(offset int64, data []byte) error {
+		kind, payload, err := decodeRecord(data)
+		if err != nil {
+			return err
+		}
+		if kind == recordDelete {
+			delete(s.index, string(payload))
+			return nil
+		}
+		tx := &proto.Transaction{}
+		if err := pb.Unmarshal(payload, tx); err != nil {
+			return err
+		}
+		s.index[hex.EncodeToString(types.HashTransaction(tx))] = offset
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.log = log
+	return s, nil
+}
+
+func (s *DiskTXStore) Put(tx *proto.Transaction) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	hash := hex.EncodeToString(types.HashTransaction(tx))
+	if _, ok := s.index[hash]; ok {
+		return nil
+	}
+	data, err := putRecord(tx)
+	if err != nil {
+		return err
+	}
+	offset, err := s.log.Append(data)
+	if err != nil {
+		return err
+	}
+	s.index[hash] = offset
+	return nil
+}
+
+func (s *DiskTXStore) Delete(hash string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, ok := s.index[hash]; !ok {
+		return nil
+	}
+	if _, err := s.log.Append(deleteRecord(hash)); err != nil {
+		return err
+	}
+	delete(s.index, hash)
+	return nil
+}
+
+func (s *DiskTXStore) Get(hash string) (*proto.Transaction, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	offset, ok := s.index[hash]
+	if !ok {
+		return nil, fmt.Errorf("could not find tx with hash %s", hash)
+	}
+	data, err := s.log.Read(offset)
+	if err != nil {
+		return nil, err
+	}
+	_, payload, err := decodeRecord(data)
+	if err != nil {
+		return nil, err
+	}
+	tx := &proto.Transaction{}
+	if err := pb.Unmarshal(payload, tx); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+func (s *DiskTXStore) Close() error {
+	return s.log.Close()
+}
+
+// utxoRecord is a single change to the UTXO set as written to disk. It
+// either puts a UTXO, deletes the UTXO with the given key or sets the
+// best block.
+type utxoRecord struct {
+	Key       string `json:"key,omitempty"`
+	UTXO      *UTXO  `json:"utxo,omitempty"`
+	BestBlock string `json:"bestBlock,omitempty"`
+}
+
+// utxoCompactRecords is the number of records the log of a
+// DiskUTXOStore holds at least before it gets compacted.
+const utxoCompactRecords = 100000
+
+// DiskUTXOStore keeps the UTXO set in memory and logs every change to
+// an append-only file it replays on startup, up to the last best block.
+// The changes only get synced to disk together with the best block, once
+// per batch. When most of the log is made up of changes that got
+// overwritten, it is replaced by a snapshot of the UTXO set.
+type DiskUTXOStore struct {
+	lock      sync.RWMutex
+	log       *recordLog
+	data      map[string]*UTXO
+	byAddress addressIndex
+	bonded    filteredIndex
+	unbonding filteredIndex
+	bestBlock string
+	// number of records in the log and how many it may hold before
+	// it gets compacted, see utxoCompactRecords
+	records      int
+	compactAfter int
+}
+
+func NewDiskUTXOStore(dir string) (*DiskUTXOStore, error) {
+	s := &DiskUTXOStore{
+		data:         make(map[string]*UTXO),
+		byAddress:    make(addressIndex),
+		bonded:       newBondedIndex(),
+		unbonding:    newUnbondingIndex(),
+		compactAfter: utxoCompactRecords,
+	}
+	// The changes of a batch only count once the best block of the
+	// batch got written, the ones of a batch cut short are dropped.
+	var (
+		pending   []utxoRecord
+		committed int64
+	)
+	log, err := openRecordLog(filepath.Join(dir, "utxos.log"), func(offset int64, data []byte) error {
+		record := utxoRecord{}
+		if err := json.Unmarshal(data, &record); err != nil {
+			return err
+		}
+		pending = append(pending, record)
+		if record.BestBlock != "" {
+			for _, record := range pending {
+				s.apply(record)
+			}
+			s.records += len(pending)
+			pending = nil
+			committed = offset + recordHeaderLen + int64(len(data))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := log.Truncate(committed); err != nil {
+		log.Close()
+		return nil, err
+	}
+	s.log = log
+	if err := s.compact(); err != nil {
+		log.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *DiskUTXOStore) apply(record utxoRecord) {
+	if record.BestBlock != "" {
+		s.bestBlock = record.BestBlock
+		return
+	}
+
+	if prev, ok := s.data[record.Key]; ok {
+		s.byAddress.delete(record.Key, prev)
+		s.bonded.delete(record.Key, prev)
+		s.unbonding.delete(record.Key, prev)
+	}
+	if record.UTXO == nil {
+		delete(s.data, record.Key)
+		return
+	}
+	s.data[record.Key] = record.UTXO
+	s.byAddress.put(record.Key, record.UTXO)
+	s.bonded.put(record.Key, record.UTXO)
+	s.unbonding.put(record.Key, record.UTXO)
+}
+
+func (s *DiskUTXOStore) write(record utxoRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if _, err := s.log.Write(data); err != nil {
+		return err
+	}
+	s.records++
+	s.apply(record)
+	return nil
+}
+
+// compact replaces the log with a snapshot of the UTXO set and the best
+// block, once the log holds more than twice the records of the snapshot.
+func (s *DiskUTXOStore) compact() error {
+	live := len(s.data) + 1
+	if s.bestBlock == "" || s.records < s.compactAfter || s.records <= 2*live {
+		return nil
+	}
+	keys := make([]string, 0, len(s.data))
+	for key := range s.data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	records := make([][]byte, 0, live)
+	for _, key := range keys {
+		data, err := json.Marshal(utxoRecord{Key: key, UTXO: s.data[key]})
+		if err != nil {
+			return err
+		}
+		records = append(records, data)
+	}
+	data, err := json.Marshal(utxoRecord{BestBlock: s.bestBlock})
+	if err != nil {
+		return err
+	}
+	if err := s.log.Replace(append(records, data)); err != nil {
+		return err
+	}
+	s.records = live
+	return nil
+}
+
+func (s *DiskUTXOStore) Get(hash string) (*UTXO, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	utxo, ok := s.data[hash]
+	if !ok {
+		return nil, fmt.Errorf("could not find utxo with hash %s", hash)
+	}
+
+	return utxo, nil
+}
+
+func (s *DiskUTXOStore) PUT(utxo *UTXO) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	key := fmt.Sprintf("%s_%d", utxo.Hash, utxo.OutIndex)
+	return s.write(utxoRecord{Key: key, UTXO: utxo})
+}
+
+func (s *DiskUTXOStore) Delete(key string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.write(utxoRecord{Key: key})
+}
+
+func (s *DiskUTXOStore) ListByAddress(address string) ([]*UTXO, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.byAddress.list(address, s.data), nil
+}
+
+func (s *DiskUTXOStore) ListBonded() ([]*UTXO, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.bonded.all(s.data), nil
+}
+
+func (s *DiskUTXOStore) ListUnbonding() ([]*UTXO, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.unbonding.all(s.data), nil
+}
+
+// PutBestBlock commits the changes written since the last best block.
+func (s *DiskUTXOStore) PutBestBlock(hash string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := s.write(utxoRecord{BestBlock: hash}); err != nil {
+		return err
+	}
+	if err := s.log.Sync(); err != nil {
+		return err
+	}
+	return s.compact()
+}
+
+func (s *DiskUTXOStore) GetBestBlock() (string, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.bestBlock, nil
+}
+
+func (s *DiskUTXOStore) Close() error {
+	return s.log.Close()
+}
@@ -0,0 +1,86 @@
+package node
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/s809616134/go-blocker/types"
+	"github.com/s809616134/go-blocker/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiskBlockStoreTornWrite(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewDiskBlockStore(dir)
+	require.Nil(t, err)
+
+	blocks := []string{}
+	for i := 0; i < 3; i++ {
+		b := util.RandomBlock()
+		require.Nil(t, store.Put(b))
+		blocks = append(blocks, hex.EncodeToString(types.HashBlock(b)))
+	}
+	require.Nil(t, store.Close())
+
+	// simulate a crash in the middle of writing the next block
+	f, err := os.OpenFile(filepath.Join(dir, "blocks.log"), os.O_WRONLY|os.O_APPEND, 0644)
+	require.Nil(t, err)
+	_, err = f.Write([]byte{0, 0, 0, 42, 1, 2, 3})
+	require.Nil(t, err)
+	require.Nil(t, f.Close())
+
+	store, err = NewDiskBlockStore(dir)
+	require.Nil(t, err)
+	list, err := store.List()
+	require.Nil(t, err)
+	require.Equal(t, 3, len(list))
+	for i, b := range list {
+		assert.Equal(t, blocks[i], hex.EncodeToString(types.HashBlock(b)))
+	}
+
+	// the torn record is cut off and new blocks can be appended
+	b := util.RandomBlock()
+	require.Nil(t, store.Put(b))
+	_, err = store.Get(hex.EncodeToString(types.HashBlock(b)))
+	assert.Nil(t, err)
+}
+
+func TestDiskUTXOStoreCompaction(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewDiskUTXOStore(dir)
+	require.Nil(t, err)
+	store.compactAfter = 20
+
+	// every block spends the output of the one before
+	for i := 0; i < 50; i++ {
+		require.Nil(t, store.PUT(&UTXO{Hash: fmt.Sprint(i), Amount: int64(i), Address: "a", Bonded: i%2 == 0}))
+		if i > 0 {
+			require.Nil(t, store.Delete(fmt.Sprintf("%d_0", i-1)))
+		}
+		require.Nil(t, store.PutBestBlock(fmt.Sprint(i)))
+		assert.LessOrEqual(t, store.records, 20)
+	}
+	// the changes after the last best block are not committed
+	require.Nil(t, store.PUT(&UTXO{Hash: "pending", Address: "a"}))
+	require.Nil(t, store.Close())
+
+	store, err = NewDiskUTXOStore(dir)
+	require.Nil(t, err)
+	best, err := store.GetBestBlock()
+	require.Nil(t, err)
+	assert.Equal(t, "49", best)
+	utxos, err := store.ListByAddress("a")
+	require.Nil(t, err)
+	require.Len(t, utxos, 1)
+	assert.Equal(t, int64(49), utxos[0].Amount)
+	_, err = store.Get("pending_0")
+	assert.NotNil(t, err)
+	_, err = store.Get("48_0")
+	assert.NotNil(t, err)
+	_, err = os.Stat(filepath.Join(dir, "utxos.log.tmp"))
+	assert.True(t, os.IsNotExist(err))
+}
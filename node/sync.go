@@ -0,0 +1,227 @@
+package node
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/s809616134/go-blocker/proto"
+	"github.com/s809616134/go-blocker/types"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// Number of blocks we request from a peer in a single round trip
+	syncBatchSize = 100
+	// How many times we go over our peers before giving up on a sync
+	syncRetries    = 3
+	syncRetryDelay = time.Second * 2
+)
+
+// syncRange returns the heights of the range a peer requested, at most
+// syncBatchSize of them.
+func syncRange(r *proto.HeightRange) (int, int, error) {
+	if r.From < 0 || r.From > r.To {
+		return 0, 0, status.Errorf(codes.InvalidArgument, "invalid height range (%d) to (%d)", r.From, r.To)
+	}
+	from, to := int64(r.From), int64(r.To)
+	if to-from >= syncBatchSize {
+		to = from + syncBatchSize - 1
+	}
+	return int(from), int(to), nil
+}
+
+func (n *Node) GetHeaders(ctx context.Context, r *proto.HeightRange) (*proto.Headers, error) {
+	from, to, err := syncRange(r)
+	if err != nil {
+		return nil, err
+	}
+	return &proto.Headers{
+		Headers: n.chain.GetHeaders(from, to),
+	}, nil
+}
+
+func (n *Node) GetBlocks(r *proto.HeightRange, stream proto.Node_GetBlocksServer) error {
+	from, to, err := syncRange(r)
+	if err != nil {
+		return err
+	}
+	for _, header := range n.chain.GetHeaders(from, to) {
+		b, err := n.chain.GetBlockByHash(types.HashHeader(header))
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sync downloads the blocks we are missing from our peers, starting
+// with the one that announced the highest chain. Only one sync runs at
+// a time. When a peer fails in the middle of a sync we resume from our
+// current height with the next one.
+func (n *Node) sync() {
+	if !n.syncLock.TryLock() {
+		return
+	}
+	defer n.syncLock.Unlock()
+	// the consensus moves on to the height on top of the blocks we got
+	defer n.consensus.Update()
+
+	for attempt := 0; attempt < syncRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(syncRetryDelay)
+		}
+
+		failed := false
+		for _, p := range n.getSyncPeers() {
+			err := n.syncWithPeer(p.client, p.version)
+			if err == nil {
+				continue
+			}
+			failed = true
+			n.logger.Errorw("sync with peer failed",
+				"we", n.ListenAddr,
+				"remoteNode", p.version.ListenAddr,
+				"height", n.chain.Height(),
+				"err", err)
+
+			if status.Code(err) == codes.Unavailable {
+				n.deletePeer(p.client)
+			}
+		}
+		if !failed {
+			return
+		}
+	}
+}
+
+type syncPeer struct {
+	client  proto.NodeClient
+	version *proto.Version
+}
+
+// getSyncPeers returns all our peers, the ones that announced the
+// highest chain during the handshake first.
+func (n *Node) getSyncPeers() []syncPeer {
+	n.peerLock.RLock()
+	defer n.peerLock.RUnlock()
+
+	peers := []syncPeer{}
+	for c, v := range n.peers {
+		peers = append(peers, syncPeer{client: c, version: v})
+	}
+	sort.Slice(peers, func(i, j int) bool {
+		return peers[i].version.Height > peers[j].version.Height
+	})
+	return peers
+}
+
+func (n *Node) syncWithPeer(c proto.NodeClient, v *proto.Version) error {
+	from := int32(n.chain.Height() + 1)
+	for {
+		r := &proto.HeightRange{
+			From: from,
+			To:   from + syncBatchSize - 1,
+		}
+		headers, err := c.GetHeaders(context.Background(), r)
+		if err != nil {
+			return err
+		}
+		if len(headers.Headers) == 0 {
+			return nil
+		}
+
+		// The peer is on another branch, step back until we
+		// reach the part of the chain we have in common.
+		prevHash := headers.Headers[0].PrevHash
+		if !n.chain.OnMainChain(prevHash) {
+			if from <= 1 {
+				return fmt.Errorf("peer does not share our genesis block")
+			}
+			from -= syncBatchSize
+			if from < 1 {
+				from = 1
+			}
+			continue
+		}
+		if err := verifyHeaderChain(prevHash, headers.Headers); err != nil {
+			return err
+		}
+
+		r.To = r.From + int32(len(headers.Headers)) - 1
+		if err := n.downloadBlocks(c, r, headers.Headers); err != nil {
+			return err
+		}
+		from = r.To + 1
+
+		n.logger.Infow("sync progress",
+			"we", n.ListenAddr,
+			"remoteNode", v.ListenAddr,
+			"height", n.chain.Height(),
+			"target", v.Height)
+	}
+}
+
+// downloadBlocks streams the blocks in the given range from the peer
+// and adds them to our chain. Every block has to match the header we
+// fetched before.
+func (n *Node) downloadBlocks(c proto.NodeClient, r *proto.HeightRange, headers []*proto.Header) error {
+	stream, err := c.GetBlocks(context.Background(), r)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; ; i++ {
+		b, err := stream.Recv()
+		if err == io.EOF {
+			if i < len(headers) {
+				return fmt.Errorf("peer sent %d blocks, expected %d", i, len(headers))
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if i >= len(headers) {
+			return fmt.Errorf("peer sent more blocks than requested")
+		}
+
+		if b.Header == nil {
+			return fmt.Errorf("block at height %d without a header", headers[i].Height)
+		}
+		hash := types.HashBlock(b)
+		if !bytes.Equal(hash, types.HashHeader(headers[i])) {
+			return fmt.Errorf("block at height %d does not match its header", headers[i].Height)
+		}
+		if n.chain.OnMainChain(hash) {
+			continue
+		}
+		// only blocks decided by the consensus make it into the chain
+		commit, err := c.GetCommit(context.Background(), &proto.BlockRequest{Hash: hash})
+		if err != nil {
+			return err
+		}
+		if err := n.chain.CommitBlock(b, commit); err != nil {
+			return err
+		}
+	}
+}
+
+// verifyHeaderChain checks that the headers link to each other,
+// starting from the block with the given hash.
+func verifyHeaderChain(prevHash []byte, headers []*proto.Header) error {
+	for _, header := range headers {
+		if !bytes.Equal(prevHash, header.PrevHash) {
+			return fmt.Errorf("header at height %d does not link to the previous one", header.Height)
+		}
+		prevHash = types.HashHeader(header)
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package node
+
+import (
+	"context"
+	"io"
+	"math"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/s809616134/go-blocker/proto"
+	"github.com/s809616134/go-blocker/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// serveNode serves n on a random local port for the duration of the
+// test and returns a client of it.
+func serveNode(t *testing.T, n *Node) proto.NodeClient {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	server := grpc.NewServer()
+	proto.RegisterNodeServer(server, n)
+	go server.Serve(ln)
+	t.Cleanup(server.Stop)
+
+	n.ListenAddr = ln.Addr().String()
+	c, err := makeNodeClient(n.ListenAddr)
+	require.Nil(t, err)
+	return c
+}
+
+// commitBlocks adds count blocks decided by the genesis validator to the
+// chain of n.
+func commitBlocks(t *testing.T, n *Node, count int) {
+	for i := 0; i < count; i++ {
+		block := randomBLock(t, n.chain)
+		require.Nil(t, n.chain.CommitBlock(block, newCommit(block, GenesisValidatorKey())))
+	}
+}
+
+// syncClient records the block ranges requested from the peer, drops
+// the connection once down hits 0 and can replace the blocks it sends.
+// It counts the header requests, every sync starts with one.
+type syncClient struct {
+	proto.NodeClient
+	ranges  []*proto.HeightRange
+	down    int
+	blocks  []*proto.Block
+	headers atomic.Int32
+}
+
+func (c *syncClient) GetHeaders(ctx context.Context, r *proto.HeightRange, opts ...grpc.CallOption) (*proto.Headers, error) {
+	c.headers.Add(1)
+	return c.NodeClient.GetHeaders(ctx, r, opts...)
+}
+
+func (c *syncClient) GetBlocks(ctx context.Context, r *proto.HeightRange, opts ...grpc.CallOption) (proto.Node_GetBlocksClient, error) {
+	c.ranges = append(c.ranges, &proto.HeightRange{From: r.From, To: r.To})
+	if c.down--; c.down == 0 {
+		return nil, status.Error(codes.Unavailable, "connection lost")
+	}
+	if c.blocks != nil {
+		return &blockStream{blocks: c.blocks}, nil
+	}
+	return c.NodeClient.GetBlocks(ctx, r, opts...)
+}
+
+type blockStream struct {
+	grpc.ClientStream
+	blocks []*proto.Block
+}
+
+func (s *blockStream) Recv() (*proto.Block, error) {
+	if len(s.blocks) == 0 {
+		return nil, io.EOF
+	}
+	b := s.blocks[0]
+	s.blocks = s.blocks[1:]
+	return b, nil
+}
+
+func TestSync(t *testing.T) {
+	a, err := NewNode(ServerConfig{})
+	require.Nil(t, err)
+	b, err := NewNode(ServerConfig{})
+	require.Nil(t, err)
+	commitBlocks(t, a, syncBatchSize+50)
+
+	// the peer goes away after the first range
+	c := &syncClient{NodeClient: serveNode(t, a), down: 2}
+	err = b.syncWithPeer(c, a.getVersion())
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Equal(t, syncBatchSize, b.chain.Height())
+	assert.Equal(t, []*proto.HeightRange{
+		{From: 1, To: syncBatchSize},
+		{From: syncBatchSize + 1, To: syncBatchSize + 50},
+	}, c.ranges)
+
+	// and we resume where we stopped once it is back
+	c.ranges = nil
+	b.addPeer(c, a.getVersion())
+	require.Eventually(t, func() bool {
+		return b.chain.Height() == a.chain.Height()
+	}, time.Second*5, time.Millisecond*10)
+	assert.Equal(t, []*proto.HeightRange{
+		{From: syncBatchSize + 1, To: syncBatchSize + 50},
+	}, c.ranges)
+	tip, err := a.chain.GetBlockByHeight(a.chain.Height())
+	require.Nil(t, err)
+	assert.True(t, b.chain.HasBlock(types.HashBlock(tip)))
+}
+
+func TestSyncBlockWithoutHeader(t *testing.T) {
+	a, err := NewNode(ServerConfig{})
+	require.Nil(t, err)
+	b, err := NewNode(ServerConfig{})
+	require.Nil(t, err)
+	commitBlocks(t, a, 1)
+
+	c := &syncClient{NodeClient: serveNode(t, a), blocks: []*proto.Block{{}}}
+	err = b.syncWithPeer(c, a.getVersion())
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "without a header")
+	assert.Equal(t, 0, b.chain.Height())
+}
+
+func TestGetHeadersRange(t *testing.T) {
+	n, err := NewNode(ServerConfig{})
+	require.Nil(t, err)
+	commitBlocks(t, n, syncBatchSize+10)
+
+	for _, r := range []*proto.HeightRange{{From: -1, To: 10}, {From: 10, To: 9}, {From: math.MinInt32, To: math.MaxInt32}} {
+		_, err := n.GetHeaders(context.Background(), r)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err), r)
+	}
+
+	// a peer gets no more than a batch
+	resp, err := n.GetHeaders(context.Background(), &proto.HeightRange{From: 5, To: math.MaxInt32})
+	require.Nil(t, err)
+	require.Len(t, resp.Headers, syncBatchSize)
+	assert.Equal(t, int32(5), resp.Headers[0].Height)
+	resp, err = n.GetHeaders(context.Background(), &proto.HeightRange{From: math.MaxInt32, To: math.MaxInt32})
+	require.Nil(t, err)
+	assert.Empty(t, resp.Headers)
+}
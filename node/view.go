@@ -0,0 +1,67 @@
+package node
+
+import (
+	"fmt"
+
+	"github.com/s809616134/go-blocker/proto"
+)
+
+// UTXOView looks up outputs by their "<txhash>_<index>" key.
+type UTXOView interface {
+	Get(string) (*UTXO, error)
+}
+
+// utxoOverlay extends a view with the outputs created and spent by txs
+// that are not committed yet, e.g. the earlier txs of a block being
+// validated. The underlying view is never modified.
+type utxoOverlay struct {
+	base    UTXOView
+	created map[string]*UTXO
+	spent   map[string]bool
+}
+
+func newUTXOOverlay(base UTXOView) *utxoOverlay {
+	return &utxoOverlay{
+		base:    base,
+		created: make(map[string]*UTXO),
+		spent:   make(map[string]bool),
+	}
+}
+
+func (o *utxoOverlay) Get(key string) (*UTXO, error) {
+	utxo, ok := o.created[key]
+	if !ok {
+		var err error
+		if utxo, err = o.base.Get(key); err != nil {
+			return nil, err
+		}
+	}
+	u := *utxo
+	u.Spent = u.Spent || o.spent[key]
+	return &u, nil
+}
+
+// apply spends the inputs of tx and adds the UTXOs it created.
+func (o *utxoOverlay) apply(tx *proto.Transaction, created []*UTXO) {
+	for _, input := range tx.Inputs {
+		o.spent[outpointKey(input)] = true
+	}
+	for _, utxo := range created {
+		o.created[fmt.Sprintf("%s_%d", utxo.Hash, utxo.OutIndex)] = utxo
+	}
+}
+
+// fallbackView looks up outputs in view and falls back to pending when
+// they are not found, e.g. for outputs of txs in the mempool.
+type fallbackView struct {
+	view    UTXOView
+	pending UTXOView
+}
+
+func (v fallbackView) Get(key string) (*UTXO, error) {
+	utxo, err := v.view.Get(key)
+	if err != nil {
+		return v.pending.Get(key)
+	}
+	return utxo, nil
+}
@@ -20,14 +20,89 @@ var _ = math.Inf
 // proto package needs to be updated.
 const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
 
+type TxType int32
+
+const (
+	TxType_TRANSFER TxType = 0
+	// mints the block reward, has no inputs and
+	// is the first tx of a block
+	TxType_COINBASE TxType = 1
+	// bonds its first output as stake of the
+	// validator paid by it
+	TxType_STAKE TxType = 2
+	// spends bonded outputs, its first output stays
+	// locked for the unbonding period, the others
+	// stay bonded
+	TxType_UNSTAKE TxType = 3
+	// bonds its first output as stake delegated to
+	// the validator of the tx
+	TxType_DELEGATE TxType = 4
+	// spends outputs delegated to the validator of the
+	// tx like an unstake tx
+	TxType_UNDELEGATE TxType = 5
+)
+
+var TxType_name = map[int32]string{
+	0: "TRANSFER",
+	1: "COINBASE",
+	2: "STAKE",
+	3: "UNSTAKE",
+	4: "DELEGATE",
+	5: "UNDELEGATE",
+}
+
+var TxType_value = map[string]int32{
+	"TRANSFER":   0,
+	"COINBASE":   1,
+	"STAKE":      2,
+	"UNSTAKE":    3,
+	"DELEGATE":   4,
+	"UNDELEGATE": 5,
+}
+
+func (x TxType) String() string {
+	return proto.EnumName(TxType_name, int32(x))
+}
+
+func (TxType) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_e2f027f54ad4521e, []int{0}
+}
+
+type VoteType int32
+
+const (
+	VoteType_PREVOTE   VoteType = 0
+	VoteType_PRECOMMIT VoteType = 1
+)
+
+var VoteType_name = map[int32]string{
+	0: "PREVOTE",
+	1: "PRECOMMIT",
+}
+
+var VoteType_value = map[string]int32{
+	"PREVOTE":   0,
+	"PRECOMMIT": 1,
+}
+
+func (x VoteType) String() string {
+	return proto.EnumName(VoteType_name, int32(x))
+}
+
+func (VoteType) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_e2f027f54ad4521e, []int{1}
+}
+
 type Block struct {
-	Header               *Header        `protobuf:"bytes,1,opt,name=header,proto3" json:"header,omitempty"`
-	Transactions         []*Transaction `protobuf:"bytes,2,rep,name=transactions,proto3" json:"transactions,omitempty"`
-	PublicKey            []byte         `protobuf:"bytes,3,opt,name=publicKey,proto3" json:"publicKey,omitempty"`
-	Signature            []byte         `protobuf:"bytes,4,opt,name=signature,proto3" json:"signature,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}       `json:"-"`
-	XXX_unrecognized     []byte         `json:"-"`
-	XXX_sizecache        int32          `json:"-"`
+	Header       *Header        `protobuf:"bytes,1,opt,name=header,proto3" json:"header,omitempty"`
+	Transactions []*Transaction `protobuf:"bytes,2,rep,name=transactions,proto3" json:"transactions,omitempty"`
+	PublicKey    []byte         `protobuf:"bytes,3,opt,name=publicKey,proto3" json:"publicKey,omitempty"`
+	Signature    []byte         `protobuf:"bytes,4,opt,name=signature,proto3" json:"signature,omitempty"`
+	// proof of validators that proposed two blocks in the same round
+	Evidence             []*Evidence `protobuf:"bytes,5,rep,name=evidence,proto3" json:"evidence,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}    `json:"-"`
+	XXX_unrecognized     []byte      `json:"-"`
+	XXX_sizecache        int32       `json:"-"`
 }
 
 func (m *Block) Reset()         { *m = Block{} }
@@ -83,11 +158,20 @@ func (m *Block) GetSignature() []byte {
 	return nil
 }
 
+func (m *Block) GetEvidence() []*Evidence {
+	if m != nil {
+		return m.Evidence
+	}
+	return nil
+}
+
 type Version struct {
-	Version              string   `protobuf:"bytes,1,opt,name=version,proto3" json:"version,omitempty"`
-	Height               int32    `protobuf:"varint,2,opt,name=height,proto3" json:"height,omitempty"`
-	ListenAddr           string   `protobuf:"bytes,3,opt,name=listenAddr,proto3" json:"listenAddr,omitempty"`
-	PeerList             []string `protobuf:"bytes,4,rep,name=peerList,proto3" json:"peerList,omitempty"`
+	Version    string   `protobuf:"bytes,1,opt,name=version,proto3" json:"version,omitempty"`
+	Height     int32    `protobuf:"varint,2,opt,name=height,proto3" json:"height,omitempty"`
+	ListenAddr string   `protobuf:"bytes,3,opt,name=listenAddr,proto3" json:"listenAddr,omitempty"`
+	PeerList   []string `protobuf:"bytes,4,rep,name=peerList,proto3" json:"peerList,omitempty"`
+	// peers of other chains get rejected
+	ChainID              string   `protobuf:"bytes,5,opt,name=chainID,proto3" json:"chainID,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -146,6 +230,13 @@ func (m *Version) GetPeerList() []string {
 	return nil
 }
 
+func (m *Version) GetChainID() string {
+	if m != nil {
+		return m.ChainID
+	}
+	return ""
+}
+
 type Ack struct {
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
@@ -177,288 +268,1544 @@ func (m *Ack) XXX_DiscardUnknown() {
 
 var xxx_messageInfo_Ack proto.InternalMessageInfo
 
-type Header struct {
-	Version              int32    `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
-	Height               int32    `protobuf:"varint,2,opt,name=height,proto3" json:"height,omitempty"`
-	PrevHash             []byte   `protobuf:"bytes,3,opt,name=prevHash,proto3" json:"prevHash,omitempty"`
-	RootHash             []byte   `protobuf:"bytes,4,opt,name=rootHash,proto3" json:"rootHash,omitempty"`
-	Timestamp            int64    `protobuf:"varint,5,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+// inclusive range of block heights
+type HeightRange struct {
+	From                 int32    `protobuf:"varint,1,opt,name=from,proto3" json:"from,omitempty"`
+	To                   int32    `protobuf:"varint,2,opt,name=to,proto3" json:"to,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
 }
 
-func (m *Header) Reset()         { *m = Header{} }
-func (m *Header) String() string { return proto.CompactTextString(m) }
-func (*Header) ProtoMessage()    {}
-func (*Header) Descriptor() ([]byte, []int) {
+func (m *HeightRange) Reset()         { *m = HeightRange{} }
+func (m *HeightRange) String() string { return proto.CompactTextString(m) }
+func (*HeightRange) ProtoMessage()    {}
+func (*HeightRange) Descriptor() ([]byte, []int) {
 	return fileDescriptor_e2f027f54ad4521e, []int{3}
 }
 
-func (m *Header) XXX_Unmarshal(b []byte) error {
-	return xxx_messageInfo_Header.Unmarshal(m, b)
+func (m *HeightRange) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_HeightRange.Unmarshal(m, b)
 }
-func (m *Header) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	return xxx_messageInfo_Header.Marshal(b, m, deterministic)
+func (m *HeightRange) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_HeightRange.Marshal(b, m, deterministic)
 }
-func (m *Header) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_Header.Merge(m, src)
+func (m *HeightRange) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_HeightRange.Merge(m, src)
 }
-func (m *Header) XXX_Size() int {
-	return xxx_messageInfo_Header.Size(m)
+func (m *HeightRange) XXX_Size() int {
+	return xxx_messageInfo_HeightRange.Size(m)
 }
-func (m *Header) XXX_DiscardUnknown() {
-	xxx_messageInfo_Header.DiscardUnknown(m)
+func (m *HeightRange) XXX_DiscardUnknown() {
+	xxx_messageInfo_HeightRange.DiscardUnknown(m)
 }
 
-var xxx_messageInfo_Header proto.InternalMessageInfo
+var xxx_messageInfo_HeightRange proto.InternalMessageInfo
 
-func (m *Header) GetVersion() int32 {
+func (m *HeightRange) GetFrom() int32 {
 	if m != nil {
-		return m.Version
+		return m.From
 	}
 	return 0
 }
 
-func (m *Header) GetHeight() int32 {
+func (m *HeightRange) GetTo() int32 {
 	if m != nil {
-		return m.Height
+		return m.To
 	}
 	return 0
 }
 
-func (m *Header) GetPrevHash() []byte {
+type Headers struct {
+	Headers              []*Header `protobuf:"bytes,1,rep,name=headers,proto3" json:"headers,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}  `json:"-"`
+	XXX_unrecognized     []byte    `json:"-"`
+	XXX_sizecache        int32     `json:"-"`
+}
+
+func (m *Headers) Reset()         { *m = Headers{} }
+func (m *Headers) String() string { return proto.CompactTextString(m) }
+func (*Headers) ProtoMessage()    {}
+func (*Headers) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e2f027f54ad4521e, []int{4}
+}
+
+func (m *Headers) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Headers.Unmarshal(m, b)
+}
+func (m *Headers) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Headers.Marshal(b, m, deterministic)
+}
+func (m *Headers) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Headers.Merge(m, src)
+}
+func (m *Headers) XXX_Size() int {
+	return xxx_messageInfo_Headers.Size(m)
+}
+func (m *Headers) XXX_DiscardUnknown() {
+	xxx_messageInfo_Headers.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Headers proto.InternalMessageInfo
+
+func (m *Headers) GetHeaders() []*Header {
 	if m != nil {
-		return m.PrevHash
+		return m.Headers
 	}
 	return nil
 }
 
-func (m *Header) GetRootHash() []byte {
+type AddressRequest struct {
+	Address              []byte   `protobuf:"bytes,1,opt,name=address,proto3" json:"address,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *AddressRequest) Reset()         { *m = AddressRequest{} }
+func (m *AddressRequest) String() string { return proto.CompactTextString(m) }
+func (*AddressRequest) ProtoMessage()    {}
+func (*AddressRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e2f027f54ad4521e, []int{5}
+}
+
+func (m *AddressRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_AddressRequest.Unmarshal(m, b)
+}
+func (m *AddressRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_AddressRequest.Marshal(b, m, deterministic)
+}
+func (m *AddressRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_AddressRequest.Merge(m, src)
+}
+func (m *AddressRequest) XXX_Size() int {
+	return xxx_messageInfo_AddressRequest.Size(m)
+}
+func (m *AddressRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_AddressRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_AddressRequest proto.InternalMessageInfo
+
+func (m *AddressRequest) GetAddress() []byte {
 	if m != nil {
-		return m.RootHash
+		return m.Address
 	}
 	return nil
 }
 
-func (m *Header) GetTimestamp() int64 {
+type Balance struct {
+	Address              []byte   `protobuf:"bytes,1,opt,name=address,proto3" json:"address,omitempty"`
+	Amount               int64    `protobuf:"varint,2,opt,name=amount,proto3" json:"amount,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Balance) Reset()         { *m = Balance{} }
+func (m *Balance) String() string { return proto.CompactTextString(m) }
+func (*Balance) ProtoMessage()    {}
+func (*Balance) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e2f027f54ad4521e, []int{6}
+}
+
+func (m *Balance) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Balance.Unmarshal(m, b)
+}
+func (m *Balance) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Balance.Marshal(b, m, deterministic)
+}
+func (m *Balance) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Balance.Merge(m, src)
+}
+func (m *Balance) XXX_Size() int {
+	return xxx_messageInfo_Balance.Size(m)
+}
+func (m *Balance) XXX_DiscardUnknown() {
+	xxx_messageInfo_Balance.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Balance proto.InternalMessageInfo
+
+func (m *Balance) GetAddress() []byte {
 	if m != nil {
-		return m.Timestamp
+		return m.Address
+	}
+	return nil
+}
+
+func (m *Balance) GetAmount() int64 {
+	if m != nil {
+		return m.Amount
 	}
 	return 0
 }
 
-type TxInput struct {
-	// previous hash of the tx
-	// containing the out put we want to spend
-	PrevTxHash []byte `protobuf:"bytes,1,opt,name=PrevTxHash,proto3" json:"PrevTxHash,omitempty"`
-	// The index of the output of the previous transaction we
-	// want to spend
-	PrevOutIndex         uint32   `protobuf:"varint,2,opt,name=PrevOutIndex,proto3" json:"PrevOutIndex,omitempty"`
-	PublicKey            []byte   `protobuf:"bytes,3,opt,name=publicKey,proto3" json:"publicKey,omitempty"`
-	Signature            []byte   `protobuf:"bytes,4,opt,name=signature,proto3" json:"signature,omitempty"`
+type UnspentOutput struct {
+	TxHash   []byte `protobuf:"bytes,1,opt,name=txHash,proto3" json:"txHash,omitempty"`
+	OutIndex uint32 `protobuf:"varint,2,opt,name=outIndex,proto3" json:"outIndex,omitempty"`
+	Amount   int64  `protobuf:"varint,3,opt,name=amount,proto3" json:"amount,omitempty"`
+	Address  []byte `protobuf:"bytes,4,opt,name=address,proto3" json:"address,omitempty"`
+	// bonded as stake, only spendable by an unstake tx
+	Bonded bool `protobuf:"varint,5,opt,name=bonded,proto3" json:"bonded,omitempty"`
+	// not spendable before this height
+	LockHeight int32 `protobuf:"varint,6,opt,name=lockHeight,proto3" json:"lockHeight,omitempty"`
+	// the validator the output is bonded to
+	Validator            []byte   `protobuf:"bytes,7,opt,name=validator,proto3" json:"validator,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
 }
 
-func (m *TxInput) Reset()         { *m = TxInput{} }
-func (m *TxInput) String() string { return proto.CompactTextString(m) }
-func (*TxInput) ProtoMessage()    {}
-func (*TxInput) Descriptor() ([]byte, []int) {
-	return fileDescriptor_e2f027f54ad4521e, []int{4}
+func (m *UnspentOutput) Reset()         { *m = UnspentOutput{} }
+func (m *UnspentOutput) String() string { return proto.CompactTextString(m) }
+func (*UnspentOutput) ProtoMessage()    {}
+func (*UnspentOutput) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e2f027f54ad4521e, []int{7}
 }
 
-func (m *TxInput) XXX_Unmarshal(b []byte) error {
-	return xxx_messageInfo_TxInput.Unmarshal(m, b)
+func (m *UnspentOutput) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_UnspentOutput.Unmarshal(m, b)
 }
-func (m *TxInput) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	return xxx_messageInfo_TxInput.Marshal(b, m, deterministic)
+func (m *UnspentOutput) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_UnspentOutput.Marshal(b, m, deterministic)
 }
-func (m *TxInput) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_TxInput.Merge(m, src)
+func (m *UnspentOutput) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_UnspentOutput.Merge(m, src)
 }
-func (m *TxInput) XXX_Size() int {
-	return xxx_messageInfo_TxInput.Size(m)
+func (m *UnspentOutput) XXX_Size() int {
+	return xxx_messageInfo_UnspentOutput.Size(m)
 }
-func (m *TxInput) XXX_DiscardUnknown() {
-	xxx_messageInfo_TxInput.DiscardUnknown(m)
+func (m *UnspentOutput) XXX_DiscardUnknown() {
+	xxx_messageInfo_UnspentOutput.DiscardUnknown(m)
 }
 
-var xxx_messageInfo_TxInput proto.InternalMessageInfo
+var xxx_messageInfo_UnspentOutput proto.InternalMessageInfo
 
-func (m *TxInput) GetPrevTxHash() []byte {
+func (m *UnspentOutput) GetTxHash() []byte {
 	if m != nil {
-		return m.PrevTxHash
+		return m.TxHash
 	}
 	return nil
 }
 
-func (m *TxInput) GetPrevOutIndex() uint32 {
+func (m *UnspentOutput) GetOutIndex() uint32 {
 	if m != nil {
-		return m.PrevOutIndex
+		return m.OutIndex
 	}
 	return 0
 }
 
-func (m *TxInput) GetPublicKey() []byte {
+func (m *UnspentOutput) GetAmount() int64 {
 	if m != nil {
-		return m.PublicKey
+		return m.Amount
+	}
+	return 0
+}
+
+func (m *UnspentOutput) GetAddress() []byte {
+	if m != nil {
+		return m.Address
 	}
 	return nil
 }
 
-func (m *TxInput) GetSignature() []byte {
+func (m *UnspentOutput) GetBonded() bool {
 	if m != nil {
-		return m.Signature
+		return m.Bonded
+	}
+	return false
+}
+
+func (m *UnspentOutput) GetLockHeight() int32 {
+	if m != nil {
+		return m.LockHeight
+	}
+	return 0
+}
+
+func (m *UnspentOutput) GetValidator() []byte {
+	if m != nil {
+		return m.Validator
 	}
 	return nil
 }
 
-type TxOutput struct {
-	Amount               int64    `protobuf:"varint,1,opt,name=amount,proto3" json:"amount,omitempty"`
-	Address              []byte   `protobuf:"bytes,2,opt,name=address,proto3" json:"address,omitempty"`
-	XXX_NoUnkeyedLiteral struct{} `json:"-"`
-	XXX_unrecognized     []byte   `json:"-"`
-	XXX_sizecache        int32    `json:"-"`
+type UnspentOutputs struct {
+	Outputs              []*UnspentOutput `protobuf:"bytes,1,rep,name=outputs,proto3" json:"outputs,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}         `json:"-"`
+	XXX_unrecognized     []byte           `json:"-"`
+	XXX_sizecache        int32            `json:"-"`
 }
 
-func (m *TxOutput) Reset()         { *m = TxOutput{} }
-func (m *TxOutput) String() string { return proto.CompactTextString(m) }
-func (*TxOutput) ProtoMessage()    {}
-func (*TxOutput) Descriptor() ([]byte, []int) {
-	return fileDescriptor_e2f027f54ad4521e, []int{5}
+func (m *UnspentOutputs) Reset()         { *m = UnspentOutputs{} }
+func (m *UnspentOutputs) String() string { return proto.CompactTextString(m) }
+func (*UnspentOutputs) ProtoMessage()    {}
+func (*UnspentOutputs) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e2f027f54ad4521e, []int{8}
 }
 
-func (m *TxOutput) XXX_Unmarshal(b []byte) error {
-	return xxx_messageInfo_TxOutput.Unmarshal(m, b)
+func (m *UnspentOutputs) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_UnspentOutputs.Unmarshal(m, b)
 }
-func (m *TxOutput) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	return xxx_messageInfo_TxOutput.Marshal(b, m, deterministic)
+func (m *UnspentOutputs) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_UnspentOutputs.Marshal(b, m, deterministic)
 }
-func (m *TxOutput) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_TxOutput.Merge(m, src)
+func (m *UnspentOutputs) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_UnspentOutputs.Merge(m, src)
 }
-func (m *TxOutput) XXX_Size() int {
-	return xxx_messageInfo_TxOutput.Size(m)
+func (m *UnspentOutputs) XXX_Size() int {
+	return xxx_messageInfo_UnspentOutputs.Size(m)
 }
-func (m *TxOutput) XXX_DiscardUnknown() {
-	xxx_messageInfo_TxOutput.DiscardUnknown(m)
+func (m *UnspentOutputs) XXX_DiscardUnknown() {
+	xxx_messageInfo_UnspentOutputs.DiscardUnknown(m)
 }
 
-var xxx_messageInfo_TxOutput proto.InternalMessageInfo
+var xxx_messageInfo_UnspentOutputs proto.InternalMessageInfo
 
-func (m *TxOutput) GetAmount() int64 {
+func (m *UnspentOutputs) GetOutputs() []*UnspentOutput {
 	if m != nil {
-		return m.Amount
+		return m.Outputs
 	}
-	return 0
+	return nil
 }
 
-func (m *TxOutput) GetAddress() []byte {
+type TxRequest struct {
+	Hash                 []byte   `protobuf:"bytes,1,opt,name=hash,proto3" json:"hash,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *TxRequest) Reset()         { *m = TxRequest{} }
+func (m *TxRequest) String() string { return proto.CompactTextString(m) }
+func (*TxRequest) ProtoMessage()    {}
+func (*TxRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e2f027f54ad4521e, []int{9}
+}
+
+func (m *TxRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_TxRequest.Unmarshal(m, b)
+}
+func (m *TxRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_TxRequest.Marshal(b, m, deterministic)
+}
+func (m *TxRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TxRequest.Merge(m, src)
+}
+func (m *TxRequest) XXX_Size() int {
+	return xxx_messageInfo_TxRequest.Size(m)
+}
+func (m *TxRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_TxRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TxRequest proto.InternalMessageInfo
+
+func (m *TxRequest) GetHash() []byte {
 	if m != nil {
-		return m.Address
+		return m.Hash
 	}
 	return nil
 }
 
-type Transaction struct {
-	Version              int32       `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
-	Inputs               []*TxInput  `protobuf:"bytes,2,rep,name=inputs,proto3" json:"inputs,omitempty"`
-	Outputs              []*TxOutput `protobuf:"bytes,3,rep,name=outputs,proto3" json:"outputs,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}    `json:"-"`
-	XXX_unrecognized     []byte      `json:"-"`
-	XXX_sizecache        int32       `json:"-"`
+type TransactionInfo struct {
+	Transaction *Transaction `protobuf:"bytes,1,opt,name=transaction,proto3" json:"transaction,omitempty"`
+	// amount of the inputs not spent by the outputs,
+	// claimed by the block producer
+	Fee int64 `protobuf:"varint,2,opt,name=fee,proto3" json:"fee,omitempty"`
+	// true while the tx waits in the mempool
+	Pending              bool     `protobuf:"varint,3,opt,name=pending,proto3" json:"pending,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
-func (m *Transaction) Reset()         { *m = Transaction{} }
-func (m *Transaction) String() string { return proto.CompactTextString(m) }
-func (*Transaction) ProtoMessage()    {}
-func (*Transaction) Descriptor() ([]byte, []int) {
-	return fileDescriptor_e2f027f54ad4521e, []int{6}
+func (m *TransactionInfo) Reset()         { *m = TransactionInfo{} }
+func (m *TransactionInfo) String() string { return proto.CompactTextString(m) }
+func (*TransactionInfo) ProtoMessage()    {}
+func (*TransactionInfo) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e2f027f54ad4521e, []int{10}
 }
 
-func (m *Transaction) XXX_Unmarshal(b []byte) error {
-	return xxx_messageInfo_Transaction.Unmarshal(m, b)
+func (m *TransactionInfo) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_TransactionInfo.Unmarshal(m, b)
 }
-func (m *Transaction) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	return xxx_messageInfo_Transaction.Marshal(b, m, deterministic)
+func (m *TransactionInfo) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_TransactionInfo.Marshal(b, m, deterministic)
 }
-func (m *Transaction) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_Transaction.Merge(m, src)
+func (m *TransactionInfo) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TransactionInfo.Merge(m, src)
 }
-func (m *Transaction) XXX_Size() int {
-	return xxx_messageInfo_Transaction.Size(m)
+func (m *TransactionInfo) XXX_Size() int {
+	return xxx_messageInfo_TransactionInfo.Size(m)
 }
-func (m *Transaction) XXX_DiscardUnknown() {
-	xxx_messageInfo_Transaction.DiscardUnknown(m)
+func (m *TransactionInfo) XXX_DiscardUnknown() {
+	xxx_messageInfo_TransactionInfo.DiscardUnknown(m)
 }
 
-var xxx_messageInfo_Transaction proto.InternalMessageInfo
+var xxx_messageInfo_TransactionInfo proto.InternalMessageInfo
 
-func (m *Transaction) GetVersion() int32 {
+func (m *TransactionInfo) GetTransaction() *Transaction {
 	if m != nil {
-		return m.Version
+		return m.Transaction
 	}
-	return 0
+	return nil
 }
 
-func (m *Transaction) GetInputs() []*TxInput {
+func (m *TransactionInfo) GetFee() int64 {
 	if m != nil {
-		return m.Inputs
+		return m.Fee
 	}
-	return nil
+	return 0
 }
 
-func (m *Transaction) GetOutputs() []*TxOutput {
+func (m *TransactionInfo) GetPending() bool {
 	if m != nil {
-		return m.Outputs
+		return m.Pending
+	}
+	return false
+}
+
+type Header struct {
+	Version              int32    `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
+	Height               int32    `protobuf:"varint,2,opt,name=height,proto3" json:"height,omitempty"`
+	PrevHash             []byte   `protobuf:"bytes,3,opt,name=prevHash,proto3" json:"prevHash,omitempty"`
+	RootHash             []byte   `protobuf:"bytes,4,opt,name=rootHash,proto3" json:"rootHash,omitempty"`
+	Timestamp            int64    `protobuf:"varint,5,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	EvidenceHash         []byte   `protobuf:"bytes,6,opt,name=evidenceHash,proto3" json:"evidenceHash,omitempty"`
+	ValidatorsHash       []byte   `protobuf:"bytes,7,opt,name=validatorsHash,proto3" json:"validatorsHash,omitempty"`
+	ChainID              string   `protobuf:"bytes,8,opt,name=chainID,proto3" json:"chainID,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Header) Reset()         { *m = Header{} }
+func (m *Header) String() string { return proto.CompactTextString(m) }
+func (*Header) ProtoMessage()    {}
+func (*Header) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e2f027f54ad4521e, []int{11}
+}
+
+func (m *Header) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Header.Unmarshal(m, b)
+}
+func (m *Header) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Header.Marshal(b, m, deterministic)
+}
+func (m *Header) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Header.Merge(m, src)
+}
+func (m *Header) XXX_Size() int {
+	return xxx_messageInfo_Header.Size(m)
+}
+func (m *Header) XXX_DiscardUnknown() {
+	xxx_messageInfo_Header.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Header proto.InternalMessageInfo
+
+func (m *Header) GetVersion() int32 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+func (m *Header) GetHeight() int32 {
+	if m != nil {
+		return m.Height
+	}
+	return 0
+}
+
+func (m *Header) GetPrevHash() []byte {
+	if m != nil {
+		return m.PrevHash
+	}
+	return nil
+}
+
+func (m *Header) GetRootHash() []byte {
+	if m != nil {
+		return m.RootHash
+	}
+	return nil
+}
+
+func (m *Header) GetTimestamp() int64 {
+	if m != nil {
+		return m.Timestamp
+	}
+	return 0
+}
+
+func (m *Header) GetEvidenceHash() []byte {
+	if m != nil {
+		return m.EvidenceHash
+	}
+	return nil
+}
+
+func (m *Header) GetValidatorsHash() []byte {
+	if m != nil {
+		return m.ValidatorsHash
+	}
+	return nil
+}
+
+func (m *Header) GetChainID() string {
+	if m != nil {
+		return m.ChainID
+	}
+	return ""
+}
+
+type TxInput struct {
+	// previous hash of the tx
+	// containing the out put we want to spend
+	PrevTxHash []byte `protobuf:"bytes,1,opt,name=PrevTxHash,proto3" json:"PrevTxHash,omitempty"`
+	// The index of the output of the previous transaction we
+	// want to spend
+	PrevOutIndex         uint32   `protobuf:"varint,2,opt,name=PrevOutIndex,proto3" json:"PrevOutIndex,omitempty"`
+	PublicKey            []byte   `protobuf:"bytes,3,opt,name=publicKey,proto3" json:"publicKey,omitempty"`
+	Signature            []byte   `protobuf:"bytes,4,opt,name=signature,proto3" json:"signature,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *TxInput) Reset()         { *m = TxInput{} }
+func (m *TxInput) String() string { return proto.CompactTextString(m) }
+func (*TxInput) ProtoMessage()    {}
+func (*TxInput) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e2f027f54ad4521e, []int{12}
+}
+
+func (m *TxInput) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_TxInput.Unmarshal(m, b)
+}
+func (m *TxInput) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_TxInput.Marshal(b, m, deterministic)
+}
+func (m *TxInput) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TxInput.Merge(m, src)
+}
+func (m *TxInput) XXX_Size() int {
+	return xxx_messageInfo_TxInput.Size(m)
+}
+func (m *TxInput) XXX_DiscardUnknown() {
+	xxx_messageInfo_TxInput.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TxInput proto.InternalMessageInfo
+
+func (m *TxInput) GetPrevTxHash() []byte {
+	if m != nil {
+		return m.PrevTxHash
+	}
+	return nil
+}
+
+func (m *TxInput) GetPrevOutIndex() uint32 {
+	if m != nil {
+		return m.PrevOutIndex
+	}
+	return 0
+}
+
+func (m *TxInput) GetPublicKey() []byte {
+	if m != nil {
+		return m.PublicKey
+	}
+	return nil
+}
+
+func (m *TxInput) GetSignature() []byte {
+	if m != nil {
+		return m.Signature
+	}
+	return nil
+}
+
+type TxOutput struct {
+	Amount               int64    `protobuf:"varint,1,opt,name=amount,proto3" json:"amount,omitempty"`
+	Address              []byte   `protobuf:"bytes,2,opt,name=address,proto3" json:"address,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *TxOutput) Reset()         { *m = TxOutput{} }
+func (m *TxOutput) String() string { return proto.CompactTextString(m) }
+func (*TxOutput) ProtoMessage()    {}
+func (*TxOutput) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e2f027f54ad4521e, []int{13}
+}
+
+func (m *TxOutput) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_TxOutput.Unmarshal(m, b)
+}
+func (m *TxOutput) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_TxOutput.Marshal(b, m, deterministic)
+}
+func (m *TxOutput) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TxOutput.Merge(m, src)
+}
+func (m *TxOutput) XXX_Size() int {
+	return xxx_messageInfo_TxOutput.Size(m)
+}
+func (m *TxOutput) XXX_DiscardUnknown() {
+	xxx_messageInfo_TxOutput.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TxOutput proto.InternalMessageInfo
+
+func (m *TxOutput) GetAmount() int64 {
+	if m != nil {
+		return m.Amount
+	}
+	return 0
+}
+
+func (m *TxOutput) GetAddress() []byte {
+	if m != nil {
+		return m.Address
+	}
+	return nil
+}
+
+type Transaction struct {
+	Version int32       `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
+	Inputs  []*TxInput  `protobuf:"bytes,2,rep,name=inputs,proto3" json:"inputs,omitempty"`
+	Outputs []*TxOutput `protobuf:"bytes,3,rep,name=outputs,proto3" json:"outputs,omitempty"`
+	Type    TxType      `protobuf:"varint,4,opt,name=type,proto3,enum=TxType" json:"type,omitempty"`
+	// height of the block a coinbase tx belongs to,
+	// keeps the coinbase hashes unique
+	Height int32 `protobuf:"varint,5,opt,name=height,proto3" json:"height,omitempty"`
+	// address of the validator a delegate or undelegate
+	// tx bonds its outputs to
+	Validator []byte `protobuf:"bytes,6,opt,name=validator,proto3" json:"validator,omitempty"`
+	// percent of the block rewards a stake tx lets its
+	// validator keep before the rest is shared with its
+	// delegators, 0 keeps the one set before
+	Commission int32 `protobuf:"varint,7,opt,name=commission,proto3" json:"commission,omitempty"`
+	// chain the tx belongs to, keeps its signatures from
+	// being replayed on other chains
+	ChainID              string   `protobuf:"bytes,8,opt,name=chainID,proto3" json:"chainID,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Transaction) Reset()         { *m = Transaction{} }
+func (m *Transaction) String() string { return proto.CompactTextString(m) }
+func (*Transaction) ProtoMessage()    {}
+func (*Transaction) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e2f027f54ad4521e, []int{14}
+}
+
+func (m *Transaction) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Transaction.Unmarshal(m, b)
+}
+func (m *Transaction) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Transaction.Marshal(b, m, deterministic)
+}
+func (m *Transaction) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Transaction.Merge(m, src)
+}
+func (m *Transaction) XXX_Size() int {
+	return xxx_messageInfo_Transaction.Size(m)
+}
+func (m *Transaction) XXX_DiscardUnknown() {
+	xxx_messageInfo_Transaction.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Transaction proto.InternalMessageInfo
+
+func (m *Transaction) GetVersion() int32 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+func (m *Transaction) GetInputs() []*TxInput {
+	if m != nil {
+		return m.Inputs
+	}
+	return nil
+}
+
+func (m *Transaction) GetOutputs() []*TxOutput {
+	if m != nil {
+		return m.Outputs
+	}
+	return nil
+}
+
+func (m *Transaction) GetType() TxType {
+	if m != nil {
+		return m.Type
+	}
+	return TxType_TRANSFER
+}
+
+func (m *Transaction) GetHeight() int32 {
+	if m != nil {
+		return m.Height
+	}
+	return 0
+}
+
+func (m *Transaction) GetValidator() []byte {
+	if m != nil {
+		return m.Validator
+	}
+	return nil
+}
+
+func (m *Transaction) GetCommission() int32 {
+	if m != nil {
+		return m.Commission
+	}
+	return 0
+}
+
+func (m *Transaction) GetChainID() string {
+	if m != nil {
+		return m.ChainID
+	}
+	return ""
+}
+
+type BlockRequest struct {
+	Hash                 []byte   `protobuf:"bytes,1,opt,name=hash,proto3" json:"hash,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *BlockRequest) Reset()         { *m = BlockRequest{} }
+func (m *BlockRequest) String() string { return proto.CompactTextString(m) }
+func (*BlockRequest) ProtoMessage()    {}
+func (*BlockRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e2f027f54ad4521e, []int{15}
+}
+
+func (m *BlockRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_BlockRequest.Unmarshal(m, b)
+}
+func (m *BlockRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_BlockRequest.Marshal(b, m, deterministic)
+}
+func (m *BlockRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_BlockRequest.Merge(m, src)
+}
+func (m *BlockRequest) XXX_Size() int {
+	return xxx_messageInfo_BlockRequest.Size(m)
+}
+func (m *BlockRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_BlockRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_BlockRequest proto.InternalMessageInfo
+
+func (m *BlockRequest) GetHash() []byte {
+	if m != nil {
+		return m.Hash
+	}
+	return nil
+}
+
+// The block the proposer of a consensus round puts to the vote
+type Proposal struct {
+	Block *Block `protobuf:"bytes,1,opt,name=block,proto3" json:"block,omitempty"`
+	Round int32  `protobuf:"varint,2,opt,name=round,proto3" json:"round,omitempty"`
+	// round the block got a quorum of prevotes in,
+	// -1 for a block that is new
+	PolRound             int32    `protobuf:"varint,3,opt,name=polRound,proto3" json:"polRound,omitempty"`
+	PublicKey            []byte   `protobuf:"bytes,4,opt,name=publicKey,proto3" json:"publicKey,omitempty"`
+	Signature            []byte   `protobuf:"bytes,5,opt,name=signature,proto3" json:"signature,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Proposal) Reset()         { *m = Proposal{} }
+func (m *Proposal) String() string { return proto.CompactTextString(m) }
+func (*Proposal) ProtoMessage()    {}
+func (*Proposal) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e2f027f54ad4521e, []int{16}
+}
+
+func (m *Proposal) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Proposal.Unmarshal(m, b)
+}
+func (m *Proposal) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Proposal.Marshal(b, m, deterministic)
+}
+func (m *Proposal) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Proposal.Merge(m, src)
+}
+func (m *Proposal) XXX_Size() int {
+	return xxx_messageInfo_Proposal.Size(m)
+}
+func (m *Proposal) XXX_DiscardUnknown() {
+	xxx_messageInfo_Proposal.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Proposal proto.InternalMessageInfo
+
+func (m *Proposal) GetBlock() *Block {
+	if m != nil {
+		return m.Block
+	}
+	return nil
+}
+
+func (m *Proposal) GetRound() int32 {
+	if m != nil {
+		return m.Round
+	}
+	return 0
+}
+
+func (m *Proposal) GetPolRound() int32 {
+	if m != nil {
+		return m.PolRound
+	}
+	return 0
+}
+
+func (m *Proposal) GetPublicKey() []byte {
+	if m != nil {
+		return m.PublicKey
+	}
+	return nil
+}
+
+func (m *Proposal) GetSignature() []byte {
+	if m != nil {
+		return m.Signature
+	}
+	return nil
+}
+
+type Vote struct {
+	Type   VoteType `protobuf:"varint,1,opt,name=type,proto3,enum=VoteType" json:"type,omitempty"`
+	Height int32    `protobuf:"varint,2,opt,name=height,proto3" json:"height,omitempty"`
+	Round  int32    `protobuf:"varint,3,opt,name=round,proto3" json:"round,omitempty"`
+	// empty for a vote for no block
+	BlockHash []byte `protobuf:"bytes,4,opt,name=blockHash,proto3" json:"blockHash,omitempty"`
+	PublicKey []byte `protobuf:"bytes,5,opt,name=publicKey,proto3" json:"publicKey,omitempty"`
+	Signature []byte `protobuf:"bytes,6,opt,name=signature,proto3" json:"signature,omitempty"`
+	// chain the vote belongs to, votes for no block name no
+	// block of the chain
+	ChainID              string   `protobuf:"bytes,7,opt,name=chainID,proto3" json:"chainID,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Vote) Reset()         { *m = Vote{} }
+func (m *Vote) String() string { return proto.CompactTextString(m) }
+func (*Vote) ProtoMessage()    {}
+func (*Vote) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e2f027f54ad4521e, []int{17}
+}
+
+func (m *Vote) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Vote.Unmarshal(m, b)
+}
+func (m *Vote) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Vote.Marshal(b, m, deterministic)
+}
+func (m *Vote) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Vote.Merge(m, src)
+}
+func (m *Vote) XXX_Size() int {
+	return xxx_messageInfo_Vote.Size(m)
+}
+func (m *Vote) XXX_DiscardUnknown() {
+	xxx_messageInfo_Vote.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Vote proto.InternalMessageInfo
+
+func (m *Vote) GetType() VoteType {
+	if m != nil {
+		return m.Type
+	}
+	return VoteType_PREVOTE
+}
+
+func (m *Vote) GetHeight() int32 {
+	if m != nil {
+		return m.Height
+	}
+	return 0
+}
+
+func (m *Vote) GetRound() int32 {
+	if m != nil {
+		return m.Round
+	}
+	return 0
+}
+
+func (m *Vote) GetBlockHash() []byte {
+	if m != nil {
+		return m.BlockHash
+	}
+	return nil
+}
+
+func (m *Vote) GetPublicKey() []byte {
+	if m != nil {
+		return m.PublicKey
+	}
+	return nil
+}
+
+func (m *Vote) GetSignature() []byte {
+	if m != nil {
+		return m.Signature
+	}
+	return nil
+}
+
+func (m *Vote) GetChainID() string {
+	if m != nil {
+		return m.ChainID
+	}
+	return ""
+}
+
+// The precommits of the validators that decided on a block,
+// stored next to the block
+type Commit struct {
+	Height               int32    `protobuf:"varint,1,opt,name=height,proto3" json:"height,omitempty"`
+	Round                int32    `protobuf:"varint,2,opt,name=round,proto3" json:"round,omitempty"`
+	BlockHash            []byte   `protobuf:"bytes,3,opt,name=blockHash,proto3" json:"blockHash,omitempty"`
+	Precommits           []*Vote  `protobuf:"bytes,4,rep,name=precommits,proto3" json:"precommits,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Commit) Reset()         { *m = Commit{} }
+func (m *Commit) String() string { return proto.CompactTextString(m) }
+func (*Commit) ProtoMessage()    {}
+func (*Commit) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e2f027f54ad4521e, []int{18}
+}
+
+func (m *Commit) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Commit.Unmarshal(m, b)
+}
+func (m *Commit) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Commit.Marshal(b, m, deterministic)
+}
+func (m *Commit) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Commit.Merge(m, src)
+}
+func (m *Commit) XXX_Size() int {
+	return xxx_messageInfo_Commit.Size(m)
+}
+func (m *Commit) XXX_DiscardUnknown() {
+	xxx_messageInfo_Commit.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Commit proto.InternalMessageInfo
+
+func (m *Commit) GetHeight() int32 {
+	if m != nil {
+		return m.Height
+	}
+	return 0
+}
+
+func (m *Commit) GetRound() int32 {
+	if m != nil {
+		return m.Round
+	}
+	return 0
+}
+
+func (m *Commit) GetBlockHash() []byte {
+	if m != nil {
+		return m.BlockHash
+	}
+	return nil
+}
+
+func (m *Commit) GetPrecommits() []*Vote {
+	if m != nil {
+		return m.Precommits
+	}
+	return nil
+}
+
+// The header of a block proposed in a consensus round, with the
+// signature of the proposal
+type SignedHeader struct {
+	Header               *Header  `protobuf:"bytes,1,opt,name=header,proto3" json:"header,omitempty"`
+	Round                int32    `protobuf:"varint,2,opt,name=round,proto3" json:"round,omitempty"`
+	PolRound             int32    `protobuf:"varint,3,opt,name=polRound,proto3" json:"polRound,omitempty"`
+	PublicKey            []byte   `protobuf:"bytes,4,opt,name=publicKey,proto3" json:"publicKey,omitempty"`
+	Signature            []byte   `protobuf:"bytes,5,opt,name=signature,proto3" json:"signature,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SignedHeader) Reset()         { *m = SignedHeader{} }
+func (m *SignedHeader) String() string { return proto.CompactTextString(m) }
+func (*SignedHeader) ProtoMessage()    {}
+func (*SignedHeader) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e2f027f54ad4521e, []int{19}
+}
+
+func (m *SignedHeader) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SignedHeader.Unmarshal(m, b)
+}
+func (m *SignedHeader) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SignedHeader.Marshal(b, m, deterministic)
+}
+func (m *SignedHeader) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SignedHeader.Merge(m, src)
+}
+func (m *SignedHeader) XXX_Size() int {
+	return xxx_messageInfo_SignedHeader.Size(m)
+}
+func (m *SignedHeader) XXX_DiscardUnknown() {
+	xxx_messageInfo_SignedHeader.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SignedHeader proto.InternalMessageInfo
+
+func (m *SignedHeader) GetHeader() *Header {
+	if m != nil {
+		return m.Header
+	}
+	return nil
+}
+
+func (m *SignedHeader) GetRound() int32 {
+	if m != nil {
+		return m.Round
+	}
+	return 0
+}
+
+func (m *SignedHeader) GetPolRound() int32 {
+	if m != nil {
+		return m.PolRound
+	}
+	return 0
+}
+
+func (m *SignedHeader) GetPublicKey() []byte {
+	if m != nil {
+		return m.PublicKey
+	}
+	return nil
+}
+
+func (m *SignedHeader) GetSignature() []byte {
+	if m != nil {
+		return m.Signature
+	}
+	return nil
+}
+
+// Two different headers a validator proposed for the same height
+// and round, or two votes of one type it cast for different blocks
+// in the same round, ordered by their hash
+type Evidence struct {
+	A                    *SignedHeader `protobuf:"bytes,1,opt,name=a,proto3" json:"a,omitempty"`
+	B                    *SignedHeader `protobuf:"bytes,2,opt,name=b,proto3" json:"b,omitempty"`
+	VoteA                *Vote         `protobuf:"bytes,3,opt,name=voteA,proto3" json:"voteA,omitempty"`
+	VoteB                *Vote         `protobuf:"bytes,4,opt,name=voteB,proto3" json:"voteB,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}      `json:"-"`
+	XXX_unrecognized     []byte        `json:"-"`
+	XXX_sizecache        int32         `json:"-"`
+}
+
+func (m *Evidence) Reset()         { *m = Evidence{} }
+func (m *Evidence) String() string { return proto.CompactTextString(m) }
+func (*Evidence) ProtoMessage()    {}
+func (*Evidence) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e2f027f54ad4521e, []int{20}
+}
+
+func (m *Evidence) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Evidence.Unmarshal(m, b)
+}
+func (m *Evidence) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Evidence.Marshal(b, m, deterministic)
+}
+func (m *Evidence) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Evidence.Merge(m, src)
+}
+func (m *Evidence) XXX_Size() int {
+	return xxx_messageInfo_Evidence.Size(m)
+}
+func (m *Evidence) XXX_DiscardUnknown() {
+	xxx_messageInfo_Evidence.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Evidence proto.InternalMessageInfo
+
+func (m *Evidence) GetA() *SignedHeader {
+	if m != nil {
+		return m.A
+	}
+	return nil
+}
+
+func (m *Evidence) GetB() *SignedHeader {
+	if m != nil {
+		return m.B
+	}
+	return nil
+}
+
+func (m *Evidence) GetVoteA() *Vote {
+	if m != nil {
+		return m.VoteA
+	}
+	return nil
+}
+
+func (m *Evidence) GetVoteB() *Vote {
+	if m != nil {
+		return m.VoteB
+	}
+	return nil
+}
+
+// The validator set deciding the main chain block at height,
+// 0 for the block on top of the tip
+type ValidatorsRequest struct {
+	Height               int32    `protobuf:"varint,1,opt,name=height,proto3" json:"height,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ValidatorsRequest) Reset()         { *m = ValidatorsRequest{} }
+func (m *ValidatorsRequest) String() string { return proto.CompactTextString(m) }
+func (*ValidatorsRequest) ProtoMessage()    {}
+func (*ValidatorsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e2f027f54ad4521e, []int{21}
+}
+
+func (m *ValidatorsRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ValidatorsRequest.Unmarshal(m, b)
+}
+func (m *ValidatorsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ValidatorsRequest.Marshal(b, m, deterministic)
+}
+func (m *ValidatorsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ValidatorsRequest.Merge(m, src)
+}
+func (m *ValidatorsRequest) XXX_Size() int {
+	return xxx_messageInfo_ValidatorsRequest.Size(m)
+}
+func (m *ValidatorsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ValidatorsRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ValidatorsRequest proto.InternalMessageInfo
+
+func (m *ValidatorsRequest) GetHeight() int32 {
+	if m != nil {
+		return m.Height
+	}
+	return 0
+}
+
+type ValidatorInfo struct {
+	Address []byte `protobuf:"bytes,1,opt,name=address,proto3" json:"address,omitempty"`
+	// bonded by the validator and delegated to it
+	Stake int64 `protobuf:"varint,2,opt,name=stake,proto3" json:"stake,omitempty"`
+	// bonded by the validator itself
+	SelfStake            int64    `protobuf:"varint,3,opt,name=selfStake,proto3" json:"selfStake,omitempty"`
+	Commission           int32    `protobuf:"varint,4,opt,name=commission,proto3" json:"commission,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ValidatorInfo) Reset()         { *m = ValidatorInfo{} }
+func (m *ValidatorInfo) String() string { return proto.CompactTextString(m) }
+func (*ValidatorInfo) ProtoMessage()    {}
+func (*ValidatorInfo) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e2f027f54ad4521e, []int{22}
+}
+
+func (m *ValidatorInfo) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ValidatorInfo.Unmarshal(m, b)
+}
+func (m *ValidatorInfo) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ValidatorInfo.Marshal(b, m, deterministic)
+}
+func (m *ValidatorInfo) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ValidatorInfo.Merge(m, src)
+}
+func (m *ValidatorInfo) XXX_Size() int {
+	return xxx_messageInfo_ValidatorInfo.Size(m)
+}
+func (m *ValidatorInfo) XXX_DiscardUnknown() {
+	xxx_messageInfo_ValidatorInfo.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ValidatorInfo proto.InternalMessageInfo
+
+func (m *ValidatorInfo) GetAddress() []byte {
+	if m != nil {
+		return m.Address
+	}
+	return nil
+}
+
+func (m *ValidatorInfo) GetStake() int64 {
+	if m != nil {
+		return m.Stake
+	}
+	return 0
+}
+
+func (m *ValidatorInfo) GetSelfStake() int64 {
+	if m != nil {
+		return m.SelfStake
+	}
+	return 0
+}
+
+func (m *ValidatorInfo) GetCommission() int32 {
+	if m != nil {
+		return m.Commission
+	}
+	return 0
+}
+
+type ValidatorList struct {
+	Validators []*ValidatorInfo `protobuf:"bytes,1,rep,name=validators,proto3" json:"validators,omitempty"`
+	// the hash the headers of the epoch commit to
+	Hash                 []byte   `protobuf:"bytes,2,opt,name=hash,proto3" json:"hash,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ValidatorList) Reset()         { *m = ValidatorList{} }
+func (m *ValidatorList) String() string { return proto.CompactTextString(m) }
+func (*ValidatorList) ProtoMessage()    {}
+func (*ValidatorList) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e2f027f54ad4521e, []int{23}
+}
+
+func (m *ValidatorList) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ValidatorList.Unmarshal(m, b)
+}
+func (m *ValidatorList) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ValidatorList.Marshal(b, m, deterministic)
+}
+func (m *ValidatorList) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ValidatorList.Merge(m, src)
+}
+func (m *ValidatorList) XXX_Size() int {
+	return xxx_messageInfo_ValidatorList.Size(m)
+}
+func (m *ValidatorList) XXX_DiscardUnknown() {
+	xxx_messageInfo_ValidatorList.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ValidatorList proto.InternalMessageInfo
+
+func (m *ValidatorList) GetValidators() []*ValidatorInfo {
+	if m != nil {
+		return m.Validators
+	}
+	return nil
+}
+
+func (m *ValidatorList) GetHash() []byte {
+	if m != nil {
+		return m.Hash
+	}
+	return nil
+}
+
+// Filters the delegations by delegator and validator,
+// an empty address matches any
+type DelegationsRequest struct {
+	Delegator            []byte   `protobuf:"bytes,1,opt,name=delegator,proto3" json:"delegator,omitempty"`
+	Validator            []byte   `protobuf:"bytes,2,opt,name=validator,proto3" json:"validator,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DelegationsRequest) Reset()         { *m = DelegationsRequest{} }
+func (m *DelegationsRequest) String() string { return proto.CompactTextString(m) }
+func (*DelegationsRequest) ProtoMessage()    {}
+func (*DelegationsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e2f027f54ad4521e, []int{24}
+}
+
+func (m *DelegationsRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DelegationsRequest.Unmarshal(m, b)
+}
+func (m *DelegationsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DelegationsRequest.Marshal(b, m, deterministic)
+}
+func (m *DelegationsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DelegationsRequest.Merge(m, src)
+}
+func (m *DelegationsRequest) XXX_Size() int {
+	return xxx_messageInfo_DelegationsRequest.Size(m)
+}
+func (m *DelegationsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_DelegationsRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DelegationsRequest proto.InternalMessageInfo
+
+func (m *DelegationsRequest) GetDelegator() []byte {
+	if m != nil {
+		return m.Delegator
+	}
+	return nil
+}
+
+func (m *DelegationsRequest) GetValidator() []byte {
+	if m != nil {
+		return m.Validator
+	}
+	return nil
+}
+
+type Delegation struct {
+	Delegator            []byte   `protobuf:"bytes,1,opt,name=delegator,proto3" json:"delegator,omitempty"`
+	Validator            []byte   `protobuf:"bytes,2,opt,name=validator,proto3" json:"validator,omitempty"`
+	Amount               int64    `protobuf:"varint,3,opt,name=amount,proto3" json:"amount,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Delegation) Reset()         { *m = Delegation{} }
+func (m *Delegation) String() string { return proto.CompactTextString(m) }
+func (*Delegation) ProtoMessage()    {}
+func (*Delegation) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e2f027f54ad4521e, []int{25}
+}
+
+func (m *Delegation) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Delegation.Unmarshal(m, b)
+}
+func (m *Delegation) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Delegation.Marshal(b, m, deterministic)
+}
+func (m *Delegation) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Delegation.Merge(m, src)
+}
+func (m *Delegation) XXX_Size() int {
+	return xxx_messageInfo_Delegation.Size(m)
+}
+func (m *Delegation) XXX_DiscardUnknown() {
+	xxx_messageInfo_Delegation.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Delegation proto.InternalMessageInfo
+
+func (m *Delegation) GetDelegator() []byte {
+	if m != nil {
+		return m.Delegator
+	}
+	return nil
+}
+
+func (m *Delegation) GetValidator() []byte {
+	if m != nil {
+		return m.Validator
+	}
+	return nil
+}
+
+func (m *Delegation) GetAmount() int64 {
+	if m != nil {
+		return m.Amount
+	}
+	return 0
+}
+
+type Delegations struct {
+	Delegations          []*Delegation `protobuf:"bytes,1,rep,name=delegations,proto3" json:"delegations,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}      `json:"-"`
+	XXX_unrecognized     []byte        `json:"-"`
+	XXX_sizecache        int32         `json:"-"`
+}
+
+func (m *Delegations) Reset()         { *m = Delegations{} }
+func (m *Delegations) String() string { return proto.CompactTextString(m) }
+func (*Delegations) ProtoMessage()    {}
+func (*Delegations) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e2f027f54ad4521e, []int{26}
+}
+
+func (m *Delegations) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Delegations.Unmarshal(m, b)
+}
+func (m *Delegations) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Delegations.Marshal(b, m, deterministic)
+}
+func (m *Delegations) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Delegations.Merge(m, src)
+}
+func (m *Delegations) XXX_Size() int {
+	return xxx_messageInfo_Delegations.Size(m)
+}
+func (m *Delegations) XXX_DiscardUnknown() {
+	xxx_messageInfo_Delegations.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Delegations proto.InternalMessageInfo
+
+func (m *Delegations) GetDelegations() []*Delegation {
+	if m != nil {
+		return m.Delegations
 	}
 	return nil
 }
 
 func init() {
+	proto.RegisterEnum("TxType", TxType_name, TxType_value)
+	proto.RegisterEnum("VoteType", VoteType_name, VoteType_value)
 	proto.RegisterType((*Block)(nil), "Block")
 	proto.RegisterType((*Version)(nil), "Version")
 	proto.RegisterType((*Ack)(nil), "Ack")
+	proto.RegisterType((*HeightRange)(nil), "HeightRange")
+	proto.RegisterType((*Headers)(nil), "Headers")
+	proto.RegisterType((*AddressRequest)(nil), "AddressRequest")
+	proto.RegisterType((*Balance)(nil), "Balance")
+	proto.RegisterType((*UnspentOutput)(nil), "UnspentOutput")
+	proto.RegisterType((*UnspentOutputs)(nil), "UnspentOutputs")
+	proto.RegisterType((*TxRequest)(nil), "TxRequest")
+	proto.RegisterType((*TransactionInfo)(nil), "TransactionInfo")
 	proto.RegisterType((*Header)(nil), "Header")
 	proto.RegisterType((*TxInput)(nil), "TxInput")
 	proto.RegisterType((*TxOutput)(nil), "TxOutput")
 	proto.RegisterType((*Transaction)(nil), "Transaction")
+	proto.RegisterType((*BlockRequest)(nil), "BlockRequest")
+	proto.RegisterType((*Proposal)(nil), "Proposal")
+	proto.RegisterType((*Vote)(nil), "Vote")
+	proto.RegisterType((*Commit)(nil), "Commit")
+	proto.RegisterType((*SignedHeader)(nil), "SignedHeader")
+	proto.RegisterType((*Evidence)(nil), "Evidence")
+	proto.RegisterType((*ValidatorsRequest)(nil), "ValidatorsRequest")
+	proto.RegisterType((*ValidatorInfo)(nil), "ValidatorInfo")
+	proto.RegisterType((*ValidatorList)(nil), "ValidatorList")
+	proto.RegisterType((*DelegationsRequest)(nil), "DelegationsRequest")
+	proto.RegisterType((*Delegation)(nil), "Delegation")
+	proto.RegisterType((*Delegations)(nil), "Delegations")
 }
 
 func init() { proto.RegisterFile("proto/types.proto", fileDescriptor_e2f027f54ad4521e) }
 
 var fileDescriptor_e2f027f54ad4521e = []byte{
-	// 476 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xa4, 0x53, 0xb1, 0x6e, 0xdb, 0x30,
-	0x10, 0x85, 0x22, 0x5b, 0xb2, 0xce, 0xea, 0x10, 0x0e, 0x81, 0x60, 0x14, 0x8d, 0xa0, 0x02, 0xad,
-	0x96, 0xca, 0x89, 0x53, 0x04, 0x2d, 0xd0, 0xc5, 0x99, 0x1c, 0xb4, 0x68, 0x02, 0xc2, 0xe8, 0xd0,
-	0x8d, 0x96, 0x08, 0x8b, 0xb0, 0x4d, 0x0a, 0x24, 0xe5, 0x3a, 0x9f, 0xd0, 0xad, 0x53, 0xbf, 0xb7,
-	0x20, 0x2d, 0xc9, 0x76, 0x87, 0x76, 0xc8, 0xa4, 0x7b, 0xef, 0x0e, 0x77, 0xef, 0x9e, 0x8e, 0x70,
-	0x5e, 0x49, 0xa1, 0xc5, 0x58, 0x3f, 0x55, 0x54, 0x65, 0x36, 0x4e, 0x7e, 0x3b, 0xd0, 0xbf, 0x5b,
-	0x8b, 0x7c, 0x85, 0x2e, 0xc1, 0x2b, 0x29, 0x29, 0xa8, 0x8c, 0x9c, 0xd8, 0x49, 0x87, 0x13, 0x3f,
-	0x9b, 0x59, 0x88, 0x1b, 0x1a, 0x5d, 0x41, 0xa8, 0x25, 0xe1, 0x8a, 0xe4, 0x9a, 0x09, 0xae, 0xa2,
-	0xb3, 0xd8, 0x4d, 0x87, 0x93, 0x30, 0x9b, 0x1f, 0x48, 0x7c, 0x52, 0x81, 0x5e, 0x42, 0x50, 0xd5,
-	0x8b, 0x35, 0xcb, 0x3f, 0xd3, 0xa7, 0xc8, 0x8d, 0x9d, 0x34, 0xc4, 0x07, 0xc2, 0x64, 0x15, 0x5b,
-	0x72, 0xa2, 0x6b, 0x49, 0xa3, 0xde, 0x3e, 0xdb, 0x11, 0xc9, 0x0f, 0xf0, 0xbf, 0x51, 0xa9, 0x98,
-	0xe0, 0x28, 0x02, 0x7f, 0xbb, 0x0f, 0xad, 0xb4, 0x00, 0xb7, 0x10, 0x5d, 0x18, 0xcd, 0x6c, 0x59,
-	0xea, 0xe8, 0x2c, 0x76, 0xd2, 0x3e, 0x6e, 0x10, 0x7a, 0x05, 0xb0, 0x66, 0x4a, 0x53, 0x3e, 0x2d,
-	0x0a, 0x69, 0x27, 0x07, 0xf8, 0x88, 0x41, 0x23, 0x18, 0x54, 0x94, 0xca, 0x2f, 0x4c, 0xe9, 0xa8,
-	0x17, 0xbb, 0x69, 0x80, 0x3b, 0x9c, 0xf4, 0xc1, 0x9d, 0xe6, 0xab, 0xe4, 0x97, 0x03, 0xde, 0xde,
-	0x80, 0xbf, 0xe7, 0xf7, 0xff, 0x3f, 0xdf, 0xf4, 0x97, 0x74, 0x3b, 0x23, 0xaa, 0x6c, 0xf6, 0xee,
-	0xb0, 0xc9, 0x49, 0x21, 0xb4, 0xcd, 0xed, 0xb7, 0xee, 0xb0, 0xb1, 0x44, 0xb3, 0x0d, 0x55, 0x9a,
-	0x6c, 0xaa, 0xa8, 0x1f, 0x3b, 0xa9, 0x8b, 0x0f, 0x44, 0xf2, 0xd3, 0x01, 0x7f, 0xbe, 0xbb, 0xe7,
-	0x55, 0x6d, 0x37, 0x7c, 0x94, 0x74, 0x3b, 0xdf, 0xd9, 0x3e, 0x8e, 0xed, 0x73, 0xc4, 0xa0, 0x04,
-	0x42, 0x83, 0x1e, 0x6a, 0x7d, 0xcf, 0x0b, 0xba, 0xb3, 0xfa, 0x5e, 0xe0, 0x13, 0xee, 0x59, 0xbf,
-	0xe7, 0x13, 0x0c, 0xe6, 0xbb, 0x87, 0x5a, 0x1b, 0x2d, 0x17, 0xe0, 0x91, 0x8d, 0xa8, 0xb9, 0xb6,
-	0x3a, 0x5c, 0xdc, 0x20, 0xe3, 0x1b, 0x29, 0x0a, 0x49, 0x95, 0xb2, 0xe3, 0x43, 0xdc, 0xc2, 0x84,
-	0xc3, 0xf0, 0xe8, 0x6a, 0xfe, 0x61, 0x70, 0x0c, 0x1e, 0x33, 0xfb, 0xb6, 0xd7, 0x36, 0xc8, 0x1a,
-	0x03, 0x70, 0xc3, 0xa3, 0xd7, 0xe0, 0x0b, 0x2b, 0x43, 0x45, 0xae, 0x2d, 0x09, 0xb2, 0x56, 0x18,
-	0x6e, 0x33, 0x93, 0x47, 0xe8, 0x7d, 0x15, 0x05, 0x45, 0x97, 0x10, 0xcc, 0x08, 0x2f, 0x54, 0x49,
-	0x56, 0x14, 0x0d, 0xb2, 0xe6, 0xc0, 0x46, 0x5d, 0x84, 0xde, 0xc2, 0xb9, 0x29, 0x58, 0xd3, 0x63,
-	0x79, 0x27, 0x27, 0x3e, 0xea, 0x65, 0xd3, 0x7c, 0x75, 0x97, 0x7e, 0x7f, 0xb3, 0x64, 0xba, 0xac,
-	0x17, 0x59, 0x2e, 0x36, 0x63, 0xf5, 0xe1, 0xea, 0xe3, 0xed, 0xf5, 0xed, 0xf5, 0xcd, 0xfb, 0xf1,
-	0x52, 0xbc, 0x5b, 0x98, 0xf7, 0x44, 0xe5, 0xd8, 0xbe, 0xb0, 0x85, 0x67, 0x3f, 0x37, 0x7f, 0x02,
-	0x00, 0x00, 0xff, 0xff, 0x31, 0x58, 0x81, 0xfc, 0x7d, 0x03, 0x00, 0x00,
+	// 1405 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xbc, 0x57, 0x3f, 0x73, 0xdb, 0xc6,
+	0x12, 0x37, 0x48, 0x82, 0x04, 0x96, 0x14, 0x4d, 0xdf, 0xf3, 0xbc, 0xc1, 0xa3, 0xf4, 0x6c, 0x19,
+	0x8e, 0x6d, 0x8d, 0x13, 0x9f, 0x6c, 0x29, 0xe3, 0xc9, 0x1f, 0x37, 0x94, 0xcd, 0x48, 0x1a, 0xdb,
+	0x92, 0xe6, 0x44, 0xab, 0x70, 0x15, 0x88, 0x38, 0x51, 0x18, 0x91, 0x38, 0x06, 0x38, 0x6a, 0xe8,
+	0xc9, 0x24, 0x7d, 0xca, 0x34, 0xe9, 0xf2, 0x25, 0xd2, 0xa7, 0x4e, 0x91, 0xef, 0x93, 0x26, 0x4d,
+	0xe6, 0xfe, 0x00, 0x38, 0x50, 0x96, 0x5c, 0x78, 0x26, 0x15, 0x6f, 0x7f, 0xbb, 0xb8, 0xdb, 0xdb,
+	0xdd, 0xdf, 0xde, 0x12, 0x6e, 0x4c, 0x13, 0xc6, 0xd9, 0x3a, 0x7f, 0x37, 0xa5, 0x29, 0x96, 0x6b,
+	0xff, 0x77, 0x0b, 0xec, 0xad, 0x31, 0x1b, 0x9e, 0xa1, 0xdb, 0x50, 0x3f, 0xa5, 0x41, 0x48, 0x13,
+	0xcf, 0x5a, 0xb5, 0xd6, 0x9a, 0x1b, 0x0d, 0xbc, 0x23, 0x45, 0xa2, 0x61, 0xf4, 0x18, 0x5a, 0x3c,
+	0x09, 0xe2, 0x34, 0x18, 0xf2, 0x88, 0xc5, 0xa9, 0x57, 0x59, 0xad, 0xae, 0x35, 0x37, 0x5a, 0x78,
+	0x50, 0x80, 0xa4, 0x64, 0x81, 0x56, 0xc0, 0x9d, 0xce, 0x8e, 0xc7, 0xd1, 0xf0, 0x25, 0x7d, 0xe7,
+	0x55, 0x57, 0xad, 0xb5, 0x16, 0x29, 0x00, 0xa1, 0x4d, 0xa3, 0x51, 0x1c, 0xf0, 0x59, 0x42, 0xbd,
+	0x9a, 0xd2, 0xe6, 0x00, 0xba, 0x07, 0x0e, 0x3d, 0x8f, 0x42, 0x1a, 0x0f, 0xa9, 0x67, 0xcb, 0x93,
+	0x5c, 0xdc, 0xd7, 0x00, 0xc9, 0x55, 0xfe, 0xcf, 0x16, 0x34, 0x8e, 0x68, 0x92, 0x46, 0x2c, 0x46,
+	0x1e, 0x34, 0xce, 0xd5, 0x52, 0x5e, 0xc1, 0x25, 0x99, 0x88, 0xfe, 0x2b, 0xee, 0x16, 0x8d, 0x4e,
+	0xb9, 0x57, 0x59, 0xb5, 0xd6, 0x6c, 0xa2, 0x25, 0x74, 0x0b, 0x60, 0x1c, 0xa5, 0x9c, 0xc6, 0xbd,
+	0x30, 0x4c, 0xa4, 0x87, 0x2e, 0x31, 0x10, 0xd4, 0x05, 0x67, 0x4a, 0x69, 0xf2, 0x2a, 0x4a, 0xb9,
+	0x57, 0x5b, 0xad, 0xae, 0xb9, 0x24, 0x97, 0xc5, 0x69, 0xc3, 0xd3, 0x20, 0x8a, 0x77, 0x5f, 0x78,
+	0xb6, 0x3a, 0x4d, 0x8b, 0xbe, 0x0d, 0xd5, 0xde, 0xf0, 0xcc, 0x7f, 0x02, 0xcd, 0x1d, 0x79, 0x0c,
+	0x09, 0xe2, 0x11, 0x45, 0x08, 0x6a, 0x27, 0x09, 0x9b, 0x48, 0xd7, 0x6c, 0x22, 0xd7, 0xa8, 0x0d,
+	0x15, 0xce, 0xb4, 0x4f, 0x15, 0xce, 0xfc, 0xcf, 0xa0, 0xa1, 0x82, 0x9e, 0xa2, 0x3b, 0xd0, 0x50,
+	0x71, 0x4f, 0x3d, 0x6b, 0xb5, 0x6a, 0xe6, 0x23, 0xc3, 0xfd, 0x87, 0xd0, 0x16, 0x5e, 0xd2, 0x34,
+	0x25, 0xf4, 0xbb, 0x19, 0x55, 0x3e, 0x05, 0x0a, 0x91, 0xc7, 0xb4, 0x48, 0x26, 0xfa, 0x5f, 0x43,
+	0x63, 0x2b, 0x18, 0x07, 0xf1, 0x90, 0x5e, 0x6e, 0x24, 0xc2, 0x14, 0x4c, 0xd8, 0x2c, 0x56, 0x61,
+	0xaa, 0x12, 0x2d, 0xf9, 0x7f, 0x5a, 0xb0, 0xf4, 0x26, 0x4e, 0xa7, 0x34, 0xe6, 0xfb, 0x33, 0x3e,
+	0x9d, 0x71, 0x61, 0xc9, 0xe7, 0x3b, 0x41, 0x7a, 0xaa, 0xb7, 0xd0, 0x92, 0x08, 0x18, 0x9b, 0xf1,
+	0xdd, 0x38, 0xa4, 0x73, 0xb9, 0xc7, 0x12, 0xc9, 0x65, 0x63, 0xf7, 0xaa, 0xb9, 0xbb, 0xe9, 0x4f,
+	0xed, 0x82, 0x3f, 0xc7, 0x2c, 0x0e, 0x69, 0x28, 0x23, 0xec, 0x10, 0x2d, 0xc9, 0xb4, 0xb1, 0xe1,
+	0x99, 0x8a, 0xae, 0x57, 0x97, 0xe1, 0x33, 0x10, 0x51, 0x59, 0xe7, 0xc1, 0x38, 0x0a, 0x03, 0xce,
+	0x12, 0xaf, 0xa1, 0x2a, 0x2b, 0x07, 0xfc, 0xaf, 0xa0, 0x5d, 0xba, 0x4c, 0x8a, 0xd6, 0xa0, 0xc1,
+	0xd4, 0x52, 0xc7, 0xba, 0x8d, 0x4b, 0x16, 0x24, 0x53, 0xfb, 0xb7, 0xc1, 0x1d, 0xcc, 0xb3, 0x68,
+	0x23, 0xa8, 0x9d, 0x16, 0x21, 0x90, 0x6b, 0x7f, 0x02, 0xd7, 0x0d, 0x3e, 0xec, 0xc6, 0x27, 0x0c,
+	0x61, 0x68, 0x1a, 0xac, 0xd0, 0xec, 0x2a, 0xd3, 0xc6, 0x34, 0x40, 0x1d, 0xa8, 0x9e, 0x50, 0xaa,
+	0x53, 0x20, 0x96, 0x22, 0x42, 0x53, 0x1a, 0x87, 0x51, 0x3c, 0x92, 0xa1, 0x73, 0x48, 0x26, 0xfa,
+	0x7f, 0x59, 0x50, 0x57, 0x65, 0xb1, 0x58, 0xfd, 0xf6, 0x87, 0xab, 0x5f, 0x54, 0x77, 0x42, 0xcf,
+	0x65, 0x1a, 0x15, 0x3b, 0x73, 0x59, 0xe8, 0x12, 0xc6, 0xb8, 0xd4, 0xa9, 0xac, 0xe4, 0xb2, 0x08,
+	0x2f, 0x8f, 0x26, 0x34, 0xe5, 0xc1, 0x64, 0x2a, 0x33, 0x53, 0x25, 0x05, 0x80, 0x7c, 0x68, 0x65,
+	0xec, 0x94, 0x5f, 0xd7, 0xe5, 0xd7, 0x25, 0x0c, 0xdd, 0x87, 0x76, 0x9e, 0x8f, 0x54, 0x5a, 0xa9,
+	0x2c, 0x2d, 0xa0, 0x26, 0xc7, 0x9c, 0x32, 0xc7, 0x7e, 0xb2, 0xa0, 0x31, 0x98, 0xef, 0xc6, 0xa2,
+	0x18, 0x6f, 0x01, 0x1c, 0x24, 0xf4, 0x7c, 0x60, 0x16, 0xa4, 0x81, 0x08, 0x8f, 0x84, 0xb4, 0x5f,
+	0x2e, 0xcc, 0x12, 0xf6, 0x31, 0xad, 0xca, 0x7f, 0x06, 0xce, 0x60, 0x5e, 0x10, 0x43, 0x17, 0xb9,
+	0x75, 0x59, 0x91, 0x57, 0xca, 0xcc, 0xfc, 0xdb, 0x82, 0xa6, 0x51, 0x0b, 0x57, 0xe4, 0x71, 0x15,
+	0xea, 0x51, 0x2c, 0xab, 0x54, 0xb5, 0x5e, 0x07, 0xeb, 0x08, 0x10, 0x8d, 0xa3, 0xbb, 0x45, 0x21,
+	0x57, 0x75, 0xcf, 0x1c, 0xcc, 0x17, 0x6a, 0x18, 0x2d, 0x43, 0x4d, 0xbc, 0x00, 0xf2, 0x1e, 0xed,
+	0x8d, 0x06, 0x1e, 0xcc, 0x07, 0xef, 0xa6, 0x94, 0x48, 0xd0, 0xa8, 0x15, 0xbb, 0x54, 0x2b, 0x25,
+	0x4a, 0xd5, 0x17, 0x28, 0x25, 0x32, 0x30, 0x64, 0x93, 0x49, 0x94, 0x4a, 0xb7, 0x1b, 0x8a, 0x90,
+	0x05, 0x72, 0x45, 0x1e, 0x7d, 0x68, 0xc9, 0xe7, 0xe7, 0x2a, 0x4e, 0xfd, 0x62, 0x81, 0x73, 0x90,
+	0xb0, 0x29, 0x4b, 0x83, 0x31, 0x5a, 0x01, 0xfb, 0x58, 0x7c, 0xa0, 0x79, 0x54, 0xc7, 0xea, 0x73,
+	0x05, 0xa2, 0x9b, 0x60, 0x27, 0x6c, 0x16, 0x87, 0xba, 0xd2, 0x95, 0x20, 0x0b, 0x9d, 0x8d, 0x89,
+	0x54, 0x54, 0xa5, 0x22, 0x97, 0xcb, 0x89, 0xaf, 0x5d, 0x99, 0x78, 0x7b, 0x31, 0xf1, 0x7f, 0x58,
+	0x50, 0x3b, 0x62, 0x9c, 0xa2, 0xff, 0xeb, 0x90, 0x5a, 0x32, 0xa4, 0x2e, 0x16, 0xe0, 0x7b, 0x83,
+	0x5a, 0x26, 0x60, 0xee, 0x6d, 0xd5, 0xf4, 0x76, 0x05, 0x5c, 0x79, 0x19, 0x83, 0x7b, 0x05, 0x50,
+	0xf6, 0xd7, 0xbe, 0xd2, 0xdf, 0xfa, 0xe2, 0x9b, 0x6a, 0xa4, 0xa1, 0x51, 0x4e, 0xc3, 0xf7, 0x50,
+	0x7f, 0x2e, 0xd2, 0xc5, 0x0d, 0x5f, 0xad, 0xf7, 0xfb, 0x5a, 0xb9, 0xd4, 0xd7, 0xea, 0xa2, 0xaf,
+	0xf7, 0x00, 0xa6, 0x09, 0x95, 0x75, 0xc0, 0x53, 0xf9, 0x80, 0x36, 0x37, 0x6c, 0x19, 0x1c, 0x62,
+	0x28, 0xfc, 0x5f, 0x2d, 0x68, 0x1d, 0x46, 0xa3, 0x98, 0x86, 0xba, 0x95, 0x7d, 0x70, 0x14, 0xf9,
+	0x77, 0xd3, 0xfc, 0x23, 0x38, 0xd9, 0xe4, 0x81, 0x96, 0xc1, 0x0a, 0xb4, 0x57, 0x4b, 0xd8, 0x74,
+	0x9a, 0x58, 0x81, 0x50, 0x1e, 0x7b, 0x95, 0xf7, 0x2a, 0x8f, 0xd1, 0x32, 0xd8, 0xe7, 0x8c, 0xd3,
+	0x9e, 0x74, 0x2d, 0x8f, 0x83, 0xc2, 0x32, 0xe5, 0x96, 0x57, 0xbb, 0xa0, 0xdc, 0xf2, 0x3f, 0x85,
+	0x1b, 0x47, 0x79, 0x5f, 0xcc, 0x88, 0x72, 0x49, 0x9e, 0xfc, 0x1f, 0x60, 0x29, 0x37, 0x96, 0xcf,
+	0xcf, 0xe5, 0xcf, 0xfd, 0x4d, 0xb0, 0x53, 0x1e, 0x9c, 0x65, 0x4f, 0x8d, 0x12, 0x64, 0x2c, 0xe8,
+	0xf8, 0xe4, 0x50, 0x6a, 0xd4, 0x4b, 0x5d, 0x00, 0x0b, 0x4c, 0xaf, 0x2d, 0x32, 0xdd, 0x3f, 0x34,
+	0x8e, 0x97, 0x63, 0x12, 0x06, 0x28, 0x9a, 0x7a, 0xfe, 0xbc, 0x96, 0x5c, 0x24, 0x86, 0x45, 0xde,
+	0x00, 0x2a, 0x46, 0x03, 0x38, 0x00, 0xf4, 0x82, 0x8e, 0xe9, 0x28, 0x90, 0x63, 0x65, 0x16, 0x81,
+	0x15, 0x70, 0x43, 0x85, 0xb2, 0x44, 0x5f, 0xad, 0x00, 0xca, 0x0d, 0xab, 0xb2, 0x38, 0x03, 0x7c,
+	0x0b, 0x50, 0xec, 0xf8, 0x31, 0x3b, 0x5d, 0x36, 0xd5, 0xf8, 0xcf, 0xa0, 0x69, 0xf8, 0x8c, 0x1e,
+	0x41, 0x33, 0x2c, 0x44, 0x1d, 0x87, 0x26, 0x2e, 0x4c, 0x88, 0xa9, 0x7f, 0xf8, 0x16, 0xea, 0xaa,
+	0x2d, 0xa3, 0x16, 0x38, 0x03, 0xd2, 0xdb, 0x3b, 0xfc, 0xa6, 0x4f, 0x3a, 0xd7, 0x84, 0xf4, 0x7c,
+	0x7f, 0x77, 0x6f, 0xab, 0x77, 0xd8, 0xef, 0x58, 0xc8, 0x05, 0xfb, 0x70, 0xd0, 0x7b, 0xd9, 0xef,
+	0x54, 0x50, 0x13, 0x1a, 0x6f, 0xf6, 0x94, 0x50, 0x15, 0x56, 0x2f, 0xfa, 0xaf, 0xfa, 0xdb, 0xbd,
+	0x41, 0xbf, 0x53, 0x43, 0x6d, 0x80, 0x37, 0x7b, 0xb9, 0x6c, 0x3f, 0xbc, 0x0f, 0x4e, 0xd6, 0x9f,
+	0xc4, 0x67, 0x07, 0xa4, 0x7f, 0xb4, 0x3f, 0xe8, 0x77, 0xae, 0xa1, 0x25, 0x70, 0x0f, 0x48, 0xff,
+	0xf9, 0xfe, 0xeb, 0xd7, 0xbb, 0x83, 0x8e, 0xb5, 0xf1, 0x5b, 0x0d, 0x6a, 0x7b, 0x2c, 0xa4, 0xe8,
+	0x36, 0xb8, 0x3b, 0x41, 0x1c, 0xa6, 0xa7, 0xa2, 0x00, 0x1c, 0xac, 0xc7, 0xed, 0x6e, 0xbe, 0x42,
+	0x0f, 0xe0, 0x86, 0x30, 0x18, 0x53, 0xf3, 0x1d, 0x2b, 0x4d, 0x38, 0xdd, 0x1a, 0xee, 0x0d, 0xcf,
+	0xd0, 0x32, 0x34, 0x95, 0xa1, 0xfa, 0xcb, 0xa1, 0x9b, 0xb7, 0x56, 0x7e, 0x02, 0xb0, 0x4d, 0x79,
+	0x36, 0xff, 0xb6, 0xb0, 0x31, 0x3c, 0x77, 0x1d, 0x9c, 0xe1, 0x77, 0xc1, 0xdd, 0xa6, 0x5c, 0x7e,
+	0xb7, 0x68, 0xa4, 0xb7, 0x7b, 0x6c, 0xa1, 0x07, 0x72, 0xab, 0x6c, 0xe0, 0xbd, 0x8e, 0xcb, 0x63,
+	0x72, 0xd7, 0xc1, 0x99, 0x6a, 0x1d, 0x9a, 0xa2, 0x4a, 0xf5, 0xb4, 0x77, 0xd1, 0xf2, 0x3a, 0x5e,
+	0x18, 0x15, 0x31, 0xb4, 0xb7, 0x29, 0x37, 0xef, 0x09, 0x38, 0x9f, 0x08, 0xbb, 0x1d, 0xbc, 0x38,
+	0xfc, 0xdd, 0x81, 0xb6, 0xba, 0x71, 0xfe, 0x80, 0xb9, 0x38, 0x5b, 0xea, 0x7b, 0xff, 0x0f, 0x40,
+	0x99, 0xc8, 0xa7, 0x44, 0x51, 0x5f, 0xab, 0xd4, 0x65, 0x75, 0x67, 0x5e, 0xc2, 0xe6, 0x4b, 0xd9,
+	0x6d, 0x60, 0x8d, 0xe7, 0x47, 0xe4, 0x4d, 0xaa, 0xf8, 0xa7, 0xa4, 0xf7, 0xd9, 0x84, 0xa5, 0x6d,
+	0xca, 0x8f, 0x0c, 0x96, 0xe1, 0x0b, 0x1d, 0xa5, 0x6b, 0xb0, 0x52, 0x32, 0x77, 0x53, 0x5e, 0xd5,
+	0x2c, 0xe2, 0xff, 0xe0, 0x8b, 0x34, 0xec, 0xb6, 0x4c, 0x70, 0x6b, 0xed, 0xed, 0xfd, 0x51, 0xc4,
+	0x4f, 0x67, 0xc7, 0x78, 0xc8, 0x26, 0xeb, 0xe9, 0x17, 0x8f, 0xbf, 0x7c, 0xfa, 0xe4, 0xe9, 0x93,
+	0xcd, 0xcf, 0xd7, 0x47, 0xec, 0x91, 0x7c, 0x18, 0x68, 0xb2, 0x2e, 0xff, 0x79, 0x1e, 0xd7, 0xe5,
+	0xcf, 0xe6, 0x3f, 0x03, 0x00, 0x89, 0x9b, 0xd0, 0x16, 0x95, 0x0e, 0x00, 0x00,
 }
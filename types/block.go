@@ -35,6 +35,11 @@ func VerifyBlock(b *proto.Block) bool {
 			return false
 		}
 	}
+	// The header commits to the evidence as well
+	if !bytes.Equal(b.Header.EvidenceHash, HashEvidenceList(b.Evidence)) {
+		logrus.Error("INVALID evidence hash")
+		return false
+	}
 	if len(b.PublicKey) != crypto.PubKeyLen {
 		logrus.Error("INVALID public key length")
 		return false
@@ -61,6 +66,7 @@ func SignBlock(pk *crypto.PrivateKey, b *proto.Block) *crypto.Signature {
 		}
 		b.Header.RootHash = tree.MerkleRoot()
 	}
+	b.Header.EvidenceHash = HashEvidenceList(b.Evidence)
 
 	hash := HashBlock(b)
 	sig := pk.Sign(hash)
@@ -0,0 +1,78 @@
+package types
+
+import (
+	"crypto/sha256"
+
+	pb "github.com/golang/protobuf/proto"
+	"github.com/s809616134/go-blocker/crypto"
+	"github.com/s809616134/go-blocker/proto"
+)
+
+// HashVote returns the hash the validator signs, the hash of the vote
+// without its signature.
+func HashVote(v *proto.Vote) []byte {
+	unsigned := pb.Clone(v).(*proto.Vote)
+	unsigned.Signature = nil
+	return hashMessage(unsigned)
+}
+
+func SignVote(pk *crypto.PrivateKey, v *proto.Vote) *crypto.Signature {
+	v.PublicKey = pk.Public().Bytes()
+	sig := pk.Sign(HashVote(v))
+	v.Signature = sig.Bytes()
+	return sig
+}
+
+func VerifyVote(v *proto.Vote) bool {
+	if len(v.PublicKey) != crypto.PubKeyLen || len(v.Signature) != crypto.SignatureLen {
+		return false
+	}
+	sig := crypto.SignatureFromBytes(v.Signature)
+	return sig.Verify(crypto.PublicKeyFromBytes(v.PublicKey), HashVote(v))
+}
+
+// HashProposal returns the hash the proposer signs. It covers the hash
+// of the block instead of the whole block.
+func HashProposal(p *proto.Proposal) []byte {
+	unsigned := &proto.Proposal{
+		Round:     p.Round,
+		PolRound:  p.PolRound,
+		PublicKey: p.PublicKey,
+	}
+	b, err := pb.Marshal(unsigned)
+	if err != nil {
+		panic(err)
+	}
+	if p.Block != nil {
+		b = append(b, HashBlock(p.Block)...)
+	}
+	return hashBytes(b)
+}
+
+func SignProposal(pk *crypto.PrivateKey, p *proto.Proposal) *crypto.Signature {
+	p.PublicKey = pk.Public().Bytes()
+	sig := pk.Sign(HashProposal(p))
+	p.Signature = sig.Bytes()
+	return sig
+}
+
+func VerifyProposal(p *proto.Proposal) bool {
+	if len(p.PublicKey) != crypto.PubKeyLen || len(p.Signature) != crypto.SignatureLen {
+		return false
+	}
+	sig := crypto.SignatureFromBytes(p.Signature)
+	return sig.Verify(crypto.PublicKeyFromBytes(p.PublicKey), HashProposal(p))
+}
+
+func hashMessage(m pb.Message) []byte {
+	b, err := pb.Marshal(m)
+	if err != nil {
+		panic(err)
+	}
+	return hashBytes(b)
+}
+
+func hashBytes(b []byte) []byte {
+	hash := sha256.Sum256(b)
+	return hash[:]
+}
@@ -0,0 +1,163 @@
+package types
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/s809616134/go-blocker/crypto"
+	"github.com/s809616134/go-blocker/proto"
+)
+
+// SignedHeaderOf returns the header of the proposed block with the
+// signature of the proposal, enough to prove who proposed the block in
+// which round.
+func SignedHeaderOf(p *proto.Proposal) *proto.SignedHeader {
+	return &proto.SignedHeader{
+		Header:    p.Block.Header,
+		Round:     p.Round,
+		PolRound:  p.PolRound,
+		PublicKey: p.PublicKey,
+		Signature: p.Signature,
+	}
+}
+
+// VerifySignedHeader verifies the signature of the proposal the header
+// was taken from. The proposal signs the hash of the header only, so the
+// rest of the block is not needed.
+func VerifySignedHeader(h *proto.SignedHeader) bool {
+	if h == nil || h.Header == nil {
+		return false
+	}
+	return VerifyProposal(&proto.Proposal{
+		Block:     &proto.Block{Header: h.Header},
+		Round:     h.Round,
+		PolRound:  h.PolRound,
+		PublicKey: h.PublicKey,
+		Signature: h.Signature,
+	})
+}
+
+// NewEvidence returns the evidence of the two signed headers, which
+// have to conflict, see VerifyEvidence.
+func NewEvidence(a, b *proto.SignedHeader) *proto.Evidence {
+	if bytes.Compare(HashHeader(a.Header), HashHeader(b.Header)) > 0 {
+		a, b = b, a
+	}
+	return &proto.Evidence{A: a, B: b}
+}
+
+// NewVoteEvidence returns the evidence of the two votes, which have to
+// conflict, see VerifyEvidence.
+func NewVoteEvidence(a, b *proto.Vote) *proto.Evidence {
+	if bytes.Compare(HashVote(a), HashVote(b)) > 0 {
+		a, b = b, a
+	}
+	return &proto.Evidence{VoteA: a, VoteB: b}
+}
+
+// VerifyEvidence checks that both headers of the evidence are signed by
+// the same key and are different headers proposed for the same height
+// in the same round, or that both votes are votes of the same type the
+// key cast for different blocks in the same round. Their order makes
+// sure every double sign has exactly one evidence.
+func VerifyEvidence(e *proto.Evidence) error {
+	headers := e.A != nil || e.B != nil
+	votes := e.VoteA != nil || e.VoteB != nil
+	switch {
+	case headers && votes:
+		return fmt.Errorf("evidence of both headers and votes")
+	case votes:
+		return verifyVoteEvidence(e)
+	}
+	if !VerifySignedHeader(e.A) || !VerifySignedHeader(e.B) {
+		return fmt.Errorf("invalid evidence signature")
+	}
+	if !bytes.Equal(e.A.PublicKey, e.B.PublicKey) {
+		return fmt.Errorf("evidence headers signed by different keys")
+	}
+	// signing blocks of two chains with the same key is fine
+	if e.A.Header.ChainID != e.B.Header.ChainID {
+		return fmt.Errorf("evidence headers of different chains %q and %q", e.A.Header.ChainID, e.B.Header.ChainID)
+	}
+	if e.A.Header.Height != e.B.Header.Height {
+		return fmt.Errorf("evidence headers of different heights (%d) and (%d)", e.A.Header.Height, e.B.Header.Height)
+	}
+	if e.A.Round != e.B.Round {
+		return fmt.Errorf("evidence headers of different rounds (%d) and (%d)", e.A.Round, e.B.Round)
+	}
+	if bytes.Compare(HashHeader(e.A.Header), HashHeader(e.B.Header)) >= 0 {
+		return fmt.Errorf("evidence headers not ordered by hash")
+	}
+	return nil
+}
+
+func verifyVoteEvidence(e *proto.Evidence) error {
+	if e.VoteA == nil || e.VoteB == nil || !VerifyVote(e.VoteA) || !VerifyVote(e.VoteB) {
+		return fmt.Errorf("invalid evidence signature")
+	}
+	a, b := e.VoteA, e.VoteB
+	if !bytes.Equal(a.PublicKey, b.PublicKey) {
+		return fmt.Errorf("evidence votes signed by different keys")
+	}
+	if a.ChainID != b.ChainID {
+		return fmt.Errorf("evidence votes of different chains %q and %q", a.ChainID, b.ChainID)
+	}
+	if a.Type != b.Type {
+		return fmt.Errorf("evidence votes of different types %s and %s", a.Type, b.Type)
+	}
+	if a.Height != b.Height {
+		return fmt.Errorf("evidence votes of different heights (%d) and (%d)", a.Height, b.Height)
+	}
+	if a.Round != b.Round {
+		return fmt.Errorf("evidence votes of different rounds (%d) and (%d)", a.Round, b.Round)
+	}
+	if bytes.Equal(a.BlockHash, b.BlockHash) {
+		return fmt.Errorf("evidence votes for the same block")
+	}
+	if bytes.Compare(HashVote(a), HashVote(b)) >= 0 {
+		return fmt.Errorf("evidence votes not ordered by hash")
+	}
+	return nil
+}
+
+// EvidenceOffender returns the address of the validator that signed
+// the headers or votes of the evidence.
+func EvidenceOffender(e *proto.Evidence) crypto.Address {
+	if e.VoteA != nil {
+		return crypto.PublicKeyFromBytes(e.VoteA.PublicKey).Address()
+	}
+	return crypto.PublicKeyFromBytes(e.A.PublicKey).Address()
+}
+
+// EvidenceHeight returns the height the offender double signed at.
+func EvidenceHeight(e *proto.Evidence) int32 {
+	if e.VoteA != nil {
+		return e.VoteA.Height
+	}
+	return e.A.Header.Height
+}
+
+// EvidenceChainID returns the chain the offender double signed on.
+func EvidenceChainID(e *proto.Evidence) string {
+	if e.VoteA != nil {
+		return e.VoteA.ChainID
+	}
+	return e.A.Header.ChainID
+}
+
+func HashEvidence(e *proto.Evidence) []byte {
+	return hashMessage(e)
+}
+
+// HashEvidenceList returns the hash the header of a block commits to,
+// nil for a block without evidence.
+func HashEvidenceList(list []*proto.Evidence) []byte {
+	if len(list) == 0 {
+		return nil
+	}
+	b := []byte{}
+	for _, e := range list {
+		b = append(b, HashEvidence(e)...)
+	}
+	return hashBytes(b)
+}
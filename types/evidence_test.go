@@ -0,0 +1,113 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/s809616134/go-blocker/crypto"
+	"github.com/s809616134/go-blocker/proto"
+	"github.com/s809616134/go-blocker/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func signedHeader(privKey *crypto.PrivateKey, height, round int32) *proto.SignedHeader {
+	p := &proto.Proposal{
+		Block:    util.RandomBlock(),
+		Round:    round,
+		PolRound: -1,
+	}
+	p.Block.Header.Height = height
+	SignProposal(privKey, p)
+	return SignedHeaderOf(p)
+}
+
+func TestVerifyEvidence(t *testing.T) {
+	var (
+		privKey = crypto.GeneratPrivateKey()
+		a       = signedHeader(privKey, 10, 1)
+		b       = signedHeader(privKey, 10, 1)
+	)
+	e := NewEvidence(a, b)
+	assert.Nil(t, VerifyEvidence(e))
+	assert.Equal(t, privKey.Public().Address(), EvidenceOffender(e))
+	// both orders give the same evidence
+	assert.Equal(t, HashEvidence(e), HashEvidence(NewEvidence(b, a)))
+
+	// the same header twice
+	assert.NotNil(t, VerifyEvidence(&proto.Evidence{A: a, B: a}))
+	// headers of different heights
+	assert.NotNil(t, VerifyEvidence(NewEvidence(a, signedHeader(privKey, 11, 1))))
+	// proposing a new block in another round is fine
+	assert.NotNil(t, VerifyEvidence(NewEvidence(a, signedHeader(privKey, 10, 2))))
+	// headers of different chains
+	p := &proto.Proposal{Block: util.RandomBlock(), Round: 1, PolRound: -1}
+	p.Block.Header.Height = 10
+	p.Block.Header.ChainID = "other"
+	SignProposal(privKey, p)
+	assert.NotNil(t, VerifyEvidence(NewEvidence(a, SignedHeaderOf(p))))
+	// headers of different signers
+	assert.NotNil(t, VerifyEvidence(NewEvidence(a, signedHeader(crypto.GeneratPrivateKey(), 10, 1))))
+	// a header nobody signed
+	e.B.Signature = e.A.Signature
+	assert.NotNil(t, VerifyEvidence(e))
+}
+
+func signedVote(privKey *crypto.PrivateKey, height, round int32, hash []byte) *proto.Vote {
+	v := &proto.Vote{
+		Type:      proto.VoteType_PRECOMMIT,
+		Height:    height,
+		Round:     round,
+		BlockHash: hash,
+	}
+	SignVote(privKey, v)
+	return v
+}
+
+func TestVerifyVoteEvidence(t *testing.T) {
+	var (
+		privKey = crypto.GeneratPrivateKey()
+		a       = signedVote(privKey, 10, 1, util.RandomHash())
+		b       = signedVote(privKey, 10, 1, nil)
+	)
+	e := NewVoteEvidence(a, b)
+	assert.Nil(t, VerifyEvidence(e))
+	assert.Equal(t, privKey.Public().Address(), EvidenceOffender(e))
+	assert.Equal(t, int32(10), EvidenceHeight(e))
+	assert.Equal(t, HashEvidence(e), HashEvidence(NewVoteEvidence(b, a)))
+
+	// the same vote twice
+	assert.NotNil(t, VerifyEvidence(&proto.Evidence{VoteA: a, VoteB: a}))
+	// votes of different rounds, heights and signers
+	assert.NotNil(t, VerifyEvidence(NewVoteEvidence(a, signedVote(privKey, 10, 2, nil))))
+	assert.NotNil(t, VerifyEvidence(NewVoteEvidence(a, signedVote(privKey, 11, 1, nil))))
+	assert.NotNil(t, VerifyEvidence(NewVoteEvidence(a, signedVote(crypto.GeneratPrivateKey(), 10, 1, nil))))
+	// a prevote and a precommit
+	prevote := signedVote(privKey, 10, 1, nil)
+	prevote.Type = proto.VoteType_PREVOTE
+	SignVote(privKey, prevote)
+	assert.NotNil(t, VerifyEvidence(NewVoteEvidence(a, prevote)))
+	// votes of different chains
+	other := signedVote(privKey, 10, 1, nil)
+	other.ChainID = "other"
+	SignVote(privKey, other)
+	assert.NotNil(t, VerifyEvidence(NewVoteEvidence(a, other)))
+	// a vote and a header
+	assert.NotNil(t, VerifyEvidence(&proto.Evidence{A: signedHeader(privKey, 10, 1), VoteA: a}))
+	// a vote nobody signed
+	e.VoteB.Signature = e.VoteA.Signature
+	assert.NotNil(t, VerifyEvidence(e))
+}
+
+func TestVerifyBlockEvidenceHash(t *testing.T) {
+	var (
+		privKey = crypto.GeneratPrivateKey()
+		block   = util.RandomBlock()
+		e       = NewEvidence(signedHeader(privKey, 1, 0), signedHeader(privKey, 1, 0))
+	)
+	block.Evidence = append(block.Evidence, e)
+	SignBlock(privKey, block)
+	assert.True(t, VerifyBlock(block))
+
+	// the evidence can't be stripped from a signed block
+	block.Evidence = nil
+	assert.False(t, VerifyBlock(block))
+}
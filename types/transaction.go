@@ -9,7 +9,7 @@ import (
 )
 
 func SignTransaction(pk *crypto.PrivateKey, tx *proto.Transaction) *crypto.Signature {
-	return pk.Sign(HashTransaction(tx))
+	return pk.Sign(signingHash(tx))
 }
 
 func HashTransaction(tx *proto.Transaction) []byte {
@@ -21,26 +21,53 @@ func HashTransaction(tx *proto.Transaction) []byte {
 	return hash[:]
 }
 
+// signingHash is the hash every input of the tx signs, the hash of the
+// tx with all the input signatures left out. That way the inputs can be
+// signed in any order.
+func signingHash(tx *proto.Transaction) []byte {
+	unsigned := pb.Clone(tx).(*proto.Transaction)
+	for _, input := range unsigned.Inputs {
+		input.Signature = nil
+	}
+	return HashTransaction(unsigned)
+}
+
 func VerifyTransaction(tx *proto.Transaction) bool {
+	hash := signingHash(tx)
 	for _, input := range tx.Inputs {
-		if len(input.Signature) == 0 {
-			panic("the transaction has no signature")
+		if len(input.Signature) != crypto.SignatureLen {
+			return false
+		}
+		if len(input.PublicKey) != crypto.PubKeyLen {
+			return false
 		}
 
 		sig := crypto.SignatureFromBytes(input.Signature)
 		pubKey := crypto.PublicKeyFromBytes(input.PublicKey)
-
-		// we don't have signature in tx when we are signing
-		// we should verify tx without signature
-
-		// TODO: make sure we don't run into issue after verification
-		// cause we set the signature to nil
-		tempSig := input.Signature
-		input.Signature = nil
-		if !sig.Verify(pubKey, HashTransaction(tx)) {
+		if !sig.Verify(pubKey, hash) {
 			return false
 		}
-		input.Signature = tempSig
 	}
 	return true
 }
+
+// NewCoinbaseTransaction returns the tx minting amount to address in
+// the block of the given chain at the given height.
+func NewCoinbaseTransaction(chainID string, height int32, amount int64, address []byte) *proto.Transaction {
+	return &proto.Transaction{
+		Version: 1,
+		ChainID: chainID,
+		Type:    proto.TxType_COINBASE,
+		Height:  height,
+		Outputs: []*proto.TxOutput{
+			{
+				Amount:  amount,
+				Address: address,
+			},
+		},
+	}
+}
+
+func IsCoinbase(tx *proto.Transaction) bool {
+	return tx.Type == proto.TxType_COINBASE
+}
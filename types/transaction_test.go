@@ -49,3 +49,47 @@ func TestNewTransaction(t *testing.T) {
 
 	assert.True(t, VerifyTransaction(tx))
 }
+
+func TestVerifyTransactionWithoutSignature(t *testing.T) {
+	privKey := crypto.GeneratPrivateKey()
+	tx := &proto.Transaction{
+		Version: 1,
+		Inputs: []*proto.TxInput{
+			{
+				PrevTxHash:   util.RandomHash(),
+				PrevOutIndex: 0,
+				PublicKey:    privKey.Public().Bytes(),
+			},
+		},
+	}
+
+	assert.False(t, VerifyTransaction(tx))
+}
+
+func TestVerifyTransactionMultipleInputs(t *testing.T) {
+	var (
+		privKeys = []*crypto.PrivateKey{crypto.GeneratPrivateKey(), crypto.GeneratPrivateKey()}
+		tx       = &proto.Transaction{Version: 1}
+	)
+	for _, privKey := range privKeys {
+		tx.Inputs = append(tx.Inputs, &proto.TxInput{
+			PrevTxHash:   util.RandomHash(),
+			PrevOutIndex: 0,
+			PublicKey:    privKey.Public().Bytes(),
+		})
+	}
+	tx.Outputs = append(tx.Outputs, &proto.TxOutput{
+		Amount:  100,
+		Address: privKeys[0].Public().Address().Bytes(),
+	})
+
+	// every input signs no matter the signatures already in place
+	for i, privKey := range privKeys {
+		tx.Inputs[i].Signature = SignTransaction(privKey, tx).Bytes()
+	}
+	assert.True(t, VerifyTransaction(tx))
+
+	// an input signed by the wrong key fails the whole tx
+	tx.Inputs[1].Signature = SignTransaction(privKeys[0], tx).Bytes()
+	assert.False(t, VerifyTransaction(tx))
+}
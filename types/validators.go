@@ -0,0 +1,16 @@
+package types
+
+import (
+	"github.com/s809616134/go-blocker/proto"
+)
+
+// HashValidators returns the hash the headers of an epoch commit to for
+// its validator set. Light clients check the set they got from a node
+// against it before they follow the commits of its validators.
+func HashValidators(list []*proto.ValidatorInfo) []byte {
+	b := []byte{}
+	for _, v := range list {
+		b = append(b, hashMessage(v)...)
+	}
+	return hashBytes(b)
+}
@@ -0,0 +1,24 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/s809616134/go-blocker/crypto"
+	"github.com/s809616134/go-blocker/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashValidators(t *testing.T) {
+	var (
+		a = &proto.ValidatorInfo{Address: crypto.GeneratPrivateKey().Public().Address().Bytes(), Stake: 100, SelfStake: 100}
+		b = &proto.ValidatorInfo{Address: crypto.GeneratPrivateKey().Public().Address().Bytes(), Stake: 200, SelfStake: 100}
+	)
+	hash := HashValidators([]*proto.ValidatorInfo{a, b})
+	assert.Len(t, hash, 32)
+	assert.NotEqual(t, hash, HashValidators([]*proto.ValidatorInfo{a}))
+	assert.NotEqual(t, hash, HashValidators(nil))
+
+	// the stake is part of the set
+	b.Stake = 100
+	assert.NotEqual(t, hash, HashValidators([]*proto.ValidatorInfo{a, b}))
+}